@@ -0,0 +1,194 @@
+package withdrawal_service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pdcgo/schema/services/common/v1"
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var ErrColumnMappingNotFound = errors.New("mapping kolom tidak ditemukan")
+
+// ColumnMappingConfig mapping kolom import csv / xlsx per team.
+// mp_id 0 berlaku untuk semua marketplace team, mapping per marketplace didahulukan
+type ColumnMappingConfig struct {
+	ID        uint `gorm:"primarykey"`
+	TeamID    uint `gorm:"uniqueIndex:idx_column_mapping_team_mp"`
+	MpID      uint `gorm:"uniqueIndex:idx_column_mapping_team_mp"`
+	Mapping   datatypes.JSONType[*datasource.ColumnMapping]
+	UpdatedBy uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func SaveColumnMapping(db *gorm.DB, teamID, mpID uint, mapping *datasource.ColumnMapping, userID uint) (*ColumnMappingConfig, error) {
+	err := mapping.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var config ColumnMappingConfig
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Model(&ColumnMappingConfig{}).
+			Where("team_id = ?", teamID).
+			Where("mp_id = ?", mpID).
+			Limit(1).
+			Find(&config).
+			Error
+		if err != nil {
+			return err
+		}
+
+		config.TeamID = teamID
+		config.MpID = mpID
+		config.Mapping = datatypes.NewJSONType(mapping)
+		config.UpdatedBy = userID
+		return tx.Save(&config).Error
+	})
+
+	return &config, err
+}
+
+func (c *ColumnMappingConfig) ToIface() *withdrawal_task_iface.ColumnMapping {
+	return &withdrawal_task_iface.ColumnMapping{
+		Id:        uint64(c.ID),
+		TeamId:    uint64(c.TeamID),
+		MpId:      uint64(c.MpID),
+		Mapping:   columnMappingSpec(c.Mapping.Data()),
+		UpdatedBy: uint64(c.UpdatedBy),
+		UpdatedAt: c.UpdatedAt.Unix(),
+	}
+}
+
+func columnMappingSpec(mapping *datasource.ColumnMapping) *withdrawal_task_iface.ColumnMappingSpec {
+	if mapping == nil {
+		return nil
+	}
+
+	spec := &withdrawal_task_iface.ColumnMappingSpec{
+		DateColumn:        mapping.DateColumn,
+		DateLayout:        mapping.DateLayout,
+		OrderRefColumn:    mapping.OrderRefColumn,
+		OrderRefRegex:     mapping.OrderRefRegex,
+		AmountColumn:      mapping.AmountColumn,
+		TypeColumn:        mapping.TypeColumn,
+		DescriptionColumn: mapping.DescriptionColumn,
+		Delimiter:         mapping.Delimiter,
+		DefaultType:       mapping.DefaultType,
+	}
+	for _, rule := range mapping.TypeRules {
+		spec.TypeRules = append(spec.TypeRules, &withdrawal_task_iface.ColumnMappingTypeRule{
+			Pattern: rule.Pattern,
+			Type:    rule.Type,
+		})
+	}
+	for _, rule := range mapping.PayoutRules {
+		spec.PayoutRules = append(spec.PayoutRules, &withdrawal_task_iface.ColumnMappingRule{
+			Column:  rule.Column,
+			Pattern: rule.Pattern,
+		})
+	}
+	for _, rule := range mapping.SkipRules {
+		spec.SkipRules = append(spec.SkipRules, &withdrawal_task_iface.ColumnMappingRule{
+			Column:  rule.Column,
+			Pattern: rule.Pattern,
+		})
+	}
+
+	return spec
+}
+
+// ColumnMappingFromSpec mapping dari request api
+func ColumnMappingFromSpec(spec *withdrawal_task_iface.ColumnMappingSpec) *datasource.ColumnMapping {
+	mapping := &datasource.ColumnMapping{
+		DateColumn:        spec.GetDateColumn(),
+		DateLayout:        spec.GetDateLayout(),
+		OrderRefColumn:    spec.GetOrderRefColumn(),
+		OrderRefRegex:     spec.GetOrderRefRegex(),
+		AmountColumn:      spec.GetAmountColumn(),
+		TypeColumn:        spec.GetTypeColumn(),
+		DescriptionColumn: spec.GetDescriptionColumn(),
+		Delimiter:         spec.GetDelimiter(),
+		DefaultType:       spec.GetDefaultType(),
+	}
+	for _, rule := range spec.GetTypeRules() {
+		mapping.TypeRules = append(mapping.TypeRules, datasource.MappingTypeRule{
+			Pattern: rule.GetPattern(),
+			Type:    rule.GetType(),
+		})
+	}
+	for _, rule := range spec.GetPayoutRules() {
+		mapping.PayoutRules = append(mapping.PayoutRules, datasource.MappingRule{
+			Column:  rule.GetColumn(),
+			Pattern: rule.GetPattern(),
+		})
+	}
+	for _, rule := range spec.GetSkipRules() {
+		mapping.SkipRules = append(mapping.SkipRules, datasource.MappingRule{
+			Column:  rule.GetColumn(),
+			Pattern: rule.GetPattern(),
+		})
+	}
+
+	return mapping
+}
+
+// FindColumnMapping return nil kalau team belum punya mapping
+func FindColumnMapping(db *gorm.DB, teamID, mpID uint) (*ColumnMappingConfig, error) {
+	var config ColumnMappingConfig
+	err := db.
+		Model(&ColumnMappingConfig{}).
+		Where("team_id = ?", teamID).
+		Where("mp_id IN ?", []uint{mpID, 0}).
+		Order("mp_id desc").
+		Limit(1).
+		Find(&config).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ID == 0 {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+// NewMappedImporter importer csv / xlsx dari mapping kolom team
+func NewMappedImporter(
+	source withdrawal_iface.ImporterSource,
+	tipe common.MarketplaceType,
+	mapping *datasource.ColumnMapping,
+	data []byte,
+) (WdImporterIterate, error) {
+	var format datasource.MappedFormat
+	switch source {
+	case withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV:
+		format = datasource.MappedCsv
+	case withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS:
+		format = datasource.MappedXls
+	default:
+		return nil, wd_error.New(
+			wd_error.CodeUnsupportedImporter,
+			fmt.Sprintf("mapping kolom tidak mendukung source %s", source),
+			wd_error.Params{"source": source.String()},
+		)
+	}
+
+	return datasource.NewMappedWd(
+		io.NopCloser(bytes.NewReader(data)),
+		format,
+		LegacyOrderMpType(tipe),
+		mapping,
+	), nil
+}
@@ -1,7 +1,13 @@
 package withdrawal_service
 
 import (
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+
 	_ "github.com/GoogleCloudPlatform/cloudsql-proxy/proxy/dialers/postgres"
+	"github.com/GoogleCloudPlatform/cloudsql-proxy/proxy/proxy"
 	"github.com/pdcgo/shared/configs"
 	"github.com/pdcgo/shared/pkg/gorm_commenter"
 	"gorm.io/driver/postgres"
@@ -22,9 +28,29 @@ func NewCloudDatabase(cfg *configs.DatabaseConfig) (*gorm.DB, error) {
 		return db, err
 	}
 
-	sqldb.SetMaxOpenConns(2)
+	sqldb.SetMaxOpenConns(runnerPoolSize(runnerWorkerCount()))
 	db.Use(gorm_commenter.NewCommentClausePlugin())
 
 	return db, err
 
 }
+
+// addr dari lib/pq formatnya '[project:region:instance]:port'
+var cloudsqlInstanceRegexp = regexp.MustCompile(`^\[(.+)\]:[0-9]+$`)
+
+// cloudsqlDialer sama dengan dialer driver cloudsqlpostgres, dipakai koneksi listen yang di luar pool
+type cloudsqlDialer struct{}
+
+// Dial implements pq.Dialer.
+func (d cloudsqlDialer) Dial(ntw, addr string) (net.Conn, error) {
+	matches := cloudsqlInstanceRegexp.FindStringSubmatch(addr)
+	if len(matches) != 2 {
+		return nil, fmt.Errorf("failed to parse cloudsql addr %q", addr)
+	}
+	return proxy.Dial(matches[1])
+}
+
+// DialTimeout implements pq.Dialer.
+func (d cloudsqlDialer) DialTimeout(ntw, addr string, timeout time.Duration) (net.Conn, error) {
+	return d.Dial(ntw, addr)
+}
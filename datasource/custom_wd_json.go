@@ -0,0 +1,335 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+)
+
+// CustomWdJsonVersion versi schema json withdrawal marketplace custom yang didukung
+const CustomWdJsonVersion = 1
+
+// tipe earning di json, dipetakan ke adjustment type
+var customEarningTypes = map[string]db_models.AdjustmentType{
+	"order_fund":        db_models.AdjOrderFund,
+	"commission":        db_models.AdjCommision,
+	"compensation":      db_models.AdjCompensation,
+	"lost_compensation": db_models.AdjLostCompensation,
+	"return":            db_models.AdjReturn,
+	"shipping":          db_models.AdjShipping,
+	"adjustment":        db_models.AdjUnknownAdj,
+	"unknown":           db_models.AdjUnknown,
+}
+
+// CustomWdFile schema file json withdrawal untuk marketplace custom (web shop, reseller).
+//
+//	{
+//	  "version": 1,
+//	  "shop_username": "tokoku",            // opsional
+//	  "withdrawals": [
+//	    {
+//	      "id": "WD-0001",                   // opsional, hanya untuk pesan error
+//	      "at": "2025-08-04T09:00:00+07:00", // RFC3339
+//	      "amount": "130500",                // dana yang ditarik, positif
+//	      "balance_after": "0",              // opsional, saldo setelah penarikan
+//	      "earnings": [
+//	        {
+//	          "order_ref_id": "WEB-1001",      // wajib kecuali type unknown
+//	          "type": "order_fund",
+//	          "amount": "84000",               // plus pemasukan, minus potongan
+//	          "at": "2025-08-03T12:00:00+07:00",
+//	          "description": "pembayaran order"
+//	        }
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// amount boleh number atau string desimal dengan titik. type earning:
+// order_fund, commission, compensation, lost_compensation, return, shipping, adjustment, unknown.
+// earning satu withdrawal adalah earning yang didanai withdrawal tersebut.
+type CustomWdFile struct {
+	Version      int                 `json:"version"`
+	ShopUsername string              `json:"shop_username"`
+	Withdrawals  []*CustomWithdrawal `json:"withdrawals"`
+}
+
+type CustomWithdrawal struct {
+	ID           string           `json:"id"`
+	At           time.Time        `json:"at"`
+	Amount       json.Number      `json:"amount"`
+	BalanceAfter json.Number      `json:"balance_after"`
+	Earnings     []*CustomEarning `json:"earnings"`
+}
+
+type CustomEarning struct {
+	OrderRefID  string      `json:"order_ref_id"`
+	Type        string      `json:"type"`
+	Amount      json.Number `json:"amount"`
+	At          time.Time   `json:"at"`
+	Description string      `json:"description"`
+}
+
+type customWdSet struct {
+	fund  *db_models.InvoItem
+	items []*db_models.InvoItem
+}
+
+type CustomWdJson struct {
+	reader io.ReadCloser
+	file   *CustomWdFile
+	sets   []*customWdSet
+}
+
+// GetShopUsername implements order_api.WdImporterIterate.
+func (c *CustomWdJson) GetShopUsername() (string, error) {
+	file, err := c.getFile()
+	if err != nil {
+		return "", err
+	}
+
+	if file.ShopUsername == "" {
+		return "", ErrCannotGetMarketplaceUsername
+	}
+	return file.ShopUsername, nil
+}
+
+// GetRefIDs implements order_api.WdImporterIterate.
+func (c *CustomWdJson) GetRefIDs() (OrderRefList, error) {
+	hasil := OrderRefList{}
+	sets, err := c.getSets()
+	if err != nil {
+		return hasil, err
+	}
+
+	maper := map[string]bool{}
+	for _, set := range sets {
+		for _, item := range set.items {
+			if item.ExternalOrderID == "" || maper[item.ExternalOrderID] {
+				continue
+			}
+			maper[item.ExternalOrderID] = true
+			hasil.Add(item.ExternalOrderID)
+		}
+	}
+
+	return hasil, nil
+}
+
+// Iterate implements order_api.WdImporterIterate.
+// urutan seperti file marketplace lain, withdrawal terbaru dulu lalu earning yang didanainya
+func (c *CustomWdJson) Iterate(ctx context.Context, handler func(item *db_models.InvoItem) error) error {
+	sets, err := c.getSets()
+	if err != nil {
+		return err
+	}
+
+	for _, set := range sets {
+		err = handler(set.fund)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range set.items {
+			err = handler(item)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *CustomWdJson) getSets() ([]*customWdSet, error) {
+	if c.sets != nil {
+		return c.sets, nil
+	}
+
+	file, err := c.getFile()
+	if err != nil {
+		return nil, err
+	}
+
+	type itemKey struct {
+		orderID string
+		tipe    db_models.AdjustmentType
+	}
+
+	sets := make([]*customWdSet, len(file.Withdrawals))
+	for i, wd := range file.Withdrawals {
+		path := fmt.Sprintf("withdrawals[%d]", i)
+
+		amount, err := customParseAmount(path+".amount", wd.Amount, true)
+		if err != nil {
+			return nil, err
+		}
+		if amount <= 0 {
+			return nil, customInvalid(path+".amount", "amount withdrawal harus lebih dari 0")
+		}
+
+		balance, err := customParseAmount(path+".balance_after", wd.BalanceAfter, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if wd.At.IsZero() {
+			return nil, customInvalid(path+".at", "tanggal withdrawal wajib diisi")
+		}
+
+		set := &customWdSet{
+			fund: &db_models.InvoItem{
+				MpFrom:          db_models.OrderMpCustom,
+				Type:            db_models.AdjFund,
+				TransactionDate: wd.At,
+				Description:     strings.TrimSpace(fmt.Sprintf("withdrawal %s", wd.ID)),
+				Amount:          (-amount).Float64(),
+				BalanceAfter:    balance.Float64(),
+			},
+		}
+
+		// satu order satu item per tipe supaya tidak saling timpa di order adjustment
+		itemMap := map[itemKey]*db_models.InvoItem{}
+		for j, earn := range wd.Earnings {
+			epath := fmt.Sprintf("%s.earnings[%d]", path, j)
+
+			tipe, ok := customEarningTypes[strings.ToLower(strings.TrimSpace(earn.Type))]
+			if !ok {
+				return nil, customInvalid(epath+".type", fmt.Sprintf("tipe earning %s tidak dikenali", earn.Type))
+			}
+
+			orderID := strings.TrimSpace(earn.OrderRefID)
+			if orderID == "" && tipe != db_models.AdjUnknown {
+				return nil, customInvalid(epath+".order_ref_id", "order_ref_id wajib diisi")
+			}
+
+			earnAmount, err := customParseAmount(epath+".amount", earn.Amount, true)
+			if err != nil {
+				return nil, err
+			}
+
+			at := earn.At
+			if at.IsZero() {
+				at = wd.At
+			}
+
+			if orderID == "" {
+				set.items = append(set.items, customInvoItem(orderID, tipe, at, earn.Description, earnAmount))
+				continue
+			}
+
+			key := itemKey{orderID, tipe}
+			item := itemMap[key]
+			if item == nil {
+				item = customInvoItem(orderID, tipe, at, earn.Description, earnAmount)
+				itemMap[key] = item
+				set.items = append(set.items, item)
+				continue
+			}
+
+			item.Amount = (money.FromFloat(item.Amount) + earnAmount).Float64()
+			if at.After(item.TransactionDate) {
+				item.TransactionDate = at
+			}
+			if earn.Description != "" && !strings.Contains(item.Description, earn.Description) {
+				item.Description = fmt.Sprintf("%s, %s", item.Description, earn.Description)
+			}
+		}
+
+		sort.SliceStable(set.items, func(i, j int) bool {
+			return set.items[i].TransactionDate.After(set.items[j].TransactionDate)
+		})
+		sets[i] = set
+	}
+
+	sort.SliceStable(sets, func(i, j int) bool {
+		return sets[i].fund.TransactionDate.After(sets[j].fund.TransactionDate)
+	})
+
+	c.sets = sets
+	return sets, nil
+}
+
+func (c *CustomWdJson) getFile() (*CustomWdFile, error) {
+	if c.file != nil {
+		return c.file, nil
+	}
+
+	data, err := io.ReadAll(c.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var file CustomWdFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&file)
+	if err != nil {
+		return nil, wd_error.New(
+			wd_error.CodeFileInvalid,
+			fmt.Sprintf("file json withdrawal tidak valid: %s", err.Error()),
+			wd_error.Params{"error": err.Error()},
+		)
+	}
+
+	if file.Version != CustomWdJsonVersion {
+		return nil, customInvalid("version", fmt.Sprintf("versi %d tidak didukung", file.Version))
+	}
+
+	if len(file.Withdrawals) == 0 {
+		return nil, customInvalid("withdrawals", "file tidak berisi withdrawal")
+	}
+
+	c.file = &file
+	return c.file, nil
+}
+
+func customInvoItem(orderID string, tipe db_models.AdjustmentType, at time.Time, desc string, amount money.Amount) *db_models.InvoItem {
+	return &db_models.InvoItem{
+		MpFrom:          db_models.OrderMpCustom,
+		ExternalOrderID: orderID,
+		Type:            tipe,
+		TransactionDate: at,
+		Description:     desc,
+		Amount:          amount.Float64(),
+	}
+}
+
+func customParseAmount(path string, data json.Number, required bool) (money.Amount, error) {
+	if data == "" {
+		if required {
+			return 0, customInvalid(path, "amount wajib diisi")
+		}
+		return 0, nil
+	}
+
+	amount, err := money.Parse(data.String())
+	if err != nil {
+		return 0, customInvalid(path, err.Error())
+	}
+	return amount, nil
+}
+
+func customInvalid(path string, msg string) error {
+	return wd_error.New(
+		wd_error.CodeFileInvalid,
+		fmt.Sprintf("%s: %s", path, msg),
+		wd_error.Params{"field": path},
+	)
+}
+
+func NewCustomWdJson(reader io.ReadCloser) *CustomWdJson {
+	return &CustomWdJson{
+		reader: reader,
+	}
+}
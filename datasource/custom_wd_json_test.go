@@ -0,0 +1,76 @@
+package datasource_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterateCustomWdJson(t *testing.T) {
+	file, err := os.Open("../test/assets/custom/withdrawal.json")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	importer := datasource.NewCustomWdJson(file)
+	username, err := importer.GetShopUsername()
+	assert.Nil(t, err)
+	assert.Equal(t, "tokoweb", username)
+
+	refIDs, err := importer.GetRefIDs()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, datasource.OrderRefList{"WEB-1001", "WEB-1002", "WEB-1003"}, refIDs)
+
+	items := []*db_models.InvoItem{}
+	err = importer.Iterate(context.Background(), func(item *db_models.InvoItem) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, items, 6)
+
+	// withdrawal terbaru dulu
+	assert.Equal(t, db_models.AdjFund, items[0].Type)
+	assert.Equal(t, -130500.0, items[0].Amount)
+	assert.Equal(t, db_models.OrderMpCustom, items[0].MpFrom)
+
+	assert.Equal(t, db_models.AdjOrderFund, items[1].Type)
+	assert.Equal(t, 84000.0, items[1].Amount)
+
+	// dua biaya satu order digabung
+	assert.Equal(t, db_models.AdjCommision, items[2].Type)
+	assert.Equal(t, -3500.0, items[2].Amount)
+	assert.Equal(t, "biaya layanan, biaya reseller", items[2].Description)
+
+	assert.Equal(t, "WEB-1002", items[3].ExternalOrderID)
+
+	assert.Equal(t, db_models.AdjFund, items[4].Type)
+	assert.Equal(t, -50000.5, items[4].Amount)
+	assert.Equal(t, 50000.5, items[5].Amount)
+}
+
+func TestCustomWdJsonInvalid(t *testing.T) {
+	cases := map[string]string{
+		"versi":      `{"version": 2, "withdrawals": []}`,
+		"kosong":     `{"version": 1, "withdrawals": []}`,
+		"field lain": `{"version": 1, "withdraw": []}`,
+		"amount":     `{"version": 1, "withdrawals": [{"at": "2025-08-01T09:00:00Z", "amount": "-10"}]}`,
+		"tipe": `{"version": 1, "withdrawals": [{"at": "2025-08-01T09:00:00Z", "amount": "10",
+			"earnings": [{"order_ref_id": "A", "type": "bonus", "amount": "10"}]}]}`,
+		"order": `{"version": 1, "withdrawals": [{"at": "2025-08-01T09:00:00Z", "amount": "10",
+			"earnings": [{"type": "order_fund", "amount": "10"}]}]}`,
+	}
+
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			importer := datasource.NewCustomWdJson(io.NopCloser(strings.NewReader(data)))
+			_, err := importer.GetRefIDs()
+			assert.NotNil(t, err)
+		})
+	}
+}
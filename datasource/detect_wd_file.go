@@ -0,0 +1,232 @@
+package datasource
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/xuri/excelize/v2"
+)
+
+type DetectFormat string
+
+const (
+	DetectXls  DetectFormat = "xls"
+	DetectCsv  DetectFormat = "csv"
+	DetectJson DetectFormat = "json"
+)
+
+// DetectParser importer yang bisa membaca file
+type DetectParser string
+
+const (
+	ParserShopeeWd    DetectParser = "shopee_wd"
+	ParserTiktokWdV1  DetectParser = "tiktok_wd_v1" // datasource.NewTiktokWdXls
+	ParserTiktokWdV2  DetectParser = "tiktok_wd_v2" // v2/datasource.NewTiktokWdXls
+	ParserLazadaWd    DetectParser = "lazada_wd"
+	ParserTokopediaWd DetectParser = "tokopedia_wd"
+	ParserMengantarWd DetectParser = "mengantar_wd"
+	ParserCustomWd    DetectParser = "custom_wd"
+)
+
+// export order details tiktok format baru, hanya terbaca benar oleh parser v2
+const tiktokV2MinOrderColumns = 58
+
+var ErrWdFileNotDetected = wd_error.New(wd_error.CodeFileInvalid, "format file withdrawal tidak dikenali", nil)
+
+type DetectResult struct {
+	Format DetectFormat
+	MpType db_models.OrderMpType
+	Parser DetectParser
+	// username shopee / json custom, rekening withdrawal tiktok, nama pengirim mengantar. kosong kalau file tidak punya
+	ShopIdentity string
+}
+
+// DetectWdFile mengenali marketplace dan format file withdrawal dari nama sheet, header dan kolom csv
+func DetectWdFile(data []byte) (*DetectResult, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return detectWdXls(data)
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		return detectWdJson(data)
+	default:
+		return detectWdCsv(data)
+	}
+}
+
+func detectWdXls(data []byte) (*DetectResult, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, wd_error.Wrap(wd_error.CodeFileInvalid, err, nil)
+	}
+	defer f.Close()
+
+	sheets := map[string]bool{}
+	for _, name := range f.GetSheetList() {
+		sheets[name] = true
+	}
+
+	switch {
+	case sheets["Transaction Report"]:
+		return detectShopeeWd(f)
+	case sheets["Order details"] && sheets["Withdrawal records"]:
+		return detectTiktokWd(f)
+	}
+
+	sheetList := f.GetSheetList()
+	if len(sheetList) == 0 {
+		return nil, ErrWdFileNotDetected
+	}
+
+	rows, err := f.GetRows(sheetList[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+
+		if strings.ToLower(strings.TrimSpace(row[0])) == lazadaColDate {
+			_, err = lazadaHeader(row)
+			if err == nil {
+				return &DetectResult{
+					Format: DetectXls,
+					MpType: db_models.OrderMpLazada,
+					Parser: ParserLazadaWd,
+				}, nil
+			}
+		}
+
+		if tokopediaHeader(row) != nil {
+			return &DetectResult{
+				Format: DetectXls,
+				MpType: db_models.OrderMpTokopedia,
+				Parser: ParserTokopediaWd,
+			}, nil
+		}
+	}
+
+	return nil, ErrWdFileNotDetected
+}
+
+func detectShopeeWd(f *excelize.File) (*DetectResult, error) {
+	rows, err := f.GetRows("Transaction Report")
+	if err != nil {
+		return nil, err
+	}
+
+	hasil := &DetectResult{
+		Format: DetectXls,
+		MpType: db_models.OrderMpShopee,
+		Parser: ParserShopeeWd,
+	}
+	for _, row := range rows {
+		if len(row) > 1 && row[0] == "Username (Penjual)" {
+			hasil.ShopIdentity = strings.TrimSpace(row[1])
+			break
+		}
+	}
+
+	return hasil, nil
+}
+
+func detectTiktokWd(f *excelize.File) (*DetectResult, error) {
+	hasil := &DetectResult{
+		Format: DetectXls,
+		MpType: db_models.OrderMpTiktok,
+		Parser: ParserTiktokWdV1,
+	}
+
+	orders, err := f.GetRows("Order details")
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) != 0 && len(orders[0]) >= tiktokV2MinOrderColumns {
+		hasil.Parser = ParserTiktokWdV2
+	}
+
+	records, err := f.GetRows("Withdrawal records")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range records {
+		if len(row) == 0 {
+			continue
+		}
+
+		switch strings.TrimSpace(row[0]) {
+		case "GMV Pay Deduction":
+			hasil.Parser = ParserTiktokWdV2
+		case string(TiktokWDWithdrawal):
+			// record paling atas withdrawal terbaru
+			if hasil.ShopIdentity == "" && len(row) > 6 {
+				hasil.ShopIdentity = strings.TrimSpace(row[6])
+			}
+		}
+	}
+
+	return hasil, nil
+}
+
+func detectWdCsv(data []byte) (*DetectResult, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, ErrWdFileNotDetected
+	}
+
+	header := map[string]int{}
+	for i, col := range records[0] {
+		col = strings.TrimPrefix(col, "\ufeff")
+		header[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+
+	for _, col := range []string{"description", "tracking id", "cod value"} {
+		if _, ok := header[col]; !ok {
+			return nil, ErrWdFileNotDetected
+		}
+	}
+
+	hasil := &DetectResult{
+		Format: DetectCsv,
+		MpType: db_models.OrderMengantar,
+		Parser: ParserMengantarWd,
+	}
+
+	sender, ok := header["sender name"]
+	if ok {
+		for _, row := range records[1:] {
+			if sender < len(row) && strings.TrimSpace(row[sender]) != "" {
+				hasil.ShopIdentity = strings.TrimSpace(row[sender])
+				break
+			}
+		}
+	}
+
+	return hasil, nil
+}
+
+func detectWdJson(data []byte) (*DetectResult, error) {
+	var file struct {
+		Version      int             `json:"version"`
+		ShopUsername string          `json:"shop_username"`
+		Withdrawals  json.RawMessage `json:"withdrawals"`
+	}
+	err := json.Unmarshal(data, &file)
+	if err != nil || file.Withdrawals == nil {
+		return nil, ErrWdFileNotDetected
+	}
+
+	return &DetectResult{
+		Format:       DetectJson,
+		MpType:       db_models.OrderMpCustom,
+		Parser:       ParserCustomWd,
+		ShopIdentity: file.ShopUsername,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package datasource_test
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectWdFile(t *testing.T) {
+	cases := []struct {
+		fname    string
+		mpType   db_models.OrderMpType
+		format   datasource.DetectFormat
+		parser   datasource.DetectParser
+		identity string
+	}{
+		{"../test/assets/shopee/luxy_wdgagal.xlsx", db_models.OrderMpShopee, datasource.DetectXls, datasource.ParserShopeeWd, "zafa.wear"},
+		{"../test/assets/testwd/tiktok14-3.xlsx", db_models.OrderMpTiktok, datasource.DetectXls, datasource.ParserTiktokWdV1, "********8533"},
+		{"../test/assets/tiktok/gmv_mlongo.xlsx", db_models.OrderMpTiktok, datasource.DetectXls, datasource.ParserTiktokWdV2, "********6762"},
+		{"../test/assets/mengantar/sample.csv", db_models.OrderMengantar, datasource.DetectCsv, datasource.ParserMengantarWd, "Kuki Outfit Pdc"},
+		{"../test/assets/custom/withdrawal.json", db_models.OrderMpCustom, datasource.DetectJson, datasource.ParserCustomWd, "tokoweb"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.fname, func(t *testing.T) {
+			data, err := os.ReadFile(c.fname)
+			assert.Nil(t, err)
+
+			detected, err := datasource.DetectWdFile(data)
+			assert.Nil(t, err)
+			assert.Equal(t, c.mpType, detected.MpType)
+			assert.Equal(t, c.format, detected.Format)
+			assert.Equal(t, c.parser, detected.Parser)
+			assert.Equal(t, c.identity, detected.ShopIdentity)
+		})
+	}
+
+	t.Run("statement lazada", func(t *testing.T) {
+		data, err := io.ReadAll(lazadaStatementFile(t, [][]interface{}{}))
+		assert.Nil(t, err)
+
+		detected, err := datasource.DetectWdFile(data)
+		assert.Nil(t, err)
+		assert.Equal(t, datasource.ParserLazadaWd, detected.Parser)
+	})
+
+	t.Run("export pengiriman bukan file withdrawal", func(t *testing.T) {
+		data, err := os.ReadFile("../test/assets/shopee_shipping.xlsx")
+		assert.Nil(t, err)
+
+		_, err = datasource.DetectWdFile(data)
+		assert.ErrorIs(t, err, datasource.ErrWdFileNotDetected)
+	})
+}
@@ -1,6 +1,6 @@
 package datasource
 
-import "errors"
+import "github.com/pdcgo/withdrawal_service/wd_error"
 
-var ErrContainInProcessWD = errors.New("ada withdrawal yang masih diproses, silahkan reimport kembali ketika withdrawalnya menjadi selesai")
-var ErrCannotGetMarketplaceUsername = errors.New("cannot get marketplace username")
+var ErrContainInProcessWD = wd_error.New(wd_error.CodeWdInProcess, "ada withdrawal yang masih diproses, silahkan reimport kembali ketika withdrawalnya menjadi selesai", nil)
+var ErrCannotGetMarketplaceUsername = wd_error.New(wd_error.CodeMpUsernameNotFound, "cannot get marketplace username", nil)
@@ -62,9 +62,7 @@ func (l *LazadaShippedXls) Iterate(ctx context.Context, handler func(orderRefID
 				return err
 			}
 
-			handler(item.OrderID)
-
-			return nil
+			return handler(item.OrderID)
 		})
 
 		if err != nil {
@@ -0,0 +1,409 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/xuri/excelize/v2"
+)
+
+// kolom account statement lazada (Finance > Account Statements > Export)
+const (
+	lazadaColDate      = "transaction date"
+	lazadaColTxType    = "transaction type"
+	lazadaColFeeName   = "fee name"
+	lazadaColAmount    = "amount"
+	lazadaColStatement = "statement"
+	lazadaColPaid      = "paid status"
+	lazadaColOrderNo   = "order no."
+)
+
+var lazadaDateLayouts = []string{
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"02 Jan 2006 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02/01/2006",
+}
+
+type LazadaWdItem struct {
+	TransactionDate time.Time
+	TxType          string
+	FeeName         string
+	Amount          money.Amount
+	Statement       string
+	Paid            bool
+	ExternalOrderID string
+}
+
+// AdjustmentType klasifikasi baris statement dari fee name, transaction type hanya kalau fee name kosong
+func (item *LazadaWdItem) AdjustmentType() db_models.AdjustmentType {
+	name := item.FeeName
+	if name == "" {
+		name = item.TxType
+	}
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.Contains(name, "payout"), strings.Contains(name, "transfer to bank"):
+		return db_models.AdjFund
+	case strings.Contains(name, "reversal"), strings.Contains(name, "refund"):
+		return db_models.AdjReturn
+	case strings.Contains(name, "lost"), strings.Contains(name, "damaged"):
+		return db_models.AdjLostCompensation
+	case strings.Contains(name, "compensation"), strings.Contains(name, "claim"):
+		return db_models.AdjCompensation
+	case strings.Contains(name, "item price"), strings.Contains(name, "paid by customer"):
+		return db_models.AdjOrderFund
+	case strings.Contains(name, "shipping"):
+		return db_models.AdjShipping
+	case strings.Contains(name, "commission"), strings.Contains(name, "fee"), strings.Contains(name, "charge"):
+		return db_models.AdjCommision
+	}
+
+	if item.ExternalOrderID != "" {
+		return db_models.AdjUnknownAdj
+	}
+	return db_models.AdjUnknown
+}
+
+type lazadaStatement struct {
+	name   string
+	at     time.Time
+	payout *db_models.InvoItem
+	items  []*db_models.InvoItem
+}
+
+func (s *lazadaStatement) amount() money.Amount {
+	var hasil money.Amount
+	for _, item := range s.items {
+		hasil += money.FromFloat(item.Amount)
+	}
+	return hasil
+}
+
+type LazadaWdXls struct {
+	reader io.ReadCloser
+	rows   []*LazadaWdItem
+}
+
+// GetShopUsername implements order_api.WdImporterIterate.
+func (l *LazadaWdXls) GetShopUsername() (string, error) {
+	return "", ErrCannotGetMarketplaceUsername
+}
+
+// GetRefIDs implements order_api.WdImporterIterate.
+func (l *LazadaWdXls) GetRefIDs() (OrderRefList, error) {
+	hasil := OrderRefList{}
+	rows, err := l.getRows()
+	if err != nil {
+		return hasil, err
+	}
+
+	maper := map[string]bool{}
+	for _, row := range rows {
+		if !row.Paid || maper[row.ExternalOrderID] {
+			continue
+		}
+		maper[row.ExternalOrderID] = true
+		hasil.Add(row.ExternalOrderID)
+	}
+
+	return hasil, nil
+}
+
+// Iterate implements order_api.WdImporterIterate.
+// lazada tidak punya baris withdrawal per statement, payout dibuat dari total statement yang sudah dibayar.
+// urutan dari statement terbaru seperti file shopee, payout dulu lalu earning nya
+func (l *LazadaWdXls) Iterate(ctx context.Context, handler func(item *db_models.InvoItem) error) error {
+	statements, err := l.statements()
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range statements {
+		if stat.payout != nil {
+			err = handler(stat.payout)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, item := range stat.items {
+			err = handler(item)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// statements baris yang sudah dibayar digabung per statement, satu order satu item per tipe adjustment
+func (l *LazadaWdXls) statements() ([]*lazadaStatement, error) {
+	rows, err := l.getRows()
+	if err != nil {
+		return nil, err
+	}
+
+	type itemKey struct {
+		orderID string
+		tipe    db_models.AdjustmentType
+	}
+
+	statMap := map[string]*lazadaStatement{}
+	itemMap := map[string]map[itemKey]*db_models.InvoItem{}
+	statements := []*lazadaStatement{}
+
+	for _, row := range rows {
+		if !row.Paid {
+			continue
+		}
+
+		stat := statMap[row.Statement]
+		if stat == nil {
+			stat = &lazadaStatement{name: row.Statement}
+			statMap[row.Statement] = stat
+			itemMap[row.Statement] = map[itemKey]*db_models.InvoItem{}
+			statements = append(statements, stat)
+		}
+		if row.TransactionDate.After(stat.at) {
+			stat.at = row.TransactionDate
+		}
+
+		tipe := row.AdjustmentType()
+		if tipe == db_models.AdjFund {
+			stat.payout = &db_models.InvoItem{
+				MpFrom:          db_models.OrderMpLazada,
+				Type:            db_models.AdjFund,
+				TransactionDate: row.TransactionDate,
+				Description:     row.FeeName,
+				Amount:          (-row.Amount.Abs()).Float64(),
+			}
+			continue
+		}
+
+		// baris tanpa order tidak digabung
+		if row.ExternalOrderID == "" {
+			stat.items = append(stat.items, l.invoItem(row, tipe))
+			continue
+		}
+
+		key := itemKey{row.ExternalOrderID, tipe}
+		item := itemMap[row.Statement][key]
+		if item == nil {
+			item = l.invoItem(row, tipe)
+			itemMap[row.Statement][key] = item
+			stat.items = append(stat.items, item)
+			continue
+		}
+
+		item.Amount = (money.FromFloat(item.Amount) + row.Amount).Float64()
+		if row.TransactionDate.After(item.TransactionDate) {
+			item.TransactionDate = row.TransactionDate
+		}
+		if !strings.Contains(item.Description, row.FeeName) {
+			item.Description = fmt.Sprintf("%s, %s", item.Description, row.FeeName)
+		}
+	}
+
+	// saldo statement minus dipotong dari payout statement berikutnya
+	sort.SliceStable(statements, func(i, j int) bool {
+		return statements[i].at.Before(statements[j].at)
+	})
+
+	var carry money.Amount
+	for _, stat := range statements {
+		if stat.payout != nil {
+			carry = 0
+			continue
+		}
+
+		amount := stat.amount() + carry
+		if amount <= 0 {
+			carry = amount
+			continue
+		}
+
+		carry = 0
+		stat.payout = &db_models.InvoItem{
+			MpFrom:          db_models.OrderMpLazada,
+			Type:            db_models.AdjFund,
+			TransactionDate: stat.at,
+			Description:     fmt.Sprintf("payout statement %s", stat.name),
+			Amount:          (-amount).Float64(),
+		}
+	}
+
+	// terbaru dulu
+	sort.SliceStable(statements, func(i, j int) bool {
+		return statements[i].at.After(statements[j].at)
+	})
+	for _, stat := range statements {
+		sort.SliceStable(stat.items, func(i, j int) bool {
+			return stat.items[i].TransactionDate.After(stat.items[j].TransactionDate)
+		})
+	}
+
+	return statements, nil
+}
+
+func (l *LazadaWdXls) invoItem(row *LazadaWdItem, tipe db_models.AdjustmentType) *db_models.InvoItem {
+	return &db_models.InvoItem{
+		MpFrom:          db_models.OrderMpLazada,
+		ExternalOrderID: row.ExternalOrderID,
+		Type:            tipe,
+		TransactionDate: row.TransactionDate,
+		Description:     row.FeeName,
+		Amount:          row.Amount.Float64(),
+	}
+}
+
+func (l *LazadaWdXls) getRows() ([]*LazadaWdItem, error) {
+	if l.rows != nil {
+		return l.rows, nil
+	}
+
+	f, err := excelize.OpenReader(l.reader)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, wd_error.New(wd_error.CodeFileInvalid, "file statement lazada kosong", nil)
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hasil := []*LazadaWdItem{}
+	var header map[string]int
+
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+
+		if header == nil {
+			if strings.ToLower(strings.TrimSpace(row[0])) != lazadaColDate {
+				continue
+			}
+
+			header, err = lazadaHeader(row)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		item, err := lazadaParseRow(header, row)
+		if err != nil {
+			return nil, err
+		}
+		hasil = append(hasil, item)
+	}
+
+	if header == nil {
+		return nil, wd_error.New(wd_error.CodeFileInvalid, "header statement lazada tidak ditemukan", nil)
+	}
+
+	l.rows = hasil
+	return hasil, nil
+}
+
+func lazadaHeader(row []string) (map[string]int, error) {
+	header := map[string]int{}
+	for i, col := range row {
+		col = strings.ToLower(strings.TrimSpace(col))
+		// versi lama pakai "Amount(Include Tax)"
+		if strings.HasPrefix(col, lazadaColAmount) {
+			col = lazadaColAmount
+		}
+		if _, ok := header[col]; !ok {
+			header[col] = i
+		}
+	}
+
+	for _, col := range []string{lazadaColDate, lazadaColFeeName, lazadaColAmount, lazadaColStatement, lazadaColPaid, lazadaColOrderNo} {
+		if _, ok := header[col]; !ok {
+			return header, wd_error.New(
+				wd_error.CodeFileInvalid,
+				fmt.Sprintf("kolom %s tidak ada di statement lazada", col),
+				wd_error.Params{"column": col},
+			)
+		}
+	}
+
+	return header, nil
+}
+
+func lazadaParseRow(header map[string]int, row []string) (*LazadaWdItem, error) {
+	get := func(col string) string {
+		i, ok := header[col]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	at, err := lazadaParseDate(get(lazadaColDate))
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := money.Parse(strings.ReplaceAll(get(lazadaColAmount), ",", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := get(lazadaColOrderNo)
+	if orderID == "-" {
+		orderID = ""
+	}
+
+	return &LazadaWdItem{
+		TransactionDate: at,
+		TxType:          get(lazadaColTxType),
+		FeeName:         get(lazadaColFeeName),
+		Amount:          amount,
+		Statement:       get(lazadaColStatement),
+		Paid:            strings.EqualFold(get(lazadaColPaid), "paid"),
+		ExternalOrderID: orderID,
+	}, nil
+}
+
+func lazadaParseDate(data string) (time.Time, error) {
+	var err error
+	var at time.Time
+	for _, layout := range lazadaDateLayouts {
+		at, err = time.ParseInLocation(layout, data, time.Local)
+		if err == nil {
+			return at, nil
+		}
+	}
+
+	return at, wd_error.New(
+		wd_error.CodeFileInvalid,
+		fmt.Sprintf("tanggal %s di statement lazada tidak dikenali", data),
+		wd_error.Params{"date": data},
+	)
+}
+
+func NewLazadaWdXls(reader io.ReadCloser) *LazadaWdXls {
+	return &LazadaWdXls{
+		reader: reader,
+	}
+}
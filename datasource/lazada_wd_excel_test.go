@@ -0,0 +1,143 @@
+package datasource_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func lazadaStatementFile(t *testing.T, rows [][]interface{}) io.ReadCloser {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	header := []interface{}{"Transaction Date", "Transaction Type", "Fee Name", "Transaction Number", "Details", "Amount", "Statement", "Paid Status", "Order No."}
+	err := f.SetSheetRow(sheet, "A1", &header)
+	assert.Nil(t, err)
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		assert.Nil(t, err)
+		err = f.SetSheetRow(sheet, cell, &row)
+		assert.Nil(t, err)
+	}
+
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+	return io.NopCloser(bytes.NewReader(buf.Bytes()))
+}
+
+func TestIterateLazadaStatement(t *testing.T) {
+	file := lazadaStatementFile(t, [][]interface{}{
+		{"01 Aug 2025", "Orders-Sales", "Item Price Credit", "T1", "", "100000", "01 Aug 2025 - 07 Aug 2025", "Paid", "LZ001"},
+		{"01 Aug 2025", "Orders-Sales", "Item Price Credit", "T2", "", "50000", "01 Aug 2025 - 07 Aug 2025", "Paid", "LZ001"},
+		{"01 Aug 2025", "Orders-Lazada Fees", "Commission", "T3", "", "-7500", "01 Aug 2025 - 07 Aug 2025", "Paid", "LZ001"},
+		{"02 Aug 2025", "Orders-Lazada Fees", "Payment Fee", "T4", "", "-1500.50", "01 Aug 2025 - 07 Aug 2025", "Paid", "LZ001"},
+		{"03 Aug 2025", "Refunds-Claims", "Reversal Item Price", "T5", "", "-20000", "01 Aug 2025 - 07 Aug 2025", "Paid", "LZ002"},
+		{"03 Aug 2025", "Orders-Sales", "Item Price Credit", "T6", "", "60000", "01 Aug 2025 - 07 Aug 2025", "Paid", "LZ002"},
+		{"09 Aug 2025", "Orders-Sales", "Item Price Credit", "T7", "", "30000", "08 Aug 2025 - 14 Aug 2025", "Paid", "LZ003"},
+		{"10 Aug 2025", "Orders-Logistics", "Shipping Fee Paid by Seller", "T8", "", "-5000", "08 Aug 2025 - 14 Aug 2025", "Paid", "LZ003"},
+		{"16 Aug 2025", "Orders-Sales", "Item Price Credit", "T9", "", "45000", "15 Aug 2025 - 21 Aug 2025", "Not paid", "LZ004"},
+	})
+
+	importer := datasource.NewLazadaWdXls(file)
+	refIDs, err := importer.GetRefIDs()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, datasource.OrderRefList{"LZ001", "LZ002", "LZ003"}, refIDs)
+
+	items := []*db_models.InvoItem{}
+	err = importer.Iterate(context.Background(), func(item *db_models.InvoItem) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// statement terbaru dulu, payout lalu earning nya
+	assert.Equal(t, db_models.AdjFund, items[0].Type)
+	assert.Equal(t, -25000.0, items[0].Amount)
+
+	funds := []float64{}
+	types := map[string]float64{}
+	for _, item := range items {
+		if item.Type == db_models.AdjFund {
+			funds = append(funds, item.Amount)
+			continue
+		}
+		types[item.ExternalOrderID+":"+string(item.Type)] += item.Amount
+	}
+
+	assert.Equal(t, []float64{-25000, -180999.5}, funds)
+	assert.Equal(t, 150000.0, types["LZ001:"+string(db_models.AdjOrderFund)])
+	assert.Equal(t, -9000.5, types["LZ001:"+string(db_models.AdjCommision)])
+	assert.Equal(t, -20000.0, types["LZ002:"+string(db_models.AdjReturn)])
+	assert.Equal(t, -5000.0, types["LZ003:"+string(db_models.AdjShipping)])
+	assert.NotContains(t, types, "LZ004:"+string(db_models.AdjOrderFund))
+}
+
+func TestIterateLazadaStatementAsset(t *testing.T) {
+	// export transaction overview seller center, nomor order dan sku disamarkan
+	file, err := os.Open("../test/assets/lazada/statement_anonymized.xlsx")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	importer := datasource.NewLazadaWdXls(file)
+	refIDs, err := importer.GetRefIDs()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, datasource.OrderRefList{
+		"2847561930214587",
+		"2847562218830174",
+		"2847559014427739",
+		"2851130076692215",
+		"2849902217348801",
+	}, refIDs)
+
+	funds := []float64{}
+	types := map[string]float64{}
+	err = importer.Iterate(context.Background(), func(item *db_models.InvoItem) error {
+		if item.Type == db_models.AdjFund {
+			funds = append(funds, item.Amount)
+			return nil
+		}
+		types[item.ExternalOrderID+":"+string(item.Type)] += item.Amount
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// statement belum dibayar tidak jadi withdrawal
+	assert.Equal(t, []float64{-215249.5, -123115}, funds)
+
+	assert.Equal(t, 134000.0, types["2847561930214587:"+string(db_models.AdjOrderFund)])
+	assert.Equal(t, -8125.0, types["2847561930214587:"+string(db_models.AdjCommision)])
+	assert.Equal(t, -5000.0, types["2847561930214587:"+string(db_models.AdjShipping)])
+	assert.Equal(t, 101000.0, types["2847562218830174:"+string(db_models.AdjOrderFund)])
+	assert.Equal(t, -5785.0, types["2847562218830174:"+string(db_models.AdjCommision)])
+	assert.Equal(t, -42975.0, types["2847559014427739:"+string(db_models.AdjReturn)])
+	assert.Equal(t, -50000.0, types[":"+string(db_models.AdjUnknown)])
+	assert.Equal(t, 150000.0, types["2851130076692215:"+string(db_models.AdjOrderFund)])
+	assert.Equal(t, -9750.5, types["2851130076692215:"+string(db_models.AdjCommision)])
+	assert.Equal(t, 75000.0, types["2849902217348801:"+string(db_models.AdjLostCompensation)])
+	assert.NotContains(t, types, "2853301184420967:"+string(db_models.AdjOrderFund))
+}
+
+func TestLazadaStatementMissingColumn(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	header := []interface{}{"Transaction Date", "Fee Name", "Amount"}
+	err := f.SetSheetRow(f.GetSheetName(0), "A1", &header)
+	assert.Nil(t, err)
+
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+
+	importer := datasource.NewLazadaWdXls(io.NopCloser(bytes.NewReader(buf.Bytes())))
+	_, err = importer.GetRefIDs()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,554 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/xuri/excelize/v2"
+)
+
+type MappedFormat string
+
+const (
+	MappedCsv MappedFormat = "csv"
+	MappedXls MappedFormat = "xls"
+)
+
+// MappingRule regex dicocokkan ke isi satu kolom, tidak case sensitive
+type MappingRule struct {
+	Column  string `json:"column"`
+	Pattern string `json:"pattern"`
+}
+
+// MappingTypeRule isi kolom type yang cocok dengan pattern dipetakan ke type earning
+// (nama type sama dengan file json custom, misal order_fund, commission, shipping)
+type MappingTypeRule struct {
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`
+}
+
+// ColumnMapping konfigurasi import csv / xlsx partner cod dan logistik yang formatnya tidak dikenal.
+// kolom disebut dengan nama header, baris header dicari otomatis.
+// kalau PayoutRules kosong setiap earning dianggap langsung dicairkan (seperti mengantar),
+// kalau ada, baris yang cocok jadi withdrawal dan mendanai earning sebelumnya.
+type ColumnMapping struct {
+	DateColumn        string            `json:"date_column"`
+	DateLayout        string            `json:"date_layout"` // layout go, misal 02 Jan 2006 15:04
+	OrderRefColumn    string            `json:"order_ref_column"`
+	OrderRefRegex     string            `json:"order_ref_regex"` // opsional, pakai group pertama kalau ada
+	AmountColumn      string            `json:"amount_column"`
+	TypeColumn        string            `json:"type_column"`
+	DescriptionColumn string            `json:"description_column"`
+	Delimiter         string            `json:"delimiter"` // csv, default koma
+	TypeRules         []MappingTypeRule `json:"type_rules"`
+	DefaultType       string            `json:"default_type"` // default order_fund
+	PayoutRules       []MappingRule     `json:"payout_rules"`
+	SkipRules         []MappingRule     `json:"skip_rules"`
+}
+
+func (m *ColumnMapping) Validate() error {
+	required := map[string]string{
+		"date_column":      m.DateColumn,
+		"date_layout":      m.DateLayout,
+		"order_ref_column": m.OrderRefColumn,
+		"amount_column":    m.AmountColumn,
+	}
+	for field, value := range required {
+		if strings.TrimSpace(value) == "" {
+			return mappingInvalid(field, fmt.Sprintf("%s wajib diisi", field))
+		}
+	}
+
+	if utf8.RuneCountInString(m.Delimiter) > 1 {
+		return mappingInvalid("delimiter", "delimiter harus satu karakter")
+	}
+
+	if m.OrderRefRegex != "" {
+		_, err := regexp.Compile(m.OrderRefRegex)
+		if err != nil {
+			return mappingInvalid("order_ref_regex", err.Error())
+		}
+	}
+
+	if m.DefaultType != "" {
+		if _, ok := customEarningTypes[m.DefaultType]; !ok {
+			return mappingInvalid("default_type", fmt.Sprintf("type %s tidak dikenali", m.DefaultType))
+		}
+	}
+
+	for i, rule := range m.TypeRules {
+		if m.TypeColumn == "" {
+			return mappingInvalid("type_column", "type_column wajib diisi kalau ada type_rules")
+		}
+		if _, ok := customEarningTypes[rule.Type]; !ok {
+			return mappingInvalid(fmt.Sprintf("type_rules[%d].type", i), fmt.Sprintf("type %s tidak dikenali", rule.Type))
+		}
+		_, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return mappingInvalid(fmt.Sprintf("type_rules[%d].pattern", i), err.Error())
+		}
+	}
+
+	for name, rules := range map[string][]MappingRule{"payout_rules": m.PayoutRules, "skip_rules": m.SkipRules} {
+		for i, rule := range rules {
+			if rule.Column == "" {
+				return mappingInvalid(fmt.Sprintf("%s[%d].column", name, i), "column wajib diisi")
+			}
+			_, err := regexp.Compile("(?i)" + rule.Pattern)
+			if err != nil {
+				return mappingInvalid(fmt.Sprintf("%s[%d].pattern", name, i), err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *ColumnMapping) columns() []string {
+	cols := []string{m.DateColumn, m.OrderRefColumn, m.AmountColumn}
+	if m.TypeColumn != "" {
+		cols = append(cols, m.TypeColumn)
+	}
+	if m.DescriptionColumn != "" {
+		cols = append(cols, m.DescriptionColumn)
+	}
+	for _, rule := range append(append([]MappingRule{}, m.PayoutRules...), m.SkipRules...) {
+		cols = append(cols, rule.Column)
+	}
+	return cols
+}
+
+type mappedRule struct {
+	column string
+	regex  *regexp.Regexp
+}
+
+func (r *mappedRule) match(row mappedRow) bool {
+	return r.regex.MatchString(row.get(r.column))
+}
+
+type mappedTypeRule struct {
+	regex *regexp.Regexp
+	tipe  db_models.AdjustmentType
+}
+
+type mappedRow struct {
+	header map[string]int
+	cells  []string
+}
+
+func (r mappedRow) get(col string) string {
+	i, ok := r.header[mappedKey(col)]
+	if !ok || i >= len(r.cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.cells[i])
+}
+
+type mappedSet struct {
+	fund  *db_models.InvoItem
+	items []*db_models.InvoItem
+}
+
+type MappedWd struct {
+	reader  io.ReadCloser
+	format  MappedFormat
+	mpFrom  db_models.OrderMpType
+	mapping *ColumnMapping
+
+	refRegex    *regexp.Regexp
+	typeRules   []*mappedTypeRule
+	payoutRules []*mappedRule
+	skipRules   []*mappedRule
+	sets        []*mappedSet
+}
+
+// GetShopUsername implements order_api.WdImporterIterate.
+func (m *MappedWd) GetShopUsername() (string, error) {
+	return "", ErrCannotGetMarketplaceUsername
+}
+
+// GetRefIDs implements order_api.WdImporterIterate.
+func (m *MappedWd) GetRefIDs() (OrderRefList, error) {
+	hasil := OrderRefList{}
+	sets, err := m.getSets()
+	if err != nil {
+		return hasil, err
+	}
+
+	maper := map[string]bool{}
+	for _, set := range sets {
+		for _, item := range set.items {
+			if item.ExternalOrderID == "" || maper[item.ExternalOrderID] {
+				continue
+			}
+			maper[item.ExternalOrderID] = true
+			hasil.Add(item.ExternalOrderID)
+		}
+	}
+
+	return hasil, nil
+}
+
+// Iterate implements order_api.WdImporterIterate.
+// withdrawal terbaru dulu lalu earning yang didanainya, urutan baris di file tidak berpengaruh
+func (m *MappedWd) Iterate(ctx context.Context, handler func(item *db_models.InvoItem) error) error {
+	sets, err := m.getSets()
+	if err != nil {
+		return err
+	}
+
+	for _, set := range sets {
+		err = handler(set.fund)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range set.items {
+			err = handler(item)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MappedWd) getSets() ([]*mappedSet, error) {
+	if m.sets != nil {
+		return m.sets, nil
+	}
+
+	err := m.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.getRows()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*db_models.InvoItem{}
+	for _, row := range rows {
+		item, err := m.parseRow(row)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	// dari yang terlama, earning dikumpulkan sampai ketemu payout
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].TransactionDate.Before(items[j].TransactionDate)
+	})
+
+	type itemKey struct {
+		orderID string
+		tipe    db_models.AdjustmentType
+	}
+
+	sets := []*mappedSet{}
+	current := &mappedSet{}
+	itemMap := map[itemKey]*db_models.InvoItem{}
+
+	for _, item := range items {
+		if item.Type == db_models.AdjFund {
+			current.fund = item
+			sets = append(sets, current)
+			current = &mappedSet{}
+			itemMap = map[itemKey]*db_models.InvoItem{}
+			continue
+		}
+
+		if len(m.payoutRules) == 0 {
+			sets = append(sets, &mappedSet{
+				fund: &db_models.InvoItem{
+					MpFrom:          m.mpFrom,
+					Type:            db_models.AdjFund,
+					TransactionDate: item.TransactionDate,
+					Amount:          (-money.FromFloat(item.Amount)).Float64(),
+				},
+				items: []*db_models.InvoItem{item},
+			})
+			continue
+		}
+
+		if item.ExternalOrderID == "" {
+			current.items = append(current.items, item)
+			continue
+		}
+
+		key := itemKey{item.ExternalOrderID, item.Type}
+		prev := itemMap[key]
+		if prev == nil {
+			itemMap[key] = item
+			current.items = append(current.items, item)
+			continue
+		}
+
+		prev.Amount = (money.FromFloat(prev.Amount) + money.FromFloat(item.Amount)).Float64()
+		prev.TransactionDate = item.TransactionDate
+		if item.Description != "" && !strings.Contains(prev.Description, item.Description) {
+			prev.Description = fmt.Sprintf("%s, %s", prev.Description, item.Description)
+		}
+	}
+	// earning setelah payout terakhir belum dicairkan, tidak diproses
+
+	for i, j := 0, len(sets)-1; i < j; i, j = i+1, j-1 {
+		sets[i], sets[j] = sets[j], sets[i]
+	}
+	for _, set := range sets {
+		sort.SliceStable(set.items, func(i, j int) bool {
+			return set.items[i].TransactionDate.After(set.items[j].TransactionDate)
+		})
+	}
+
+	m.sets = sets
+	return sets, nil
+}
+
+// parseRow return nil untuk baris yang dilewati
+func (m *MappedWd) parseRow(row mappedRow) (*db_models.InvoItem, error) {
+	date := row.get(m.mapping.DateColumn)
+	if date == "" {
+		return nil, nil
+	}
+
+	for _, rule := range m.skipRules {
+		if rule.match(row) {
+			return nil, nil
+		}
+	}
+
+	at, err := time.ParseInLocation(m.mapping.DateLayout, date, time.Local)
+	if err != nil {
+		return nil, wd_error.New(
+			wd_error.CodeFileInvalid,
+			fmt.Sprintf("tanggal %s tidak sesuai format %s", date, m.mapping.DateLayout),
+			wd_error.Params{"date": date, "layout": m.mapping.DateLayout},
+		)
+	}
+
+	rawAmount := row.get(m.mapping.AmountColumn)
+	amount, err := tokopediaParseAmount(rawAmount)
+	if err != nil {
+		return nil, wd_error.New(
+			wd_error.CodeFileInvalid,
+			fmt.Sprintf("nominal %s tidak valid", rawAmount),
+			wd_error.Params{"amount": rawAmount},
+		)
+	}
+
+	desc := row.get(m.mapping.OrderRefColumn)
+	if m.mapping.DescriptionColumn != "" {
+		desc = row.get(m.mapping.DescriptionColumn)
+	}
+
+	for _, rule := range m.payoutRules {
+		if rule.match(row) {
+			return &db_models.InvoItem{
+				MpFrom:          m.mpFrom,
+				Type:            db_models.AdjFund,
+				TransactionDate: at,
+				Description:     desc,
+				Amount:          (-amount.Abs()).Float64(),
+			}, nil
+		}
+	}
+
+	return &db_models.InvoItem{
+		MpFrom:          m.mpFrom,
+		ExternalOrderID: m.orderRef(row.get(m.mapping.OrderRefColumn)),
+		Type:            m.adjustmentType(row),
+		TransactionDate: at,
+		Description:     desc,
+		Amount:          amount.Float64(),
+	}, nil
+}
+
+func (m *MappedWd) orderRef(data string) string {
+	if m.refRegex == nil {
+		return data
+	}
+
+	match := m.refRegex.FindStringSubmatch(data)
+	switch len(match) {
+	case 0:
+		return ""
+	case 1:
+		return match[0]
+	default:
+		return match[1]
+	}
+}
+
+func (m *MappedWd) adjustmentType(row mappedRow) db_models.AdjustmentType {
+	if m.mapping.TypeColumn != "" {
+		value := row.get(m.mapping.TypeColumn)
+		for _, rule := range m.typeRules {
+			if rule.regex.MatchString(value) {
+				return rule.tipe
+			}
+		}
+	}
+
+	if m.mapping.DefaultType != "" {
+		return customEarningTypes[m.mapping.DefaultType]
+	}
+	return db_models.AdjOrderFund
+}
+
+func (m *MappedWd) compile() error {
+	err := m.mapping.Validate()
+	if err != nil {
+		return err
+	}
+
+	if m.mapping.OrderRefRegex != "" {
+		m.refRegex = regexp.MustCompile(m.mapping.OrderRefRegex)
+	}
+
+	m.typeRules = make([]*mappedTypeRule, len(m.mapping.TypeRules))
+	for i, rule := range m.mapping.TypeRules {
+		m.typeRules[i] = &mappedTypeRule{
+			regex: regexp.MustCompile("(?i)" + rule.Pattern),
+			tipe:  customEarningTypes[rule.Type],
+		}
+	}
+
+	compileRules := func(rules []MappingRule) []*mappedRule {
+		hasil := make([]*mappedRule, len(rules))
+		for i, rule := range rules {
+			hasil[i] = &mappedRule{
+				column: rule.Column,
+				regex:  regexp.MustCompile("(?i)" + rule.Pattern),
+			}
+		}
+		return hasil
+	}
+	m.payoutRules = compileRules(m.mapping.PayoutRules)
+	m.skipRules = compileRules(m.mapping.SkipRules)
+
+	return nil
+}
+
+// getRows baris setelah header, header adalah baris pertama yang punya semua kolom mapping
+func (m *MappedWd) getRows() ([]mappedRow, error) {
+	records, err := m.records()
+	if err != nil {
+		return nil, err
+	}
+
+	var header map[string]int
+	hasil := []mappedRow{}
+	for _, record := range records {
+		if header == nil {
+			header = m.header(record)
+			continue
+		}
+		hasil = append(hasil, mappedRow{header: header, cells: record})
+	}
+
+	if header == nil {
+		return nil, wd_error.New(
+			wd_error.CodeFileInvalid,
+			"header sesuai mapping kolom tidak ditemukan",
+			wd_error.Params{"columns": strings.Join(m.mapping.columns(), ", ")},
+		)
+	}
+
+	return hasil, nil
+}
+
+func (m *MappedWd) header(record []string) map[string]int {
+	header := map[string]int{}
+	for i, col := range record {
+		key := mappedKey(col)
+		if _, ok := header[key]; !ok {
+			header[key] = i
+		}
+	}
+
+	for _, col := range m.mapping.columns() {
+		if _, ok := header[mappedKey(col)]; !ok {
+			return nil
+		}
+	}
+	return header
+}
+
+func (m *MappedWd) records() ([][]string, error) {
+	switch m.format {
+	case MappedXls:
+		f, err := excelize.OpenReader(m.reader)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, wd_error.New(wd_error.CodeFileInvalid, "file xlsx kosong", nil)
+		}
+		return f.GetRows(sheets[0])
+
+	default:
+		data, err := io.ReadAll(m.reader)
+		if err != nil {
+			return nil, err
+		}
+
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		if m.mapping.Delimiter != "" {
+			reader.Comma, _ = utf8.DecodeRuneInString(m.mapping.Delimiter)
+		}
+
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, wd_error.New(
+				wd_error.CodeFileInvalid,
+				fmt.Sprintf("file csv tidak valid: %s", err.Error()),
+				wd_error.Params{"error": err.Error()},
+			)
+		}
+		return records, nil
+	}
+}
+
+func mappedKey(col string) string {
+	return strings.ToLower(strings.TrimSpace(col))
+}
+
+func mappingInvalid(field string, msg string) error {
+	return wd_error.New(
+		wd_error.CodeMappingInvalid,
+		fmt.Sprintf("mapping kolom tidak valid, %s", msg),
+		wd_error.Params{"field": field},
+	)
+}
+
+func NewMappedWd(reader io.ReadCloser, format MappedFormat, mpFrom db_models.OrderMpType, mapping *ColumnMapping) *MappedWd {
+	return &MappedWd{
+		reader:  reader,
+		format:  format,
+		mpFrom:  mpFrom,
+		mapping: mapping,
+	}
+}
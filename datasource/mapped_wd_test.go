@@ -0,0 +1,142 @@
+package datasource_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+func iterateMapped(t *testing.T, importer *datasource.MappedWd) []*db_models.InvoItem {
+	items := []*db_models.InvoItem{}
+	err := importer.Iterate(context.Background(), func(item *db_models.InvoItem) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.Nil(t, err)
+	return items
+}
+
+func TestMappedWdPayoutRows(t *testing.T) {
+	data := strings.Join([]string{
+		"Laporan COD Partner X",
+		"Tanggal;Keterangan;Jenis;Nominal",
+		"01/08/2025;COD resi JNT001;cod;100.000",
+		"01/08/2025;Biaya COD resi JNT001;fee;-3.000",
+		"01/08/2025;Biaya asuransi resi JNT001;fee;-500",
+		"02/08/2025;COD resi JNT002;cod;50.000,50",
+		"03/08/2025;Transfer ke rekening;payout;146.500,50",
+		"04/08/2025;Ongkir retur resi JNT003;retur;-12.000",
+		"05/08/2025;COD resi JNT004;cod;40.000",
+		"06/08/2025;Transfer ke rekening;payout;28.000",
+		"07/08/2025;COD resi JNT005;cod;10.000",
+		"07/08/2025;Saldo awal;saldo;0",
+	}, "\n")
+
+	importer := datasource.NewMappedWd(
+		io.NopCloser(strings.NewReader(data)),
+		datasource.MappedCsv,
+		db_models.OrderMengantar,
+		&datasource.ColumnMapping{
+			DateColumn:     "tanggal",
+			DateLayout:     "02/01/2006",
+			OrderRefColumn: "Keterangan",
+			OrderRefRegex:  `resi ([A-Z0-9]+)`,
+			AmountColumn:   "Nominal",
+			TypeColumn:     "Jenis",
+			Delimiter:      ";",
+			TypeRules: []datasource.MappingTypeRule{
+				{Pattern: "^fee$", Type: "commission"},
+				{Pattern: "retur", Type: "return"},
+			},
+			PayoutRules: []datasource.MappingRule{{Column: "Jenis", Pattern: "^payout$"}},
+			SkipRules:   []datasource.MappingRule{{Column: "Jenis", Pattern: "saldo"}},
+		},
+	)
+
+	refIDs, err := importer.GetRefIDs()
+	assert.Nil(t, err)
+	// JNT005 belum dicairkan
+	assert.ElementsMatch(t, datasource.OrderRefList{"JNT001", "JNT002", "JNT003", "JNT004"}, refIDs)
+
+	items := iterateMapped(t, importer)
+	assert.Len(t, items, 7)
+
+	assert.Equal(t, db_models.AdjFund, items[0].Type)
+	assert.Equal(t, -28000.0, items[0].Amount)
+	assert.Equal(t, "JNT004", items[1].ExternalOrderID)
+	assert.Equal(t, db_models.AdjReturn, items[2].Type)
+	assert.Equal(t, -12000.0, items[2].Amount)
+
+	assert.Equal(t, db_models.AdjFund, items[3].Type)
+	assert.Equal(t, -146500.5, items[3].Amount)
+	assert.Equal(t, 50000.5, items[4].Amount)
+
+	// dua biaya satu resi digabung
+	commision := items[5]
+	if commision.Type != db_models.AdjCommision {
+		commision = items[6]
+	}
+	assert.Equal(t, db_models.AdjCommision, commision.Type)
+	assert.Equal(t, "JNT001", commision.ExternalOrderID)
+	assert.Equal(t, -3500.0, commision.Amount)
+	assert.Equal(t, db_models.OrderMengantar, commision.MpFrom)
+}
+
+func TestMappedWdEachRowPaid(t *testing.T) {
+	data := strings.Join([]string{
+		"No,Tanggal,Order,Total",
+		"1,01 Aug 2025 10:00,Revenue from order ID MGT01,\"15,000\"",
+		"2,02 Aug 2025 10:00,Revenue from order ID MGT02,20000",
+	}, "\n")
+
+	importer := datasource.NewMappedWd(
+		io.NopCloser(strings.NewReader(data)),
+		datasource.MappedCsv,
+		db_models.OrderMengantar,
+		&datasource.ColumnMapping{
+			DateColumn:     "Tanggal",
+			DateLayout:     "02 Jan 2006 15:04",
+			OrderRefColumn: "Order",
+			OrderRefRegex:  `order ID (\S+)`,
+			AmountColumn:   "Total",
+		},
+	)
+
+	items := iterateMapped(t, importer)
+	assert.Len(t, items, 4)
+	assert.Equal(t, db_models.AdjFund, items[0].Type)
+	assert.Equal(t, -20000.0, items[0].Amount)
+	assert.Equal(t, "MGT02", items[1].ExternalOrderID)
+	assert.Equal(t, db_models.AdjOrderFund, items[1].Type)
+	assert.Equal(t, -15000.0, items[2].Amount)
+	assert.Equal(t, "MGT01", items[3].ExternalOrderID)
+}
+
+func TestMappedWdInvalid(t *testing.T) {
+	mapping := &datasource.ColumnMapping{
+		DateColumn:     "Tanggal",
+		DateLayout:     "2006-01-02",
+		OrderRefColumn: "Order",
+		AmountColumn:   "Total",
+		TypeRules:      []datasource.MappingTypeRule{{Pattern: "x", Type: "order_fund"}},
+	}
+	assert.NotNil(t, mapping.Validate())
+
+	mapping.TypeRules = nil
+	assert.Nil(t, mapping.Validate())
+
+	// kolom mapping tidak ada di file
+	importer := datasource.NewMappedWd(
+		io.NopCloser(strings.NewReader("Tanggal,Resi,Total\n2025-08-01,A,100")),
+		datasource.MappedCsv,
+		db_models.OrderMengantar,
+		mapping,
+	)
+	_, err := importer.GetRefIDs()
+	assert.NotNil(t, err)
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"encoding/csv"
 	"io"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
 )
 
 type MengantarCsv struct {
@@ -46,7 +46,7 @@ func (m *MengantarCsv) Iterate(ctx context.Context, handler func(item *db_models
 		}
 		refID := strings.ReplaceAll(item[2], "Revenue from order ID ", "")
 
-		amount, err := strconv.ParseFloat(item[14], 64)
+		amount, err := money.ParseFloat(item[14])
 		if err != nil {
 			return err
 		}
@@ -57,13 +57,16 @@ func (m *MengantarCsv) Iterate(ctx context.Context, handler func(item *db_models
 			Type:            db_models.AdjOrderFund,
 			TransactionDate: t,
 			Description:     item[2],
-			Amount:          amount,
+			Amount:          amount.Float64(),
 		}
 
 		ordfunds = append(ordfunds, &orditem)
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 
 	for _, dd := range ordfunds {
 		item := dd
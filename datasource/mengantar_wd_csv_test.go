@@ -2,11 +2,14 @@ package datasource_test
 
 import (
 	"context"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,21 +25,55 @@ func TestIterateMengantar(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Len(t, refList, 3)
 
-	var amount float64 = 0
+	var amount money.Amount
+	funds := []float64{}
 	wdCount := 0
 	err = mengan.Iterate(context.Background(), func(item *db_models.InvoItem) error {
 		switch item.Type {
 		case db_models.AdjFund:
 			wdCount += 1
-			// assert.Equal(t, 471826.2302, item.Amount)
 
 		case db_models.AdjOrderFund:
-			amount += item.Amount
+			amount += money.FromFloat(item.Amount)
+			funds = append(funds, item.Amount)
 		}
 
 		return nil
 	})
 	assert.Nil(t, err)
 	assert.Equal(t, 3, wdCount)
-	assert.Equal(t, 471826.2302, amount)
+	// kolom total fixture 162241.6702, 148025.38, 161559.18 dibulatkan ke sen
+	assert.Equal(t, []float64{162241.67, 148025.38, 161559.18}, funds)
+	assert.Equal(t, money.FromFloat(471826.23), amount)
+
+	t.Run("total format float", func(t *testing.T) {
+		data := "No,Date,Description,Tracking ID,Courier,Customer Name,Customer Phone Number,Goods Description,Quantity,Sender Name,COD Value,Discounted Shipping Fee,Estimated Pricing,COD Fee (inc tax),Total\n" +
+			"1,13 Mar 2025 20:00,Revenue from order ID 250310Z9V57B,'5419832500861893,JNE,Anditha Putri,+6281210636848,pakaian,1,Kuki Outfit Pdc,176106,8000.0001999999995,13864.33,5864.3298,1.622416702e+05\n" +
+			"2,13 Mar 2025 20:00,Revenue from order ID 2503127V9BIL,'5419832500886627,JNE,yun.andriany,087788160016,pakaian,1,Kuki Outfit Pdc,161400,8000,13374.62,5374.620000000001, 148025.38 \n"
+
+		funds := []float64{}
+		err := datasource.
+			NewMengantarWdCsv(io.NopCloser(strings.NewReader(data))).
+			Iterate(context.Background(), func(item *db_models.InvoItem) error {
+				if item.Type == db_models.AdjOrderFund {
+					funds = append(funds, item.Amount)
+				}
+				return nil
+			})
+		assert.Nil(t, err)
+		assert.Equal(t, []float64{162241.67, 148025.38}, funds)
+	})
+
+	t.Run("total tidak valid tidak dilewati", func(t *testing.T) {
+		data := "No,Date,Description,Tracking ID,Courier,Customer Name,Customer Phone Number,Goods Description,Quantity,Sender Name,COD Value,Discounted Shipping Fee,Estimated Pricing,COD Fee (inc tax),Total\n" +
+			"1,13 Mar 2025 20:00,Revenue from order ID 250310Z9V57B,'5419832500861893,JNE,Anditha Putri,+6281210636848,pakaian,1,Kuki Outfit Pdc,176106,8000,13864.33,5864.3298,162241.67\n" +
+			"2,13 Mar 2025 20:00,Revenue from order ID 2503127V9BIL,'5419832500886627,JNE,yun.andriany,087788160016,pakaian,1,Kuki Outfit Pdc,161400,8000,13374.62,5374.62,148.025.38\n"
+
+		err := datasource.
+			NewMengantarWdCsv(io.NopCloser(strings.NewReader(data))).
+			Iterate(context.Background(), func(item *db_models.InvoItem) error {
+				return nil
+			})
+		assert.NotNil(t, err)
+	})
 }
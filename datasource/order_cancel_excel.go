@@ -0,0 +1,153 @@
+package datasource
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pdcgo/withdrawal_service/models"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/xuri/excelize/v2"
+)
+
+// orderExportRows baris setelah header di sheet pertama, header dikenali dari kolom pertama
+func orderExportRows(reader io.Reader, firstCol string) ([]string, [][]string, error) {
+	f, err := excelize.OpenReader(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, nil, wd_error.New(wd_error.CodeFileInvalid, "file export order kosong", nil)
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, row := range rows {
+		if len(row) == 0 || !strings.EqualFold(strings.TrimSpace(row[0]), firstCol) {
+			continue
+		}
+		return row, rows[i+1:], nil
+	}
+
+	return nil, nil, wd_error.New(
+		wd_error.CodeFileInvalid,
+		"header export order tidak ditemukan",
+		wd_error.Params{"column": firstCol},
+	)
+}
+
+type ShopeeOrderCancelXls struct {
+	reader io.ReadCloser
+}
+
+// Iterate export pesanan shopee, hanya order batal atau dikembalikan yang diteruskan
+func (s *ShopeeOrderCancelXls) Iterate(ctx context.Context, handler func(item *models.OrderCancel) error) error {
+	_, rows, err := orderExportRows(s.reader, "No. Pesanan")
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+
+		order := models.ShopeeOrderCancel{}
+		err = order.Marshal(row)
+		if err != nil {
+			return err
+		}
+
+		item := order.ToOrderCancel()
+		if item == nil {
+			continue
+		}
+
+		err = handler(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewShopeeOrderCancelXls(reader io.ReadCloser) *ShopeeOrderCancelXls {
+	return &ShopeeOrderCancelXls{
+		reader: reader,
+	}
+}
+
+type TiktokOrderCancelXls struct {
+	reader io.ReadCloser
+}
+
+// Iterate export order tiktok, kolom dicari dari nama header karena urutannya sering berubah
+func (t *TiktokOrderCancelXls) Iterate(ctx context.Context, handler func(item *models.OrderCancel) error) error {
+	header, rows, err := orderExportRows(t.reader, "Order ID")
+	if err != nil {
+		return err
+	}
+
+	cols := map[string]int{}
+	for i, col := range header {
+		cols[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+
+	get := func(row []string, col string) string {
+		i, ok := cols[col]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	for _, row := range rows {
+		orderID := get(row, "order id")
+		// baris kedua export tiktok berisi keterangan kolom
+		if orderID == "" || !strings.ContainsAny(orderID, "0123456789") {
+			continue
+		}
+
+		status := strings.ToLower(get(row, "order status"))
+		cancelType := strings.ToLower(get(row, "cancelation/return type"))
+		reason := get(row, "cancel reason")
+
+		item := &models.OrderCancel{
+			ExternalOrderID: orderID,
+			Reason:          reason,
+			Receipt:         get(row, "tracking id"),
+		}
+
+		switch {
+		case strings.Contains(cancelType, "return"):
+			item.Kind = models.OrderReturned
+		case strings.HasPrefix(status, "cancel"):
+			item.Kind = models.OrderCancelled
+			if strings.Contains(strings.ToLower(reason), "deliver") {
+				item.Kind = models.OrderFailedDelivery
+			}
+		default:
+			continue
+		}
+
+		err = handler(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewTiktokOrderCancelXls(reader io.ReadCloser) *TiktokOrderCancelXls {
+	return &TiktokOrderCancelXls{
+		reader: reader,
+	}
+}
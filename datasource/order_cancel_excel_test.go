@@ -0,0 +1,79 @@
+package datasource_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func orderExportFile(t *testing.T, rows [][]interface{}) io.ReadCloser {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		assert.Nil(t, err)
+		err = f.SetSheetRow(sheet, cell, &row)
+		assert.Nil(t, err)
+	}
+
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+	return io.NopCloser(bytes.NewReader(buf.Bytes()))
+}
+
+func TestIterateShopeeOrderCancel(t *testing.T) {
+	file := orderExportFile(t, [][]interface{}{
+		{"No. Pesanan", "Status Pesanan", "Alasan Pembatalan", "Status Pembatalan/ Pengembalian", "No. Resi"},
+		{"24092989VWP90G", "Batal", "Dibatalkan oleh Pembeli. Alasan: Perlu mengubah alamat pengiriman", "", ""},
+		{"24092989VWP91H", "Batal", "Dibatalkan oleh Sistem. Alasan: Pengiriman gagal", "", "SPXID0001"},
+		{"24092989VWP92J", "Selesai", "", "Pengembalian Dana Disetujui", "SPXID0002"},
+		{"24092989VWP93K", "Selesai", "", "Pengembalian Ditolak", "SPXID0003"},
+		{"24092989VWP94L", "Dikirim", "", "", "SPXID0004"},
+	})
+
+	items := []*models.OrderCancel{}
+	err := datasource.NewShopeeOrderCancelXls(file).Iterate(context.Background(), func(item *models.OrderCancel) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+
+	assert.Equal(t, models.OrderCancelled, items[0].Kind)
+	assert.Equal(t, models.OrderFailedDelivery, items[1].Kind)
+	assert.Equal(t, "SPXID0001", items[1].Receipt)
+	assert.Equal(t, models.OrderReturned, items[2].Kind)
+	assert.Equal(t, "24092989VWP92J", items[2].ExternalOrderID)
+}
+
+func TestIterateTiktokOrderCancel(t *testing.T) {
+	file := orderExportFile(t, [][]interface{}{
+		{"Order ID", "Order Status", "Cancelation/Return Type", "Cancel Reason", "Tracking ID"},
+		{"Platform unique order ID.", "Order status", "", "", ""},
+		{"577001", "Canceled", "Cancel", "Out of stock", ""},
+		{"577002", "Canceled", "Cancel", "Package delivery failed", "JX0001"},
+		{"577003", "Completed", "Return/Refund", "", "JX0002"},
+		{"577004", "Completed", "", "", "JX0003"},
+	})
+
+	items := []*models.OrderCancel{}
+	err := datasource.NewTiktokOrderCancelXls(file).Iterate(context.Background(), func(item *models.OrderCancel) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+
+	assert.Equal(t, models.OrderCancelled, items[0].Kind)
+	assert.Equal(t, models.OrderFailedDelivery, items[1].Kind)
+	assert.Equal(t, models.OrderReturned, items[2].Kind)
+	assert.Equal(t, "577003", items[2].ExternalOrderID)
+}
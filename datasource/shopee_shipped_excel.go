@@ -69,9 +69,7 @@ func (s *ShopeeShippedXls) Iterate(ctx context.Context, handler func(orderRefID
 				return err
 			}
 
-			handler(item.OrderID)
-
-			return nil
+			return handler(item.OrderID)
 		})
 
 		if err != nil {
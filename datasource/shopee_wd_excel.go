@@ -7,6 +7,7 @@ import (
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/excel_reader"
 	"github.com/pdcgo/withdrawal_service/models"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -135,7 +136,7 @@ func (s *ShopeeWdXls) Iterate(ctx context.Context, handler func(item *db_models.
 		// checking duplicate fund
 		if item.Type == models.WdTxFund {
 			if lastitem.Type == item.Type {
-				if lastitem.Amount == item.Amount {
+				if money.FromFloat(lastitem.Amount) == money.FromFloat(item.Amount) {
 					return nil
 				}
 			}
@@ -147,8 +148,8 @@ func (s *ShopeeWdXls) Iterate(ctx context.Context, handler func(item *db_models.
 			ExternalOrderID: item.ExternalOrderID,
 			TransactionDate: item.TransactionDate,
 			Description:     item.Description,
-			Amount:          item.Amount,
-			BalanceAfter:    item.BalanceAfter,
+			Amount:          money.FromFloat(item.Amount).Float64(),
+			BalanceAfter:    money.FromFloat(item.BalanceAfter).Float64(),
 			Type:            tipe,
 		})
 
@@ -66,9 +66,7 @@ func (t *TiktokShippedXls) Iterate(ctx context.Context, handler func(orderRefID
 				return nil
 			}
 
-			handler(item.OrderID)
-
-			return nil
+			return handler(item.OrderID)
 		})
 
 		if err != nil {
@@ -9,6 +9,8 @@ import (
 
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/excel_reader"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -73,7 +75,7 @@ func (w *withdrawalMapImpl) calculateAfterAmount() error {
 			continue
 		}
 
-		wd.DiffAmount += item.Amount
+		wd.DiffAmount = (money.FromFloat(wd.DiffAmount) + money.FromFloat(item.Amount)).Float64()
 	}
 	wlen := len(w.wds)
 
@@ -82,7 +84,7 @@ func (w *withdrawalMapImpl) calculateAfterAmount() error {
 			continue
 		}
 		nextwd := w.wds[i+1]
-		nextwd.AfterAmount = (wd.DiffAmount * -1) + wd.AfterAmount
+		nextwd.AfterAmount = (money.FromFloat(wd.AfterAmount) - money.FromFloat(wd.DiffAmount)).Float64()
 	}
 
 	return nil
@@ -120,18 +122,18 @@ func (w *withdrawalMapImpl) getStack(pos int, item *TiktokWdItem) (*TiktokDayWDI
 	}
 
 	if (pos + 1) > len(w.data) {
-		return nil, fmt.Errorf("stack nil, mungkin xls bermasalah coba download dengan rentan waktu lebih panjang")
+		return nil, wd_error.New(wd_error.CodeRangeTooShort, "stack nil, mungkin xls bermasalah coba download dengan rentan waktu lebih panjang", nil)
 	}
 
 	stack := w.data[pos]
 	if stack == nil {
-		return nil, fmt.Errorf("stack nil, mungkin xls bermasalah")
+		return nil, wd_error.New(wd_error.CodeFileInvalid, "stack nil, mungkin xls bermasalah", nil)
 	}
 
 	if stack.SuccessTime.Equal(item.OrderSettledTime) {
 		if item.SettlementAmount != 0 {
-			stack.Amount -= item.SettlementAmount
-			if stack.Amount == 0 {
+			stack.Amount = (money.FromFloat(stack.Amount) - money.FromFloat(item.SettlementAmount)).Float64()
+			if money.FromFloat(stack.Amount) == 0 {
 				w.ind += 1
 			}
 		}
@@ -141,14 +143,22 @@ func (w *withdrawalMapImpl) getStack(pos int, item *TiktokWdItem) (*TiktokDayWDI
 		if item.SettlementAmount == 0 {
 			stack := w.data[w.ind-1]
 			if stack == nil {
-				return nil, fmt.Errorf("stack nil, mungkin xls bermasalah2")
+				return nil, wd_error.New(wd_error.CodeFileInvalid, "stack nil, mungkin xls bermasalah2", nil)
 			}
 
 			if stack.SuccessTime.Equal(item.OrderSettledTime) {
 				return stack, nil
 			}
 		}
-		return nil, fmt.Errorf("%s with %s on stack %s", item.ExternalOrderID, item.OrderSettledTime.String(), stack.SuccessTime.String())
+		return nil, wd_error.New(
+			wd_error.CodeWdNotMatch,
+			fmt.Sprintf("%s with %s on stack %s", item.ExternalOrderID, item.OrderSettledTime.String(), stack.SuccessTime.String()),
+			wd_error.Params{
+				"external_order_id": item.ExternalOrderID,
+				"settled_time":      item.OrderSettledTime.String(),
+				"wd_time":           stack.SuccessTime.String(),
+			},
+		)
 	}
 
 	return stack, nil
@@ -281,7 +291,7 @@ func (s *tiktokWdXlsImpl) Iterate(ctx context.Context, handler func(item *db_mod
 			ExternalOrderID: item.ExternalOrderID,
 			TransactionDate: item.OrderSettledTime,
 			Description:     item.Type,
-			Amount:          item.SettlementAmount,
+			Amount:          money.FromFloat(item.SettlementAmount).Float64(),
 			BalanceAfter:    0,
 			Type:            tipe,
 		}
@@ -72,9 +72,7 @@ func (l *TokopediaShippedXls) Iterate(ctx context.Context, handler func(orderRef
 				haveProcess[item.OrderID] = true
 			}
 
-			handler(item.OrderID)
-
-			return nil
+			return handler(item.OrderID)
 		})
 
 		if err != nil {
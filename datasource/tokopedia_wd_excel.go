@@ -0,0 +1,374 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/xuri/excelize/v2"
+)
+
+// kolom riwayat saldo tokopedia, nama kolom beda antara export bahasa indonesia dan inggris
+var tokopediaColumns = map[string][]string{
+	"date":    {"tanggal", "date"},
+	"desc":    {"deskripsi", "keterangan", "description"},
+	"amount":  {"nominal", "jumlah", "amount"},
+	"balance": {"saldo", "balance"},
+}
+
+var tokopediaDateLayouts = []string{
+	"02 Jan 2006 15:04:05",
+	"02 Jan 2006 15:04",
+	"2 Jan 2006 15:04",
+	"02 Jan 2006",
+	"2006-01-02 15:04:05",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02-01-2006 15:04",
+}
+
+var tokopediaMonthReplacer = strings.NewReplacer(
+	"Mei", "May",
+	"Agu", "Aug",
+	"Agt", "Aug",
+	"Okt", "Oct",
+	"Des", "Dec",
+)
+
+var tokopediaInvoiceRegex = regexp.MustCompile(`INV/[0-9]{8}/[A-Z]+/[0-9]+`)
+
+type TokopediaWdItem struct {
+	TransactionDate time.Time
+	Description     string
+	Amount          money.Amount
+	BalanceAfter    money.Amount
+}
+
+func (item *TokopediaWdItem) ExternalOrderID() string {
+	return tokopediaInvoiceRegex.FindString(item.Description)
+}
+
+// IsFailedWithdrawal penarikan gagal dan dana yang dikembalikan tidak diproses
+func (item *TokopediaWdItem) IsFailedWithdrawal() bool {
+	desc := strings.ToLower(item.Description)
+	return strings.Contains(desc, "penarikan") && strings.Contains(desc, "gagal")
+}
+
+// AdjustmentType klasifikasi baris riwayat saldo dari deskripsi
+func (item *TokopediaWdItem) AdjustmentType() db_models.AdjustmentType {
+	desc := strings.ToLower(item.Description)
+
+	switch {
+	case item.Amount < 0 && (strings.Contains(desc, "penarikan") || strings.Contains(desc, "withdrawal")):
+		return db_models.AdjFund
+	case strings.Contains(desc, "refund"), strings.Contains(desc, "pengembalian dana"), strings.Contains(desc, "retur"):
+		return db_models.AdjReturn
+	case strings.Contains(desc, "ongkos kirim"), strings.Contains(desc, "ongkir"), strings.Contains(desc, "shipping"):
+		return db_models.AdjShipping
+	case strings.Contains(desc, "kompensasi"), strings.Contains(desc, "compensation"):
+		return db_models.AdjCompensation
+	case strings.Contains(desc, "biaya"), strings.Contains(desc, "potongan"), strings.Contains(desc, "komisi"), strings.Contains(desc, "fee"):
+		return db_models.AdjCommision
+	case strings.Contains(desc, "penjualan"), strings.Contains(desc, "transaksi"), strings.Contains(desc, "sales"):
+		if item.ExternalOrderID() != "" {
+			return db_models.AdjOrderFund
+		}
+	}
+
+	if item.ExternalOrderID() != "" {
+		return db_models.AdjUnknownAdj
+	}
+	return db_models.AdjUnknown
+}
+
+type TokopediaWdXls struct {
+	reader io.ReadCloser
+	rows   []*TokopediaWdItem
+}
+
+// GetShopUsername implements order_api.WdImporterIterate.
+func (t *TokopediaWdXls) GetShopUsername() (string, error) {
+	return "", ErrCannotGetMarketplaceUsername
+}
+
+// GetRefIDs implements order_api.WdImporterIterate.
+func (t *TokopediaWdXls) GetRefIDs() (OrderRefList, error) {
+	hasil := OrderRefList{}
+	rows, err := t.getRows()
+	if err != nil {
+		return hasil, err
+	}
+
+	maper := map[string]bool{}
+	for _, row := range rows {
+		refID := row.ExternalOrderID()
+		if maper[refID] {
+			continue
+		}
+		maper[refID] = true
+		hasil.Add(refID)
+	}
+
+	return hasil, nil
+}
+
+// Iterate implements order_api.WdImporterIterate.
+// urutan file dari yang terbaru, earning setelah baris penarikan adalah earning yang didanai penarikan tersebut.
+// satu invoice bisa punya beberapa baris biaya, digabung per tipe supaya tidak saling timpa di order adjustment
+func (t *TokopediaWdXls) Iterate(ctx context.Context, handler func(item *db_models.InvoItem) error) error {
+	rows, err := t.getRows()
+	if err != nil {
+		return err
+	}
+
+	type itemKey struct {
+		orderID string
+		tipe    db_models.AdjustmentType
+	}
+
+	buffer := []*db_models.InvoItem{}
+	bufferMap := map[itemKey]*db_models.InvoItem{}
+
+	flush := func() error {
+		for _, item := range buffer {
+			err := handler(item)
+			if err != nil {
+				return err
+			}
+		}
+		buffer = []*db_models.InvoItem{}
+		bufferMap = map[itemKey]*db_models.InvoItem{}
+		return nil
+	}
+
+	for _, row := range rows {
+		if row.IsFailedWithdrawal() {
+			continue
+		}
+
+		tipe := row.AdjustmentType()
+		item := &db_models.InvoItem{
+			MpFrom:          db_models.OrderMpTokopedia,
+			ExternalOrderID: row.ExternalOrderID(),
+			Type:            tipe,
+			TransactionDate: row.TransactionDate,
+			Description:     row.Description,
+			Amount:          row.Amount.Float64(),
+			BalanceAfter:    row.BalanceAfter.Float64(),
+		}
+
+		if tipe == db_models.AdjFund {
+			err = flush()
+			if err != nil {
+				return err
+			}
+
+			err = handler(item)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if item.ExternalOrderID == "" {
+			buffer = append(buffer, item)
+			continue
+		}
+
+		key := itemKey{item.ExternalOrderID, tipe}
+		prev := bufferMap[key]
+		if prev == nil {
+			bufferMap[key] = item
+			buffer = append(buffer, item)
+			continue
+		}
+
+		prev.Amount = (money.FromFloat(prev.Amount) + row.Amount).Float64()
+		if !strings.Contains(prev.Description, row.Description) {
+			prev.Description = fmt.Sprintf("%s, %s", prev.Description, row.Description)
+		}
+	}
+
+	return flush()
+}
+
+func (t *TokopediaWdXls) getRows() ([]*TokopediaWdItem, error) {
+	if t.rows != nil {
+		return t.rows, nil
+	}
+
+	f, err := excelize.OpenReader(t.reader)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, wd_error.New(wd_error.CodeFileInvalid, "file riwayat saldo tokopedia kosong", nil)
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hasil := []*TokopediaWdItem{}
+	var header map[string]int
+
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+
+		if header == nil {
+			header = tokopediaHeader(row)
+			continue
+		}
+
+		item, err := tokopediaParseRow(header, row)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			continue
+		}
+		hasil = append(hasil, item)
+	}
+
+	if header == nil {
+		return nil, wd_error.New(wd_error.CodeFileInvalid, "header riwayat saldo tokopedia tidak ditemukan", nil)
+	}
+
+	t.rows = hasil
+	return hasil, nil
+}
+
+// tokopediaHeader return nil kalau baris belum header, export tokopedia punya beberapa baris info di atas
+func tokopediaHeader(row []string) map[string]int {
+	header := map[string]int{}
+	for i, col := range row {
+		col = strings.ToLower(strings.TrimSpace(col))
+		for key, aliases := range tokopediaColumns {
+			if _, ok := header[key]; ok {
+				continue
+			}
+			for _, alias := range aliases {
+				if strings.HasPrefix(col, alias) {
+					header[key] = i
+					break
+				}
+			}
+		}
+	}
+
+	if len(header) != len(tokopediaColumns) {
+		return nil
+	}
+	return header
+}
+
+// tokopediaParseRow baris tanpa tanggal (total, catatan kaki) dilewati
+func tokopediaParseRow(header map[string]int, row []string) (*TokopediaWdItem, error) {
+	get := func(key string) string {
+		i := header[key]
+		if i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	date := get("date")
+	if date == "" {
+		return nil, nil
+	}
+
+	at, err := tokopediaParseDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := tokopediaParseAmount(get("amount"))
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := tokopediaParseAmount(get("balance"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokopediaWdItem{
+		TransactionDate: at,
+		Description:     get("desc"),
+		Amount:          amount,
+		BalanceAfter:    balance,
+	}, nil
+}
+
+// tokopediaParseAmount format rupiah, pemisah desimal ditebak dari posisi titik dan koma
+func tokopediaParseAmount(data string) (money.Amount, error) {
+	data = strings.ReplaceAll(data, "Rp", "")
+	data = strings.ReplaceAll(data, " ", "")
+	if data == "" {
+		return 0, nil
+	}
+
+	dot := strings.LastIndex(data, ".")
+	comma := strings.LastIndex(data, ",")
+	switch {
+	case dot >= 0 && comma >= 0:
+		if comma > dot {
+			data = strings.ReplaceAll(data, ".", "")
+			data = strings.ReplaceAll(data, ",", ".")
+		} else {
+			data = strings.ReplaceAll(data, ",", "")
+		}
+	case dot >= 0:
+		data = tokopediaSingleSeparator(data, ".")
+	case comma >= 0:
+		data = tokopediaSingleSeparator(data, ",")
+	}
+
+	return money.Parse(data)
+}
+
+// tokopediaSingleSeparator dianggap pemisah ribuan kalau muncul lebih dari sekali atau diikuti 3 digit
+func tokopediaSingleSeparator(data string, sep string) string {
+	last := strings.LastIndex(data, sep)
+	if strings.Count(data, sep) > 1 || len(data)-last-1 == 3 {
+		return strings.ReplaceAll(data, sep, "")
+	}
+	return strings.Replace(data, sep, ".", 1)
+}
+
+func tokopediaParseDate(data string) (time.Time, error) {
+	var err error
+	var at time.Time
+
+	data = tokopediaMonthReplacer.Replace(data)
+	for _, layout := range tokopediaDateLayouts {
+		at, err = time.ParseInLocation(layout, data, time.Local)
+		if err == nil {
+			return at, nil
+		}
+	}
+
+	return at, wd_error.New(
+		wd_error.CodeFileInvalid,
+		fmt.Sprintf("tanggal %s di riwayat saldo tokopedia tidak dikenali", data),
+		wd_error.Params{"date": data},
+	)
+}
+
+func NewTokopediaWdXls(reader io.ReadCloser) *TokopediaWdXls {
+	return &TokopediaWdXls{
+		reader: reader,
+	}
+}
@@ -0,0 +1,68 @@
+package datasource_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestIterateTokopediaSaldo(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	rows := [][]interface{}{
+		{"Riwayat Saldo Penjual"},
+		{"Tanggal", "Deskripsi", "Nominal (Rp)", "Saldo Akhir (Rp)"},
+		{"05 Agu 2025 10:00", "Pendapatan Transaksi Penjualan INV/20250801/MPL/100001", "20.000", "20.000"},
+		{"04 Agu 2025 09:00", "Penarikan Saldo ke BCA 1234", "-130.500", "0"},
+		{"03 Agu 2025 12:00", "Potongan Biaya Layanan INV/20250801/MPL/100002", "-2.000", "130.500"},
+		{"03 Agu 2025 12:00", "Potongan Biaya Power Merchant INV/20250801/MPL/100002", "-1.500", "132.500"},
+		{"03 Agu 2025 12:00", "Pendapatan Transaksi Penjualan INV/20250801/MPL/100002", "84.000", "134.000"},
+		{"02 Agu 2025 08:00", "Pendapatan Transaksi Penjualan INV/20250801/MPL/100003", "50.000,50", "50.000"},
+		{"01 Agu 2025 08:00", "Penarikan Saldo Gagal ke BCA 1234", "130.000", "0"},
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		assert.Nil(t, err)
+		err = f.SetSheetRow(sheet, cell, &row)
+		assert.Nil(t, err)
+	}
+
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+
+	importer := datasource.NewTokopediaWdXls(io.NopCloser(bytes.NewReader(buf.Bytes())))
+	refIDs, err := importer.GetRefIDs()
+	assert.Nil(t, err)
+	assert.Len(t, refIDs, 3)
+
+	items := []*db_models.InvoItem{}
+	err = importer.Iterate(context.Background(), func(item *db_models.InvoItem) error {
+		items = append(items, item)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, items, 5)
+
+	assert.Equal(t, db_models.AdjOrderFund, items[0].Type)
+	assert.Equal(t, 20000.0, items[0].Amount)
+
+	assert.Equal(t, db_models.AdjFund, items[1].Type)
+	assert.Equal(t, -130500.0, items[1].Amount)
+
+	// dua biaya satu invoice digabung
+	assert.Equal(t, db_models.AdjCommision, items[2].Type)
+	assert.Equal(t, "INV/20250801/MPL/100002", items[2].ExternalOrderID)
+	assert.Equal(t, -3500.0, items[2].Amount)
+
+	assert.Equal(t, db_models.AdjOrderFund, items[3].Type)
+	assert.Equal(t, 84000.0, items[3].Amount)
+	assert.Equal(t, 50000.5, items[4].Amount)
+}
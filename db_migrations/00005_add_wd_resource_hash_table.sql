@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE wd_resource_hashes (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    resource_path TEXT NOT NULL,
+    team_id BIGINT NOT NULL,
+    marketplace_id BIGINT NOT NULL,
+    content_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_wd_resource_hashes_path
+    ON wd_resource_hashes (resource_path);
+
+CREATE INDEX idx_wd_resource_hashes_lookup
+    ON wd_resource_hashes (team_id, marketplace_id, content_hash);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_wd_resource_hashes_lookup;
+DROP INDEX IF EXISTS idx_wd_resource_hashes_path;
+DROP TABLE IF EXISTS wd_resource_hashes;
@@ -38,7 +38,10 @@ func NewTaskErrorPipeline(store TaskStore, ctx context.Context) (ErrEmitter, fun
 							return data, store.SetErr(data.id, data.err)
 						})).
 						Via("debug error", yenstream.NewMap(ctx, func(data *taskErr) (*taskErr, error) {
-							slog.Error(data.err.Error(), slog.Uint64("task_id", uint64(data.id)))
+							slog.Error(data.err.Error(),
+								slog.Uint64("task_id", uint64(data.id)),
+								slog.String("code", string(taskError(data.err).Code)),
+							)
 							return data, nil
 						}))
 
@@ -0,0 +1,177 @@
+package withdrawal_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDuplicateFile = wd_error.New(wd_error.CodeDuplicateFile, "file withdrawal sudah pernah diimport", nil)
+
+type FingerprintScope string
+
+const (
+	FingerprintV1 FingerprintScope = "v1"
+	FingerprintV2 FingerprintScope = "v2"
+)
+
+// FileFingerprint satu file hanya boleh sekali diimport per toko, import ulang yang disengaja tidak dibatasi
+type FileFingerprint struct {
+	ID          uint             `gorm:"primarykey"`
+	Scope       FingerprintScope `gorm:"index:idx_file_fingerprint_lookup;uniqueIndex:idx_file_fingerprint_unique,where:reimport = false"`
+	TeamID      uint             `gorm:"index:idx_file_fingerprint_lookup;uniqueIndex:idx_file_fingerprint_unique"`
+	MpID        uint             `gorm:"index:idx_file_fingerprint_lookup;uniqueIndex:idx_file_fingerprint_unique"`
+	ContentHash string           `gorm:"index:idx_file_fingerprint_lookup;uniqueIndex:idx_file_fingerprint_unique"`
+	ResourceUri string
+	TaskID      uint
+	UserID      uint
+	Reimport    bool
+	CreatedAt   time.Time
+}
+
+// WDResourceHash content hash sha256 per WDResource, WDResource sendiri ada di shared db_models
+type WDResourceHash struct {
+	ID            uint `gorm:"primarykey"`
+	ResourcePath  string
+	TeamID        uint
+	MarketplaceID uint
+	ContentHash   string
+	CreatedAt     time.Time
+}
+
+// ResourceContentHash hash file yang dicatat saat upload, kosong kalau file tidak diupload lewat document service
+func ResourceContentHash(db *gorm.DB, teamID uint, resourcePath string) (string, error) {
+	var item WDResourceHash
+	err := db.
+		Model(&WDResourceHash{}).
+		Where("team_id = ?", teamID).
+		Where("resource_path = ?", resourcePath).
+		Order("id desc").
+		Limit(1).
+		Find(&item).
+		Error
+
+	return item.ContentHash, err
+}
+
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// MultiContentHash hash gabungan beberapa file, urutan file tidak berpengaruh
+func MultiContentHash(datas [][]byte) string {
+	hashes := make([]string, len(datas))
+	for i, data := range datas {
+		hashes[i] = ContentHash(data)
+	}
+	sort.Strings(hashes)
+
+	return ContentHash([]byte(strings.Join(hashes, ",")))
+}
+
+func CheckFileFingerprint(db *gorm.DB, scope FingerprintScope, teamID uint, mpID uint, hash string) error {
+	var prev FileFingerprint
+	err := db.
+		Model(&FileFingerprint{}).
+		Where("scope = ?", scope).
+		Where("team_id = ?", teamID).
+		Where("mp_id = ?", mpID).
+		Where("content_hash = ?", hash).
+		Order("id desc").
+		Limit(1).
+		Find(&prev).
+		Error
+
+	if err != nil {
+		return err
+	}
+
+	if prev.ID != 0 {
+		importedAt := prev.CreatedAt.Format("2006-01-02 15:04")
+		return wd_error.New(
+			wd_error.CodeDuplicateFile,
+			fmt.Sprintf("%s pada %s (%s), kirim ulang dengan reimport untuk import ulang",
+				ErrDuplicateFile.Error(),
+				importedAt,
+				prev.ResourceUri,
+			),
+			wd_error.Params{
+				"imported_at":  importedAt,
+				"resource_uri": prev.ResourceUri,
+			},
+		)
+	}
+
+	return nil
+}
+
+// SaveFileFingerprint ditolak unique index kalau file yang sama lolos cek bersamaan
+func SaveFileFingerprint(db *gorm.DB, fingerprint *FileFingerprint) error {
+	if fingerprint.CreatedAt.IsZero() {
+		fingerprint.CreatedAt = time.Now()
+	}
+
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(fingerprint)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		err := CheckFileFingerprint(db, fingerprint.Scope, fingerprint.TeamID, fingerprint.MpID, fingerprint.ContentHash)
+		if err != nil {
+			return err
+		}
+		return ErrDuplicateFile
+	}
+
+	return nil
+}
+
+// checkSubmitFile menolak file duplikat saat submit, hash diambil dari catatan upload.
+// file tanpa catatan hash dicek ulang oleh runner setelah file dibaca
+func checkSubmitFile(db *gorm.DB, payload *withdrawal_iface.SubmitWithdrawalRequest, opt *TaskOption) (string, error) {
+	hash, err := ResourceContentHash(db, uint(payload.TeamId), payload.ResourceUri)
+	if err != nil || hash == "" {
+		return hash, err
+	}
+
+	if opt.AllowReimport {
+		return hash, nil
+	}
+
+	var pending int64
+	err = db.
+		Model(&TaskItem{}).
+		Where("team_id = ?", payload.TeamId).
+		Where("mp_id = ?", payload.MpId).
+		Where("content_hash = ?", hash).
+		Where("dry_run = ?", false).
+		Where("status in ?", []withdrawal_iface.TaskStatus{
+			withdrawal_iface.TaskStatus_TASK_STATUS_WAITING,
+			withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+		}).
+		Count(&pending).
+		Error
+	if err != nil {
+		return hash, err
+	}
+
+	if pending > 0 {
+		return hash, wd_error.New(
+			wd_error.CodeDuplicateFile,
+			fmt.Sprintf("%s, file yang sama sedang diproses", ErrDuplicateFile.Error()),
+			nil,
+		)
+	}
+
+	return hash, CheckFileFingerprint(db, FingerprintV1, uint(payload.TeamId), uint(payload.MpId), hash)
+}
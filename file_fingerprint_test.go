@@ -0,0 +1,112 @@
+package withdrawal_service_test
+
+import (
+	"testing"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/shared/authorization"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/pdcgo/withdrawal_service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFileFingerprint(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "test file fingerprint",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(
+					&withdrawal_service.FileFingerprint{},
+					&withdrawal_service.WDResourceHash{},
+					&withdrawal_service.TaskItem{},
+				)
+				assert.Nil(t, err)
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			hash := withdrawal_service.ContentHash([]byte("file withdrawal"))
+			assert.Equal(t, hash, withdrawal_service.ContentHash([]byte("file withdrawal")))
+			assert.Equal(t,
+				withdrawal_service.MultiContentHash([][]byte{[]byte("a"), []byte("b")}),
+				withdrawal_service.MultiContentHash([][]byte{[]byte("b"), []byte("a")}),
+			)
+
+			err := withdrawal_service.CheckFileFingerprint(&db, withdrawal_service.FingerprintV1, 1, 1, hash)
+			assert.Nil(t, err)
+
+			err = withdrawal_service.SaveFileFingerprint(&db, &withdrawal_service.FileFingerprint{
+				Scope:       withdrawal_service.FingerprintV1,
+				TeamID:      1,
+				MpID:        1,
+				ContentHash: hash,
+				ResourceUri: "withdrawal_resources/test.xlsx",
+			})
+			assert.Nil(t, err)
+
+			t.Run("file sama di toko sama ditolak", func(t *testing.T) {
+				err := withdrawal_service.CheckFileFingerprint(&db, withdrawal_service.FingerprintV1, 1, 1, hash)
+				assert.ErrorIs(t, err, withdrawal_service.ErrDuplicateFile)
+			})
+
+			t.Run("toko lain atau scope lain tidak terpengaruh", func(t *testing.T) {
+				err := withdrawal_service.CheckFileFingerprint(&db, withdrawal_service.FingerprintV1, 1, 2, hash)
+				assert.Nil(t, err)
+
+				err = withdrawal_service.CheckFileFingerprint(&db, withdrawal_service.FingerprintV2, 1, 1, hash)
+				assert.Nil(t, err)
+			})
+
+			t.Run("simpan fingerprint yang sama ditolak kecuali reimport", func(t *testing.T) {
+				err := withdrawal_service.SaveFileFingerprint(&db, &withdrawal_service.FileFingerprint{
+					Scope:       withdrawal_service.FingerprintV1,
+					TeamID:      1,
+					MpID:        1,
+					ContentHash: hash,
+				})
+				assert.ErrorIs(t, err, withdrawal_service.ErrDuplicateFile)
+
+				err = withdrawal_service.SaveFileFingerprint(&db, &withdrawal_service.FileFingerprint{
+					Scope:       withdrawal_service.FingerprintV1,
+					TeamID:      1,
+					MpID:        1,
+					ContentHash: hash,
+					Reimport:    true,
+				})
+				assert.Nil(t, err)
+			})
+
+			t.Run("file duplikat ditolak saat submit", func(t *testing.T) {
+				err := db.Save(&withdrawal_service.WDResourceHash{
+					ResourcePath:  "withdrawal_resources/ulang.xlsx",
+					TeamID:        1,
+					MarketplaceID: 1,
+					ContentHash:   hash,
+				}).Error
+				assert.Nil(t, err)
+
+				st := withdrawal_service.NewTempStore(&db)
+				payload := &withdrawal_iface.SubmitWithdrawalRequest{
+					TeamId:      1,
+					MpId:        1,
+					ResourceUri: "withdrawal_resources/ulang.xlsx",
+				}
+
+				err = st.Add(&authorization.JwtIdentity{UserID: 1}, payload, nil)
+				assert.ErrorIs(t, err, withdrawal_service.ErrDuplicateFile)
+
+				err = st.Add(&authorization.JwtIdentity{UserID: 1}, payload, &withdrawal_service.TaskOption{AllowReimport: true})
+				assert.Nil(t, err)
+
+				task := withdrawal_service.TaskItem{}
+				err = db.Model(&withdrawal_service.TaskItem{}).Where("resource_uri = ?", payload.ResourceUri).First(&task).Error
+				assert.Nil(t, err)
+				assert.Equal(t, hash, task.ContentHash)
+			})
+		},
+	)
+}
@@ -0,0 +1,52 @@
+package withdrawal_service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+)
+
+const tempAssetBucket = "gudang_assets_temp"
+
+// FileStorage membaca file yang diupload user untuk rpc import yang tidak lewat task
+type FileStorage interface {
+	GetContent(ctx context.Context, uri string) ([]byte, error)
+}
+
+type gcsFileStorage struct {
+	bucket string
+}
+
+// GetContent implements FileStorage.
+func (g *gcsFileStorage) GetContent(ctx context.Context, uri string) ([]byte, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	params := wd_error.Params{"resource_uri": uri}
+	file, err := client.Bucket(g.bucket).Object(uri).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, wd_error.Wrap(wd_error.CodeFileNotFound, err, params)
+		}
+		return nil, wd_error.Wrap(wd_error.CodeStorage, err, params)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, wd_error.Wrap(wd_error.CodeStorage, err, params)
+	}
+	return data, nil
+}
+
+func NewGcsFileStorage(bucket string) FileStorage {
+	return &gcsFileStorage{
+		bucket: bucket,
+	}
+}
@@ -10,7 +10,7 @@ require (
 	github.com/gabriel-vasile/mimetype v1.4.11
 	github.com/google/wire v0.7.0
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.27.2
-	github.com/pdcgo/schema v1.0.134
+	github.com/pdcgo/schema v1.0.135
 	github.com/pdcgo/shared v1.0.134
 	github.com/stretchr/testify v1.11.1
 	github.com/xuri/excelize/v2 v2.9.1
@@ -24,6 +24,7 @@ require (
 	cloud.google.com/go/cloudtasks v1.13.7
 	github.com/golang/mock v1.7.0-rc.1
 	github.com/googleapis/gax-go/v2 v2.21.0
+	github.com/lib/pq v1.10.9
 	github.com/pdcgo/accounting_service v1.0.12
 	github.com/wargasipil/data_processing v0.0.0-20260420085126-0b978af04e6f
 	google.golang.org/protobuf v1.36.11
@@ -96,7 +97,6 @@ require (
 	github.com/klauspost/compress v1.18.4 // indirect
 	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-sqlite3 v1.14.32 // indirect
 	github.com/microsoft/go-mssqldb v1.9.6 // indirect
@@ -10,6 +10,7 @@ type ReturnQuery interface {
 	Get(lock bool) error
 	Err() error
 	Cancel() error
+	Open() error
 	AddNote(orderID uint, tipe db_models.NoteType, note string) error
 	SetReturnProblem() ReturnQuery
 	SetResolution(resID uint) ReturnQuery
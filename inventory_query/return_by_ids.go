@@ -1,6 +1,7 @@
 package inventory_query
 
 import (
+	"errors"
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
@@ -24,37 +25,123 @@ func NewReturnByIds(
 	}
 }
 
+var ErrReturnNotFound = errors.New("return transaction tidak ditemukan")
+var ErrReturnUnsupported = errors.New("perubahan return ini belum didukung di withdrawal service")
+
 type returnByIdsImpl struct {
 	tx    *gorm.DB
 	agent identity_iface.Agent
 
 	teamID uint
 	txIDs  []uint
+	txs    []*db_models.InvTransaction
+	err    error
 }
 
 // AddNote implements inventory_iface.ReturnQuery.
+// note return dikelola inventory service
 func (r *returnByIdsImpl) AddNote(orderID uint, tipe db_models.NoteType, note string) error {
-	panic("unimplemented")
+	return ErrReturnUnsupported
 }
 
 // Err implements inventory_iface.ReturnQuery.
 func (r *returnByIdsImpl) Err() error {
-	panic("unimplemented")
+	return r.err
 }
 
 // Get implements inventory_iface.ReturnQuery.
 func (r *returnByIdsImpl) Get(lock bool) error {
-	panic("unimplemented")
+	if r.err != nil {
+		return r.err
+	}
+
+	r.txs = []*db_models.InvTransaction{}
+	r.err = r.buildQuery(lock).Find(&r.txs).Error
+	if r.err != nil {
+		return r.err
+	}
+
+	if len(r.txs) == 0 {
+		r.err = ErrReturnNotFound
+	}
+	return r.err
 }
 
 // SetResolution implements inventory_iface.ReturnQuery.
 func (r *returnByIdsImpl) SetResolution(resID uint) ReturnQuery {
-	panic("unimplemented")
+	if r.err == nil {
+		r.err = ErrReturnUnsupported
+	}
+	return r
 }
 
 // SetReturnProblem implements inventory_iface.ReturnQuery.
 func (r *returnByIdsImpl) SetReturnProblem() ReturnQuery {
-	panic("unimplemented")
+	if r.err == nil {
+		r.err = ErrReturnUnsupported
+	}
+	return r
+}
+
+// Open implements inventory_iface.ReturnQuery.
+// return yang sudah dicancel (misal karena order sempat completed) dikembalikan ke status sebelum cancel,
+// status diambil dari timestamp terakhir yang bukan cancel
+func (r *returnByIdsImpl) Open() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	txIDs := []uint{}
+	err := r.buildQuery(true).
+		Where("status = ?", db_models.InvTxCancel).
+		Select("id").
+		Find(&txIDs).
+		Error
+	if err != nil {
+		return err
+	}
+
+	for _, txID := range txIDs {
+		var last db_models.InvTimestamp
+		err = r.tx.
+			Model(&db_models.InvTimestamp{}).
+			Where("tx_id = ?", txID).
+			Where("status != ?", db_models.InvTxCancel).
+			Order("id desc").
+			Limit(1).
+			Find(&last).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// tidak ada status sebelum cancel, dibiarkan
+		if last.TxID == 0 {
+			continue
+		}
+
+		err = r.tx.
+			Model(&db_models.InvTransaction{}).
+			Where("id = ?", txID).
+			Update("status", last.Status).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = r.tx.Save(&db_models.InvTimestamp{
+			TxID:      txID,
+			UserID:    r.agent.GetUserID(),
+			Status:    last.Status,
+			Timestamp: time.Now(),
+			From:      r.agent.GetAgentType(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Cancel implements invertory_iface.ReturnQuery.
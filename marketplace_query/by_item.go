@@ -100,7 +100,7 @@ func (m *itemQueryImpl) DeleteWithdrawal(wdID uint) error {
 		return err
 	}
 
-	err = m.tx.Model(&db_models.AssetHistory{}).Where("id = ?", histID).Error
+	err = m.tx.Where("id = ?", histID).Delete(&db_models.AssetHistory{}).Error
 	if err != nil {
 		return err
 	}
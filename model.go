@@ -3,18 +3,78 @@ package withdrawal_service
 import (
 	"github.com/pdcgo/schema/services/common/v1"
 	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
 	"github.com/pdcgo/shared/authorization"
 	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"gorm.io/datatypes"
 )
 
 type TaskItem struct {
 	ID        uint `gorm:"primarykey"`
 	AgentData datatypes.JSONType[*authorization.JwtIdentity]
+	Attempt   int
+	NextRunAt int64 `gorm:"index"`
+
+	LeaseOwner     string
+	LeaseExpiredAt int64 `gorm:"index"`
+	HeartbeatAt    int64
+
+	CancelledBy uint
+	CancelledAt int64
+
+	RevertedBy uint
+	RevertedAt int64
+
+	RowsParsed    int
+	RowsProcessed int
+	Summary       datatypes.JSONType[*TaskSummary]
+
+	ContentHash   string `gorm:"index"`
+	AllowReimport bool
+
+	DryRun  bool
+	Preview datatypes.JSONType[*TaskPreview]
+
+	ErrCode     wd_error.Code `gorm:"index"`
+	ErrCategory wd_error.Category
+	ErrParams   datatypes.JSONType[wd_error.Params]
+
+	FinishedAt int64 `gorm:"index"`
+	Duration   int64 // detik dari mulai proses sampai selesai
 
 	*withdrawal_iface.TaskItem
 }
 
+func (t *TaskItem) ToTaskIface() *withdrawal_task_iface.TaskItem {
+	item := &withdrawal_task_iface.TaskItem{
+		Task:          t.TaskItem,
+		Id:            uint64(t.ID),
+		DryRun:        t.DryRun,
+		Attempt:       int32(t.Attempt),
+		NextRunAt:     t.NextRunAt,
+		CancelledBy:   uint64(t.CancelledBy),
+		CancelledAt:   t.CancelledAt,
+		RevertedBy:    uint64(t.RevertedBy),
+		RevertedAt:    t.RevertedAt,
+		RowsParsed:    int32(t.RowsParsed),
+		RowsProcessed: int32(t.RowsProcessed),
+		Summary:       t.Summary.Data().ToIface(),
+		FinishedAt:    t.FinishedAt,
+		Duration:      t.Duration,
+		ErrCode:       string(t.ErrCode),
+		ErrCategory:   string(t.ErrCategory),
+		ErrParams:     t.ErrParams.Data(),
+	}
+
+	agent := t.AgentData.Data()
+	if agent != nil {
+		item.SubmittedBy = uint64(agent.UserID)
+	}
+
+	return item
+}
+
 // func (TaskItem) TableName() string {
 // 	return "task_items_v2"
 // }
@@ -30,8 +90,19 @@ func (t *TaskItem) ToLegacyWDImporterQuery() *WDImporterQuery {
 		source = XlsSource
 	}
 
+	return &WDImporterQuery{
+		ImporterQuery: &ImporterQuery{
+			Source: source,
+			MpType: LegacyOrderMpType(t.MpType),
+			TeamID: uint(t.TeamId),
+		},
+		MpID: uint(t.MpId),
+	}
+}
+
+func LegacyOrderMpType(tipe common.MarketplaceType) db_models.OrderMpType {
 	var mpType db_models.OrderMpType
-	switch t.MpType {
+	switch tipe {
 	case common.MarketplaceType_MARKETPLACE_TYPE_CUSTOM:
 		mpType = db_models.OrderMpCustom
 	case common.MarketplaceType_MARKETPLACE_TYPE_LAZADA:
@@ -45,13 +116,5 @@ func (t *TaskItem) ToLegacyWDImporterQuery() *WDImporterQuery {
 	case common.MarketplaceType_MARKETPLACE_TYPE_TOKOPEDIA:
 		mpType = db_models.OrderMpTokopedia
 	}
-
-	return &WDImporterQuery{
-		ImporterQuery: &ImporterQuery{
-			Source: source,
-			MpType: mpType,
-			TeamID: uint(t.TeamId),
-		},
-		MpID: uint(t.MpId),
-	}
+	return mpType
 }
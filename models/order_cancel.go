@@ -0,0 +1,17 @@
+package models
+
+type OrderCancelKind string
+
+const (
+	OrderCancelled      OrderCancelKind = "cancel"
+	OrderReturned       OrderCancelKind = "return"
+	OrderFailedDelivery OrderCancelKind = "failed_delivery" // paket sudah dikirim tapi gagal sampai, barang kembali ke gudang
+)
+
+// OrderCancel satu baris laporan pembatalan / pengembalian marketplace
+type OrderCancel struct {
+	ExternalOrderID string
+	Kind            OrderCancelKind
+	Reason          string
+	Receipt         string
+}
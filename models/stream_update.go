@@ -139,6 +139,33 @@ func (item *ShopeeOrderCancel) Marshal(data []string) error {
 	return nil
 }
 
+// ToOrderCancel nil kalau order tidak batal dan tidak dikembalikan.
+// kolom 2 export shopee berisi alasan pembatalan, kolom 3 status pembatalan / pengembalian
+func (item *ShopeeOrderCancel) ToOrderCancel() *OrderCancel {
+	reason := strings.ToLower(item.ReturnStatus)
+	returnStatus := strings.ToLower(item.ShippingFailedStatus)
+
+	hasil := &OrderCancel{
+		ExternalOrderID: item.ExternalOrderID,
+		Reason:          item.ReturnStatus,
+		Receipt:         item.Receipt,
+	}
+
+	switch {
+	case item.Status == ShopeeOrderStatusCancel:
+		hasil.Kind = OrderCancelled
+		if item.Receipt != "" || strings.Contains(reason, "gagal") {
+			hasil.Kind = OrderFailedDelivery
+		}
+	case strings.Contains(returnStatus, "pengembalian") && !strings.Contains(returnStatus, "ditolak") && !strings.Contains(returnStatus, "dibatalkan"):
+		hasil.Kind = OrderReturned
+	default:
+		return nil
+	}
+
+	return hasil
+}
+
 type ShopeeReturnStatus string
 
 const (
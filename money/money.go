@@ -0,0 +1,170 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount nominal dalam satuan sen, semua penjumlahan dan perbandingan nominal pakai ini supaya exact
+type Amount int64
+
+const scale = 100
+
+// FromFloat nilai float dari parser atau InvoItem dibulatkan ke sen terdekat
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * scale))
+}
+
+// Sum menjumlahkan nominal float tanpa selisih pembulatan
+func Sum(values ...float64) Amount {
+	var hasil Amount
+	for _, val := range values {
+		hasil += FromFloat(val)
+	}
+	return hasil
+}
+
+// Parse string desimal dengan titik, misal "-12500.75", digit ketiga setelah koma dibulatkan
+func Parse(str string) (Amount, error) {
+	raw := strings.TrimSpace(str)
+	s := raw
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	for _, part := range []string{whole, frac} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return 0, fmt.Errorf("invalid amount %q", raw)
+			}
+		}
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", raw, err)
+	}
+
+	frac += "000"
+	cents, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		cents += 1
+	}
+
+	hasil := Amount(units*scale + cents)
+	if negative {
+		hasil = -hasil
+	}
+	return hasil, nil
+}
+
+// ParseFloat sama dengan Parse, tapi angka format float seperti "1.6e+05" tetap diterima.
+// dipakai untuk file export yang kolom angka nya ditulis dari float
+func ParseFloat(str string) (Amount, error) {
+	hasil, err := Parse(str)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		if ferr != nil {
+			return 0, err
+		}
+		hasil = FromFloat(f)
+	}
+	return hasil, nil
+}
+
+// Float64 untuk field float di InvoItem dan query lama
+func (a Amount) Float64() float64 {
+	return float64(a) / scale
+}
+
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+	}
+	abs := a.Abs()
+	return fmt.Sprintf("%s%d.%02d", sign, abs/scale, abs%scale)
+}
+
+// MarshalJSON disimpan sebagai angka rupiah, sama dengan field float sebelumnya
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "null" || str == "" {
+		*a = 0
+		return nil
+	}
+
+	return a.parse(str)
+}
+
+// GormDataType kolom decimal, sama dengan kolom float64 yang di migrate gorm postgres
+func (Amount) GormDataType() string {
+	return "decimal"
+}
+
+// Value implements driver.Valuer, dikirim sebagai string desimal supaya tidak lewat float
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan implements sql.Scanner
+func (a *Amount) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case float64:
+		*a = FromFloat(v)
+		return nil
+	case int64:
+		*a = Amount(v * scale)
+		return nil
+	case []byte:
+		return a.parse(string(v))
+	case string:
+		return a.parse(v)
+	}
+
+	return fmt.Errorf("cannot scan %T into money.Amount", value)
+}
+
+// parse string desimal, angka dengan eksponen dari float lama tetap diterima
+func (a *Amount) parse(str string) error {
+	hasil, err := ParseFloat(str)
+	if err != nil {
+		return err
+	}
+	*a = hasil
+	return nil
+}
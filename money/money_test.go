@@ -0,0 +1,111 @@
+package money_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmount(t *testing.T) {
+	t.Run("penjumlahan float tetap exact", func(t *testing.T) {
+		a, b := 0.1, 0.2
+		assert.NotEqual(t, 0.3, a+b)
+		assert.Equal(t, money.FromFloat(0.3), money.Sum(a, b))
+		assert.Equal(t, money.FromFloat(1200.6), money.Sum(0.1, 0.2, 1500.35, -300.05))
+	})
+
+	t.Run("parse string", func(t *testing.T) {
+		cases := map[string]money.Amount{
+			"12500":     1250000,
+			"-12500.75": -1250075,
+			"0.5":       50,
+			".25":       25,
+			"10.005":    1001,
+			"+7.1":      710,
+		}
+
+		for str, expected := range cases {
+			amount, err := money.Parse(str)
+			assert.Nil(t, err, str)
+			assert.Equal(t, expected, amount, str)
+		}
+
+		for _, str := range []string{"", "-", "1.2.3", "12a", "1,000"} {
+			_, err := money.Parse(str)
+			assert.NotNil(t, err, str)
+		}
+	})
+
+	t.Run("parse format float", func(t *testing.T) {
+		cases := map[string]money.Amount{
+			"162241.6702":   16224167,
+			" 8000 ":        800000,
+			"1.6224167e+05": 16224167,
+			"-5E2":          -50000,
+		}
+
+		for str, expected := range cases {
+			amount, err := money.ParseFloat(str)
+			assert.Nil(t, err, str)
+			assert.Equal(t, expected, amount, str)
+		}
+
+		_, err := money.ParseFloat("1,000")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("konversi balik ke float", func(t *testing.T) {
+		amount := money.FromFloat(-1234.56)
+		assert.Equal(t, -1234.56, amount.Float64())
+		assert.Equal(t, money.FromFloat(1234.56), amount.Abs())
+		assert.Equal(t, "-1234.56", amount.String())
+		assert.Equal(t, "0.05", money.Amount(5).String())
+	})
+
+	t.Run("json tetap angka rupiah", func(t *testing.T) {
+		data, err := json.Marshal(map[string]money.Amount{"amount": money.FromFloat(150000.5)})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"amount":150000.50}`, string(data))
+
+		// summary lama disimpan sebagai float
+		var hasil struct {
+			Amount money.Amount `json:"amount"`
+		}
+		for raw, expected := range map[string]money.Amount{
+			`{"amount":150000.5}`:  15000050,
+			`{"amount":-0.1}`:      -10,
+			`{"amount":1.5e+06}`:   150000000,
+			`{"amount":"2500.25"}`: 250025,
+			`{"amount":null}`:      0,
+		} {
+			err = json.Unmarshal([]byte(raw), &hasil)
+			assert.Nil(t, err, raw)
+			assert.Equal(t, expected, hasil.Amount, raw)
+		}
+	})
+
+	t.Run("scan kolom decimal", func(t *testing.T) {
+		cases := []struct {
+			value    interface{}
+			expected money.Amount
+		}{
+			{[]byte("18500.30"), 1850030},
+			{"-5000", -500000},
+			{1500.25, 150025},
+			{int64(7), 700},
+		}
+
+		for _, c := range cases {
+			var amount money.Amount
+			err := amount.Scan(c.value)
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, amount)
+		}
+
+		value, err := money.FromFloat(18500.3).Value()
+		assert.Nil(t, err)
+		assert.Equal(t, "18500.30", value)
+	})
+}
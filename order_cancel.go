@@ -0,0 +1,158 @@
+package withdrawal_service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pdcgo/schema/services/common/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/events/order_event"
+	"github.com/pdcgo/shared/interfaces/identity_iface"
+	"github.com/pdcgo/shared/pkg/streampipe"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/models"
+	"github.com/pdcgo/withdrawal_service/order_query"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/gorm"
+)
+
+type OrderCancelIterate interface {
+	Iterate(ctx context.Context, handler func(item *models.OrderCancel) error) error
+}
+
+func NewOrderCancelImporter(tipe common.MarketplaceType, data []byte) (OrderCancelIterate, error) {
+	switch tipe {
+	case common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE:
+		return datasource.NewShopeeOrderCancelXls(io.NopCloser(bytes.NewReader(data))), nil
+	case common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK:
+		return datasource.NewTiktokOrderCancelXls(io.NopCloser(bytes.NewReader(data))), nil
+	default:
+		return nil, wd_error.New(
+			wd_error.CodeUnsupportedImporter,
+			fmt.Sprintf("laporan pembatalan %s not supported", tipe),
+			wd_error.Params{"mp_type": tipe.String()},
+		)
+	}
+}
+
+// ImportOrderCancel order batal dibatalkan, order yang dikembalikan atau gagal kirim return nya dibuka.
+// hanya order milik marketplace yang dipilih yang diproses
+func ImportOrderCancel(
+	ctx context.Context,
+	db *gorm.DB,
+	agent identity_iface.Agent,
+	pub streampipe.PublishProvider,
+	teamID uint,
+	mpID uint,
+	importer OrderCancelIterate,
+) (*withdrawal_task_iface.OrderCancelSummary, error) {
+	summary := &withdrawal_task_iface.OrderCancelSummary{
+		NotFound: []string{},
+	}
+
+	refIDs := []string{}
+	cancelRefs := []string{}
+	returnRefs := []string{}
+	seen := map[string]bool{}
+
+	err := importer.Iterate(ctx, func(item *models.OrderCancel) error {
+		if seen[item.ExternalOrderID] {
+			return nil
+		}
+		seen[item.ExternalOrderID] = true
+		refIDs = append(refIDs, item.ExternalOrderID)
+
+		switch item.Kind {
+		case models.OrderCancelled:
+			cancelRefs = append(cancelRefs, item.ExternalOrderID)
+		case models.OrderReturned:
+			returnRefs = append(returnRefs, item.ExternalOrderID)
+		case models.OrderFailedDelivery:
+			cancelRefs = append(cancelRefs, item.ExternalOrderID)
+			returnRefs = append(returnRefs, item.ExternalOrderID)
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	if len(refIDs) == 0 {
+		return summary, nil
+	}
+
+	found := []string{}
+	err = db.
+		Model(&db_models.Order{}).
+		Where("team_id = ?", teamID).
+		Where("order_mp_id = ?", mpID).
+		Where("order_ref_id IN ?", refIDs).
+		Pluck("order_ref_id", &found).
+		Error
+	if err != nil {
+		return summary, err
+	}
+
+	foundMap := map[string]bool{}
+	for _, refID := range found {
+		foundMap[refID] = true
+	}
+	for _, refID := range refIDs {
+		if !foundMap[refID] {
+			summary.NotFound = append(summary.NotFound, refID)
+		}
+	}
+
+	onlyFound := func(refs []string) []string {
+		hasil := []string{}
+		for _, refID := range refs {
+			if foundMap[refID] {
+				hasil = append(hasil, refID)
+			}
+		}
+		return hasil
+	}
+	cancelRefs = onlyFound(cancelRefs)
+	returnRefs = onlyFound(returnRefs)
+
+	cancelIDs := []uint{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		query := order_query.NewOrderQuery(tx, agent, pub)
+
+		if len(cancelRefs) != 0 {
+			cancelIDs, err = query.ByRefIDs(teamID, cancelRefs).Cancel(mpID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(returnRefs) != 0 {
+			err = query.ByRefIDs(teamID, returnRefs).OpenReturn(mpID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	summary.Cancelled = int32(len(cancelIDs))
+	summary.ReturnOrders = int32(len(returnRefs))
+
+	// event dikirim setelah commit supaya rollback tidak ikut terkirim
+	for _, id := range cancelIDs {
+		pub.Send(streampipe.DEFAULT_TOPIC, &order_event.OrderEvent{
+			Action:  order_event.OrderChangeStatus,
+			Status:  db_models.OrdCancel,
+			OrderID: id,
+		})
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,800 @@
+package withdrawal_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/shared/authorization"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/events/order_event"
+	"github.com/pdcgo/shared/interfaces/identity_iface"
+	"github.com/pdcgo/shared/pkg/streampipe"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/order_query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	orderNotFoundBatchSize     = 200
+	orderNotFoundMatchInterval = time.Minute * 15
+	orderNotFoundMaxBackoff    = time.Hour * 24
+	orderNotFoundLockKey       = 7340021 // pg advisory lock, hanya satu instance yang mencocokkan ulang
+)
+
+// WdOrderNotFoundResolution riwayat entry wd_order_not_found yang sudah dicocokkan ulang ke order.
+// entry asli nya dihapus supaya order_not_found withdrawal tetap dihitung sama seperti importer
+type WdOrderNotFoundResolution struct {
+	ID           uint   `gorm:"primarykey"`
+	NotFoundID   uint   `gorm:"uniqueIndex"`
+	TeamID       uint   `gorm:"index:idx_wd_not_found_resolution_team"`
+	MpID         uint   `gorm:"index:idx_wd_not_found_resolution_team"`
+	WdID         uint   `gorm:"index"`
+	OrderRefID   string `gorm:"index"`
+	OrderID      uint
+	AdjustmentID uint
+	Type         db_models.AdjustmentType
+	Amount       float64
+	At           time.Time
+	ResolvedAt   time.Time
+}
+
+// WdOrderNotFoundCheck kapan entry wd_order_not_found dicek lagi, entry yang order nya belum masuk
+// dicek makin jarang supaya tidak semua entry discan ulang tiap interval
+type WdOrderNotFoundCheck struct {
+	NotFoundID  uint `gorm:"primarykey;autoIncrement:false"`
+	Attempt     int
+	CheckedAt   time.Time
+	NextCheckAt time.Time `gorm:"index"`
+}
+
+func orderNotFoundBackoff(attempt int) time.Duration {
+	backoff := orderNotFoundMatchInterval
+	for i := 1; i < attempt && backoff < orderNotFoundMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > orderNotFoundMaxBackoff {
+		return orderNotFoundMaxBackoff
+	}
+	return backoff
+}
+
+// ListOrderNotFound entry yang belum ketemu order nya, mpID dan wdID 0 berarti semua
+func ListOrderNotFound(db *gorm.DB, teamID, mpID, wdID uint, page, limit int) ([]*withdrawal_task_iface.OrderNotFound, int64, error) {
+	var err error
+	var total int64
+	hasil := []*withdrawal_task_iface.OrderNotFound{}
+
+	wdQuery := db.
+		Model(&db_models.Withdrawal{}).
+		Select("id").
+		Where("team_id = ?", teamID)
+	if mpID != 0 {
+		wdQuery = wdQuery.Where("mp_id = ?", mpID)
+	}
+	if wdID != 0 {
+		wdQuery = wdQuery.Where("id = ?", wdID)
+	}
+
+	query := db.
+		Model(&db_models.WdOrderNotFound{}).
+		Where("wd_id IN (?)", wdQuery).
+		Session(&gorm.Session{})
+
+	err = query.Count(&total).Error
+	if err != nil {
+		return hasil, total, err
+	}
+
+	entries := []*db_models.WdOrderNotFound{}
+	err = query.
+		Order("id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&entries).
+		Error
+	if err != nil {
+		return hasil, total, err
+	}
+
+	wds, err := findWithdrawals(db, entries)
+	if err != nil {
+		return hasil, total, err
+	}
+
+	for _, entry := range entries {
+		item := &withdrawal_task_iface.OrderNotFound{
+			Id:         uint64(entry.ID),
+			WdId:       uint64(entry.WdID),
+			OrderRefId: entry.OrderRefID,
+			Amount:     entry.Amount,
+			At:         entry.At.Unix(),
+		}
+		wd := wds[entry.WdID]
+		if wd != nil {
+			item.MpId = uint64(wd.MpID)
+			item.WithdrawalAt = wd.At.Unix()
+		}
+		hasil = append(hasil, item)
+	}
+
+	return hasil, total, nil
+}
+
+func findWithdrawals(db *gorm.DB, entries []*db_models.WdOrderNotFound) (map[uint]*db_models.Withdrawal, error) {
+	hasil := map[uint]*db_models.Withdrawal{}
+	if len(entries) == 0 {
+		return hasil, nil
+	}
+
+	wdIDs := []uint{}
+	seen := map[uint]bool{}
+	for _, entry := range entries {
+		if seen[entry.WdID] {
+			continue
+		}
+		seen[entry.WdID] = true
+		wdIDs = append(wdIDs, entry.WdID)
+	}
+
+	wds := []*db_models.Withdrawal{}
+	err := db.
+		Model(&db_models.Withdrawal{}).
+		Where("id IN ?", wdIDs).
+		Find(&wds).
+		Error
+	if err != nil {
+		return hasil, err
+	}
+
+	for _, wd := range wds {
+		hasil[wd.ID] = wd
+	}
+	return hasil, nil
+}
+
+// ResolveOrderNotFound mencocokkan ulang entry wd_order_not_found yang sudah waktunya dicek.
+// fund / adjustment, fund_at, WdValid dan completed order ditulis seperti saat import, per withdrawal satu transaksi
+func ResolveOrderNotFound(
+	ctx context.Context,
+	db *gorm.DB,
+	agent identity_iface.Agent,
+	pub streampipe.PublishProvider,
+) (int, error) {
+	var resolved int
+	var lastID uint
+
+	// entry yang sudah dihapus revert atau import ulang
+	err := db.
+		WithContext(ctx).
+		Where("not_found_id NOT IN (?)", db.Model(&db_models.WdOrderNotFound{}).Select("id")).
+		Delete(&WdOrderNotFoundCheck{}).
+		Error
+	if err != nil {
+		return resolved, err
+	}
+
+	for {
+		now := time.Now()
+		entries := []*db_models.WdOrderNotFound{}
+		err := db.
+			WithContext(ctx).
+			Model(&db_models.WdOrderNotFound{}).
+			Where("id > ?", lastID).
+			Where("order_ref_id != ?", "").
+			Where("id NOT IN (?)", db.
+				Model(&WdOrderNotFoundCheck{}).
+				Select("not_found_id").
+				Where("next_check_at > ?", now),
+			).
+			Order("id asc").
+			Limit(orderNotFoundBatchSize).
+			Find(&entries).
+			Error
+		if err != nil {
+			return resolved, err
+		}
+
+		if len(entries) == 0 {
+			return resolved, nil
+		}
+		lastID = entries[len(entries)-1].ID
+
+		wds, err := findWithdrawals(db.WithContext(ctx), entries)
+		if err != nil {
+			return resolved, err
+		}
+
+		byWd := map[uint][]*db_models.WdOrderNotFound{}
+		wdIDs := []uint{}
+		for _, entry := range entries {
+			if wds[entry.WdID] == nil {
+				continue
+			}
+			if byWd[entry.WdID] == nil {
+				wdIDs = append(wdIDs, entry.WdID)
+			}
+			byWd[entry.WdID] = append(byWd[entry.WdID], entry)
+		}
+
+		for _, wdID := range wdIDs {
+			count, err := resolveWdOrderNotFound(db.WithContext(ctx), agent, pub, wds[wdID], byWd[wdID])
+			if err != nil {
+				return resolved, err
+			}
+			resolved += count
+		}
+
+		err = markOrderNotFoundChecked(db.WithContext(ctx), entries, now)
+		if err != nil {
+			return resolved, err
+		}
+	}
+}
+
+// markOrderNotFoundChecked entry yang masih belum ketemu dijadwalkan cek berikutnya
+func markOrderNotFoundChecked(db *gorm.DB, entries []*db_models.WdOrderNotFound, now time.Time) error {
+	ids := []uint{}
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	existIDs := []uint{}
+	err := db.
+		Model(&db_models.WdOrderNotFound{}).
+		Where("id IN ?", ids).
+		Pluck("id", &existIDs).
+		Error
+	if err != nil || len(existIDs) == 0 {
+		return err
+	}
+
+	checks := []*WdOrderNotFoundCheck{}
+	err = db.
+		Model(&WdOrderNotFoundCheck{}).
+		Where("not_found_id IN ?", existIDs).
+		Find(&checks).
+		Error
+	if err != nil {
+		return err
+	}
+
+	attempts := map[uint]int{}
+	for _, check := range checks {
+		attempts[check.NotFoundID] = check.Attempt
+	}
+
+	checks = []*WdOrderNotFoundCheck{}
+	for _, id := range existIDs {
+		attempt := attempts[id] + 1
+		checks = append(checks, &WdOrderNotFoundCheck{
+			NotFoundID:  id,
+			Attempt:     attempt,
+			CheckedAt:   now,
+			NextCheckAt: now.Add(orderNotFoundBackoff(attempt)),
+		})
+	}
+
+	return db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "not_found_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"attempt", "checked_at", "next_check_at"}),
+		}).
+		Create(&checks).
+		Error
+}
+
+func resolveWdOrderNotFound(
+	db *gorm.DB,
+	agent identity_iface.Agent,
+	pub streampipe.PublishProvider,
+	wd *db_models.Withdrawal,
+	entries []*db_models.WdOrderNotFound,
+) (int, error) {
+	var resolved int
+
+	refIDs := []string{}
+	for _, entry := range entries {
+		refIDs = append(refIDs, entry.OrderRefID)
+	}
+
+	found := []string{}
+	err := db.
+		Model(&db_models.Order{}).
+		Where("team_id = ?", wd.TeamID).
+		Where("order_mp_id = ?", wd.MpID).
+		Where("order_ref_id IN ?", refIDs).
+		Where("status != ?", db_models.OrdCancel).
+		Pluck("order_ref_id", &found).
+		Error
+	if err != nil {
+		return resolved, err
+	}
+
+	if len(found) == 0 {
+		return resolved, nil
+	}
+
+	foundMap := map[string]bool{}
+	for _, refID := range found {
+		foundMap[refID] = true
+	}
+
+	matched := []*db_models.WdOrderNotFound{}
+	for _, entry := range entries {
+		if foundMap[entry.OrderRefID] {
+			matched = append(matched, entry)
+		}
+	}
+
+	types, err := notFoundTypes(db, wd)
+	if err != nil {
+		return resolved, err
+	}
+
+	taskID, err := notFoundTaskID(db, matched)
+	if err != nil {
+		return resolved, err
+	}
+	journal := newTaskJournal(taskID)
+
+	completeIDs := []uint{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		query := order_query.NewOrderQuery(tx, agent, pub).ByMpRefIDs(wd.TeamID, wd.MpID, found)
+		err := query.Lock()
+		if err != nil {
+			return err
+		}
+
+		// entry bisa sudah dihapus import ulang setelah dibaca
+		ids := []uint{}
+		for _, entry := range matched {
+			ids = append(ids, entry.ID)
+		}
+		existIDs := []uint{}
+		err = tx.
+			Model(&db_models.WdOrderNotFound{}).
+			Where("id IN ?", ids).
+			Pluck("id", &existIDs).
+			Error
+		if err != nil {
+			return err
+		}
+		exist := map[uint]bool{}
+		for _, id := range existIDs {
+			exist[id] = true
+		}
+
+		entries := []*db_models.WdOrderNotFound{}
+		logs := []*order_query.AdjustmentLog{}
+		for _, entry := range matched {
+			if !exist[entry.ID] {
+				continue
+			}
+			entries = append(entries, entry)
+			logs = append(logs, &order_query.AdjustmentLog{
+				RefID:  entry.OrderRefID,
+				Type:   types.get(entry),
+				At:     entry.At,
+				FundAt: wd.At,
+				Amount: entry.Amount,
+				Desc:   "dicocokkan ulang dari order tidak ditemukan",
+			})
+		}
+
+		if len(logs) == 0 {
+			return nil
+		}
+
+		orderIDs := []uint{}
+		err = tx.
+			Model(&db_models.Order{}).
+			Where("team_id = ?", wd.TeamID).
+			Where("order_mp_id = ?", wd.MpID).
+			Where("order_ref_id IN ?", found).
+			Pluck("id", &orderIDs).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// wd_total dan adjustment order dihitung ulang di LogAdjustments, nilai lama dicatat untuk revert
+		err = journal.Snapshot(tx, ChangeOrder, orderIDs, "wd_total", "adjustment")
+		if err != nil {
+			return err
+		}
+
+		err = query.LogAdjustments(logs)
+		if err != nil {
+			return err
+		}
+
+		var amount money.Amount
+		adjIDs := []uint{}
+		resolutions := []*WdOrderNotFoundResolution{}
+		resolvedIDs := []uint{}
+		now := time.Now()
+		for idx, log := range logs {
+			if log.NotFound {
+				continue
+			}
+			entry := entries[idx]
+
+			if log.Prev == nil {
+				err = journal.Created(tx, ChangeOrderAdjustment, log.ID)
+			} else {
+				err = journal.Updated(tx, ChangeOrderAdjustment, log.ID, map[string]interface{}{
+					"amount":  log.Prev.Amount,
+					"at":      log.Prev.At,
+					"fund_at": log.Prev.FundAt,
+				})
+			}
+			if err != nil {
+				return err
+			}
+
+			var orderID uint
+			err = tx.
+				Model(&db_models.OrderAdjustment{}).
+				Select("order_id").
+				Where("id = ?", log.ID).
+				Find(&orderID).
+				Error
+			if err != nil {
+				return err
+			}
+
+			adjIDs = append(adjIDs, log.ID)
+			resolvedIDs = append(resolvedIDs, entry.ID)
+			amount += money.FromFloat(entry.Amount)
+			resolutions = append(resolutions, &WdOrderNotFoundResolution{
+				NotFoundID:   entry.ID,
+				TeamID:       wd.TeamID,
+				MpID:         wd.MpID,
+				WdID:         wd.ID,
+				OrderRefID:   entry.OrderRefID,
+				OrderID:      orderID,
+				AdjustmentID: log.ID,
+				Type:         log.Type,
+				Amount:       entry.Amount,
+				At:           entry.At,
+				ResolvedAt:   now,
+			})
+		}
+
+		if len(adjIDs) == 0 {
+			return nil
+		}
+
+		err = journal.Snapshot(tx, ChangeOrderAdjustment, adjIDs, "fund_at")
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Model(&db_models.OrderAdjustment{}).
+			Where("id IN ?", adjIDs).
+			Update("fund_at", wd.At).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = createWdValids(tx, journal, wd.ID, adjIDs)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&resolutions).Error
+		if err != nil {
+			return err
+		}
+
+		resolutionIDs := []uint{}
+		for _, res := range resolutions {
+			resolutionIDs = append(resolutionIDs, res.ID)
+		}
+		err = journal.Created(tx, ChangeWdOrderNotFoundResolution, resolutionIDs...)
+		if err != nil {
+			return err
+		}
+
+		if journal.enabled() {
+			rows := []map[string]interface{}{}
+			err = tx.
+				Model(&db_models.WdOrderNotFound{}).
+				Where("id IN ?", resolvedIDs).
+				Find(&rows).
+				Error
+			if err != nil {
+				return err
+			}
+
+			err = journal.Deleted(tx, ChangeWdOrderNotFound, rows)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.
+			Unscoped().
+			Where("id IN ?", resolvedIDs).
+			Delete(&db_models.WdOrderNotFound{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Where("not_found_id IN ?", resolvedIDs).
+			Delete(&WdOrderNotFoundCheck{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = journal.Snapshot(tx, ChangeWithdrawal, []uint{wd.ID}, "order_valid", "order_not_found", "diff_amount")
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Model(&db_models.Withdrawal{}).
+			Where("id = ?", wd.ID).
+			Updates(map[string]interface{}{
+				"order_valid": tx.
+					Model(&db_models.WdValid{}).
+					Select("COUNT(*)").
+					Where("withdrawal_id = ?", wd.ID),
+				"order_not_found": tx.
+					Model(&db_models.WdOrderNotFound{}).
+					Select("COUNT(*)").
+					Where("wd_id = ?", wd.ID),
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// sama seperti importer, withdrawal yang sudah balance tidak di increment
+		var diffAmount float64
+		err = tx.
+			Model(&db_models.Withdrawal{}).
+			Select("diff_amount").
+			Where("id = ?", wd.ID).
+			Find(&diffAmount).
+			Error
+		if err != nil {
+			return err
+		}
+		if diffAmount != 0 {
+			err = order_query.
+				NewFinDataQuery(agent, tx).
+				WithdrawalByID(wd.ID).
+				IncActualAmount(amount.Float64())
+			if err != nil {
+				return err
+			}
+		}
+
+		resolvedRefs := []string{}
+		for _, res := range resolutions {
+			resolvedRefs = append(resolvedRefs, res.OrderRefID)
+		}
+		completeIDs, err = completeOrders(
+			tx,
+			journal,
+			order_query.NewOrderQuery(tx, agent, pub).ByMpRefIDs(wd.TeamID, wd.MpID, resolvedRefs),
+			wd.TeamID,
+			wd.MpID,
+			resolvedRefs,
+			wd.At,
+		)
+		if err != nil {
+			return err
+		}
+
+		resolved = len(resolutions)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// event dikirim setelah commit supaya rollback tidak ikut terkirim
+	for _, id := range completeIDs {
+		pub.Send(streampipe.DEFAULT_TOPIC, &order_event.OrderEvent{
+			Action:  order_event.OrderChangeStatus,
+			Status:  db_models.OrdCompleted,
+			OrderID: id,
+		})
+	}
+
+	return resolved, nil
+}
+
+// notFoundTaskID task terakhir yang membuat entry, perubahan pencocokan ulang dicatat di task itu
+// supaya revert task ikut membatalkan hasil pencocokan. 0 kalau entry bukan dari task
+func notFoundTaskID(db *gorm.DB, entries []*db_models.WdOrderNotFound) (uint, error) {
+	var taskID uint
+	if len(entries) == 0 {
+		return taskID, nil
+	}
+
+	ids := []uint{}
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+
+	err := db.
+		Model(&TaskChange{}).
+		Select("coalesce(max(task_id), 0)").
+		Where("entity = ?", ChangeWdOrderNotFound).
+		Where("action = ?", ChangeCreate).
+		Where("entity_id IN ?", ids).
+		Scan(&taskID).
+		Error
+	return taskID, err
+}
+
+// createWdValids hanya wd_valid yang belum ada yang dibuat dan dicatat, supaya revert tidak menghapus milik import
+func createWdValids(tx *gorm.DB, journal *taskJournal, wdID uint, adjIDs []uint) error {
+	existIDs := []uint{}
+	err := tx.
+		Model(&db_models.WdValid{}).
+		Where("withdrawal_id = ?", wdID).
+		Where("order_adjustment_id IN ?", adjIDs).
+		Pluck("order_adjustment_id", &existIDs).
+		Error
+	if err != nil {
+		return err
+	}
+	exist := map[uint]bool{}
+	for _, id := range existIDs {
+		exist[id] = true
+	}
+
+	for _, id := range adjIDs {
+		if exist[id] {
+			continue
+		}
+		exist[id] = true
+
+		err = tx.
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "withdrawal_id"}, {Name: "order_adjustment_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"withdrawal_id", "order_adjustment_id"}),
+			}).
+			Create(&db_models.WdValid{
+				WithdrawalID:      wdID,
+				OrderAdjustmentID: id,
+			}).
+			Error
+		if err != nil {
+			return fmt.Errorf("%s id order %d wd %d", err.Error(), id, wdID)
+		}
+
+		err = journal.CreatedRow(tx, ChangeWdValid, map[string]interface{}{
+			"withdrawal_id":       wdID,
+			"order_adjustment_id": id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tipe adjustment tidak disimpan di wd_order_not_found, diambil dari earning unmatched rekonsiliasi terakhir
+type notFoundTypeMap map[string]db_models.AdjustmentType
+
+var knownAdjustmentTypes = []db_models.AdjustmentType{
+	db_models.AdjOrderFund,
+	db_models.AdjCommision,
+	db_models.AdjCompensation,
+	db_models.AdjLostCompensation,
+	db_models.AdjReturn,
+	db_models.AdjShipping,
+	db_models.AdjUnknownAdj,
+	db_models.AdjUnknown,
+}
+
+func notFoundTypeKey(refID string, amount money.Amount) string {
+	return fmt.Sprintf("%s|%d", refID, amount)
+}
+
+func notFoundTypes(db *gorm.DB, wd *db_models.Withdrawal) (notFoundTypeMap, error) {
+	hasil := notFoundTypeMap{}
+
+	rec, err := GetReconciliation(db, ReconciliationV1, wd.TeamID, wd.ID)
+	if errors.Is(err, ErrReconciliationNotFound) {
+		return hasil, nil
+	}
+	if err != nil {
+		return hasil, err
+	}
+
+	typeNames := map[string]db_models.AdjustmentType{}
+	for _, tipe := range knownAdjustmentTypes {
+		typeNames[fmt.Sprint(tipe)] = tipe
+	}
+
+	for _, earning := range rec.Unmatched.Data() {
+		tipe, ok := typeNames[earning.Type]
+		if !ok {
+			continue
+		}
+		hasil[notFoundTypeKey(earning.OrderRefId, earning.Amount)] = tipe
+	}
+
+	return hasil, nil
+}
+
+// get fallback ke order fund untuk pemasukan dan adjustment untuk potongan
+func (m notFoundTypeMap) get(entry *db_models.WdOrderNotFound) db_models.AdjustmentType {
+	tipe, ok := m[notFoundTypeKey(entry.OrderRefID, money.FromFloat(entry.Amount))]
+	if ok {
+		return tipe
+	}
+
+	if entry.Amount < 0 {
+		return db_models.AdjUnknownAdj
+	}
+	return db_models.AdjOrderFund
+}
+
+// runOrderNotFoundMatcher job background pencocokan ulang order tidak ditemukan
+func (r *runner) runOrderNotFoundMatcher(ctx context.Context) {
+	tick := time.NewTicker(orderNotFoundMatchInterval)
+	defer tick.Stop()
+
+	agent := NewV2ImporterAgent(&authorization.JwtIdentity{
+		From: "withdrawal_service",
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			resolved, err := r.resolveOrderNotFoundLeader(ctx, agent)
+			if err != nil {
+				slog.Error(err.Error(), slog.String("function", "order_not_found_matcher"))
+				continue
+			}
+			if resolved != 0 {
+				slog.Info("order not found resolved", slog.Int("count", resolved))
+			}
+		}
+	}
+}
+
+// resolveOrderNotFoundLeader di postgres hanya instance yang dapat advisory lock yang jalan,
+// lock dilepas saat transaksi lock selesai atau koneksi putus
+func (r *runner) resolveOrderNotFoundLeader(ctx context.Context, agent identity_iface.Agent) (int, error) {
+	if r.db.Dialector.Name() != "postgres" {
+		return ResolveOrderNotFound(ctx, r.db, agent, r.pub)
+	}
+
+	lock := r.db.WithContext(ctx).Begin()
+	if lock.Error != nil {
+		return 0, lock.Error
+	}
+	defer lock.Rollback()
+
+	var leader bool
+	err := lock.
+		Raw("SELECT pg_try_advisory_xact_lock(?)", orderNotFoundLockKey).
+		Scan(&leader).
+		Error
+	if err != nil || !leader {
+		return 0, err
+	}
+
+	return ResolveOrderNotFound(ctx, r.db, agent, r.pub)
+}
@@ -0,0 +1,240 @@
+package withdrawal_service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/shared/authorization"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/pdcgo/withdrawal_service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestResolveOrderNotFound(t *testing.T) {
+	var db gorm.DB
+	wdAt := time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	moretest.Suite(t, "cocokkan ulang order tidak ditemukan",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(
+					&db_models.Withdrawal{},
+					&db_models.WdOrderNotFound{},
+					&db_models.WdValid{},
+					&db_models.Order{},
+					&db_models.OrderAdjustment{},
+					&db_models.OrderTimestamp{},
+					&db_models.InvTransaction{},
+					&db_models.InvTimestamp{},
+					&withdrawal_service.WithdrawalReconciliation{},
+					&withdrawal_service.WdOrderNotFoundResolution{},
+					&withdrawal_service.WdOrderNotFoundCheck{},
+					&withdrawal_service.TaskItem{},
+					&withdrawal_service.TaskChange{},
+					&withdrawal_service.FileFingerprint{},
+				)
+				assert.Nil(t, err)
+
+				err = db.Create(&db_models.Withdrawal{
+					ID:         1,
+					TeamID:     1,
+					MpID:       1,
+					At:         wdAt,
+					DiffAmount: -25000,
+				}).Error
+				assert.Nil(t, err)
+
+				notFounds := []*db_models.WdOrderNotFound{
+					{ID: 1, WdID: 1, OrderRefID: "ORD001", Amount: 25000, At: wdAt.AddDate(0, 0, -2)},
+					{ID: 2, WdID: 1, OrderRefID: "ORD002", Amount: 12000, At: wdAt.AddDate(0, 0, -2)},
+				}
+				err = db.Create(&notFounds).Error
+				assert.Nil(t, err)
+
+				// ORD002 dibuat task 5, hasil pencocokan nya ikut dicatat di task itu
+				err = db.Create(&withdrawal_service.TaskItem{
+					ID: 5,
+					TaskItem: &withdrawal_iface.TaskItem{
+						TeamId: 1,
+						MpId:   1,
+						Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+					},
+				}).Error
+				assert.Nil(t, err)
+
+				err = db.Create(&withdrawal_service.TaskChange{
+					TaskID:   5,
+					Entity:   withdrawal_service.ChangeWdOrderNotFound,
+					Action:   withdrawal_service.ChangeCreate,
+					EntityID: 2,
+				}).Error
+				assert.Nil(t, err)
+
+				// order sudah completed supaya tidak ada event yang dikirim
+				err = db.Create(&db_models.Order{
+					ID:         1,
+					TeamID:     1,
+					OrderRefID: "ORD001",
+					OrderMpID:  1,
+					Status:     db_models.OrdCompleted,
+				}).Error
+				assert.Nil(t, err)
+
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			agent := withdrawal_service.NewV2ImporterAgent(&authorization.JwtIdentity{
+				From: "withdrawal_service",
+			})
+
+			t.Run("list entry per team", func(t *testing.T) {
+				items, total, err := withdrawal_service.ListOrderNotFound(&db, 1, 1, 0, 1, 10)
+				assert.Nil(t, err)
+				assert.Equal(t, int64(2), total)
+				assert.Len(t, items, 2)
+				assert.Equal(t, wdAt.Unix(), items[0].WithdrawalAt)
+
+				_, total, err = withdrawal_service.ListOrderNotFound(&db, 2, 0, 0, 1, 10)
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), total)
+			})
+
+			t.Run("order yang sudah masuk dicocokkan", func(t *testing.T) {
+				resolved, err := withdrawal_service.ResolveOrderNotFound(context.Background(), &db, agent, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, resolved)
+
+				adj := db_models.OrderAdjustment{}
+				err = db.Model(&db_models.OrderAdjustment{}).Where("order_id = ?", 1).First(&adj).Error
+				assert.Nil(t, err)
+				assert.Equal(t, db_models.AdjOrderFund, adj.Type)
+				assert.Equal(t, float64(25000), adj.Amount)
+
+				var count int64
+				err = db.Model(&db_models.WdValid{}).Where("withdrawal_id = ?", 1).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(1), count)
+
+				res := withdrawal_service.WdOrderNotFoundResolution{}
+				err = db.Model(&withdrawal_service.WdOrderNotFoundResolution{}).Where("not_found_id = ?", 1).First(&res).Error
+				assert.Nil(t, err)
+				assert.Equal(t, uint(1), res.OrderID)
+				assert.Equal(t, adj.ID, res.AdjustmentID)
+
+				items, total, err := withdrawal_service.ListOrderNotFound(&db, 1, 0, 1, 1, 10)
+				assert.Nil(t, err)
+				assert.Equal(t, int64(1), total)
+				assert.Equal(t, "ORD002", items[0].OrderRefId)
+
+				wd := db_models.Withdrawal{}
+				err = db.Model(&db_models.Withdrawal{}).First(&wd, 1).Error
+				assert.Nil(t, err)
+				assert.Equal(t, float64(0), wd.DiffAmount)
+			})
+
+			t.Run("jalan ulang tidak menulis dua kali", func(t *testing.T) {
+				resolved, err := withdrawal_service.ResolveOrderNotFound(context.Background(), &db, agent, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, 0, resolved)
+			})
+
+			t.Run("entry yang belum ketemu dicek lagi setelah jadwal nya", func(t *testing.T) {
+				check := withdrawal_service.WdOrderNotFoundCheck{}
+				err := db.Model(&withdrawal_service.WdOrderNotFoundCheck{}).Where("not_found_id = ?", 2).First(&check).Error
+				assert.Nil(t, err)
+				assert.Equal(t, 1, check.Attempt)
+				assert.True(t, check.NextCheckAt.After(time.Now()))
+
+				err = db.Create(&db_models.Order{
+					ID:         2,
+					TeamID:     1,
+					OrderRefID: "ORD002",
+					OrderMpID:  1,
+					Status:     db_models.OrdCompleted,
+				}).Error
+				assert.Nil(t, err)
+
+				resolved, err := withdrawal_service.ResolveOrderNotFound(context.Background(), &db, agent, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, 0, resolved)
+
+				err = db.
+					Model(&withdrawal_service.WdOrderNotFoundCheck{}).
+					Where("not_found_id = ?", 2).
+					Update("next_check_at", time.Now().Add(-time.Minute)).
+					Error
+				assert.Nil(t, err)
+
+				resolved, err = withdrawal_service.ResolveOrderNotFound(context.Background(), &db, agent, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, resolved)
+
+				var count int64
+				err = db.Model(&withdrawal_service.WdOrderNotFoundCheck{}).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+			})
+
+			t.Run("order marketplace lain tidak dicocokkan", func(t *testing.T) {
+				err := db.Create(&db_models.WdOrderNotFound{ID: 3, WdID: 1, OrderRefID: "ORD003", Amount: 5000, At: wdAt}).Error
+				assert.Nil(t, err)
+				err = db.Create(&db_models.Order{
+					ID:         3,
+					TeamID:     1,
+					OrderRefID: "ORD003",
+					OrderMpID:  2,
+					Status:     db_models.OrdCompleted,
+				}).Error
+				assert.Nil(t, err)
+
+				resolved, err := withdrawal_service.ResolveOrderNotFound(context.Background(), &db, agent, nil)
+				assert.Nil(t, err)
+				assert.Equal(t, 0, resolved)
+
+				var count int64
+				err = db.Model(&db_models.OrderAdjustment{}).Where("order_id = ?", 3).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+			})
+
+			t.Run("revert task ikut membatalkan hasil pencocokan", func(t *testing.T) {
+				var count int64
+				err := db.Model(&withdrawal_service.TaskChange{}).Where("task_id = ?", 5).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Greater(t, count, int64(1))
+
+				_, _, err = withdrawal_service.NewTempStore(&db).Revert(1, 5, 10)
+				assert.Nil(t, err)
+
+				err = db.Model(&withdrawal_service.WdOrderNotFoundResolution{}).Where("not_found_id = ?", 2).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+
+				err = db.Model(&db_models.OrderAdjustment{}).Where("order_id = ?", 2).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+
+				err = db.Model(&db_models.WdValid{}).Where("withdrawal_id = ?", 1).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(1), count)
+
+				// entry dibuat task 5, ikut hilang bersama task nya
+				err = db.Model(&db_models.WdOrderNotFound{}).Where("id = ?", 2).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+
+				// hasil pencocokan entry dari import tanpa task tetap ada
+				err = db.Model(&withdrawal_service.WdOrderNotFoundResolution{}).Where("not_found_id = ?", 1).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(1), count)
+			})
+		},
+	)
+}
@@ -19,7 +19,8 @@ type financeImpl struct {
 
 // DataQuery implements Finance.
 func (f *financeImpl) DataQuery(agent identity_iface.Agent, tx *gorm.DB) FinDataQuery {
-	return NewFinDataQuery(f.agent, f.tx)
+	// pakai tx dari pemanggil supaya ikut transaksi set withdrawal
+	return NewFinDataQuery(agent, tx)
 }
 
 // MpWithdrawal implements Finance.
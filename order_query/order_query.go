@@ -1,13 +1,14 @@
 package order_query
 
 import (
-	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/interfaces/identity_iface"
 	"github.com/pdcgo/shared/pkg/streampipe"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -31,19 +32,46 @@ func (mpres *HaveMarketplaceRes) GetError() error {
 	}
 
 	if mpres.ValidCount == 0 {
-		return fmt.Errorf("marketplace yang dipilih kemungkinan salah karena valid count order 0")
+		return wd_error.New(wd_error.CodeMarketplaceMismatch, "marketplace yang dipilih kemungkinan salah karena valid count order 0", wd_error.Params{
+			"valid_count": "0",
+		})
 	}
 
 	if mpres.InvalidCount != 0 {
-		return fmt.Errorf("ada %d order tidak valid karena memiliki marketplace yang berbeda", mpres.InvalidCount)
+		return wd_error.New(
+			wd_error.CodeMarketplaceMismatch,
+			fmt.Sprintf("ada %d order tidak valid karena memiliki marketplace yang berbeda", mpres.InvalidCount),
+			wd_error.Params{
+				"invalid_count": strconv.Itoa(mpres.InvalidCount),
+			},
+		)
 	}
 
 	return nil
 }
 
+// AdjustmentLog satu baris adjustment untuk LogAdjustments, ID, NotFound dan Prev diisi setelah ditulis
+type AdjustmentLog struct {
+	RefID  string
+	Type   db_models.AdjustmentType
+	At     time.Time
+	FundAt time.Time
+	Amount float64
+	Desc   string
+
+	ID       uint
+	NotFound bool
+	Prev     *db_models.OrderAdjustment // nil kalau adjustment baru dibuat
+}
+
 type OrderRefIDsQuery interface {
 	Lock() error
-	Completed(at time.Time) error
+	Completed(at time.Time) ([]uint, error)
+	CompletedRefIDs() ([]string, error)
+	Cancel(mpID uint) ([]uint, error)
+	OpenReturn(mpID uint) error
+	Shipped(mpID uint, at time.Time) ([]uint, error)
+	LogAdjustments(logs []*AdjustmentLog) error
 	// GetIDs() ([]uint, error)
 	HaveMarketplace(mpID uint) *HaveMarketplaceRes
 	ChangeMarketplace(mpID uint) error
@@ -55,6 +83,7 @@ type OrderQuery interface {
 
 	ByRefID(teamID uint, refID string) OrderDataQuery
 	ByRefIDs(teamID uint, refIDs []string) OrderRefIDsQuery
+	ByMpRefIDs(teamID uint, mpID uint, refIDs []string) OrderRefIDsQuery
 
 	// OrderItemByQuery(q func(qdb *gorm.DB) *gorm.DB) OrderItemByQuery
 }
@@ -84,6 +113,18 @@ func (o *orderQueryImpl) ByRefIDs(teamID uint, refIDs []string) OrderRefIDsQuery
 	}
 }
 
+// ByMpRefIDs implements OrderQuery, sama dengan ByRefIDs tapi hanya order milik mpID
+func (o *orderQueryImpl) ByMpRefIDs(teamID uint, mpID uint, refIDs []string) OrderRefIDsQuery {
+	return &orderByRefIDsImpl{
+		teamID: teamID,
+		mpID:   mpID,
+		refIDs: refIDs,
+		tx:     o.tx,
+		agent:  o.agent,
+		pub:    o.pub,
+	}
+}
+
 // ByRefID implements OrderQuery.
 func (o *orderQueryImpl) ByRefID(teamID uint, refID string) OrderDataQuery {
 	return &orderByRefIDImpl{
@@ -309,7 +350,7 @@ func (m *mpByIDImpl) DeleteWithdrawal(wdID uint) error {
 		return err
 	}
 
-	err = m.tx.Model(&db_models.AssetHistory{}).Where("id = ?", histID).Error
+	err = m.tx.Where("id = ?", histID).Delete(&db_models.AssetHistory{}).Error
 	if err != nil {
 		return err
 	}
@@ -326,7 +367,7 @@ func (m *mpByIDImpl) Lock() error {
 	}
 
 	if id == 0 {
-		return errors.New("cannot lock marketplace not found")
+		return wd_error.New(wd_error.CodeMarketplaceNotFound, "cannot lock marketplace not found", nil)
 	}
 	return nil
 }
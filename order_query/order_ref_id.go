@@ -7,6 +7,7 @@ import (
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/interfaces/identity_iface"
 	"github.com/pdcgo/shared/pkg/streampipe"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -65,7 +66,14 @@ func (o *orderByRefIDImpl) LogAdjustment(tipe db_models.AdjustmentType, at time.
 	}
 
 	if ord.OrderMpID == 0 {
-		return 0, fmt.Errorf("refmode order with id %s marketplace not set %d with receipt %s with ref %s", ord.OrderRefID, ord.ID, ord.Receipt, o.refID)
+		return 0, wd_error.New(
+			wd_error.CodeOrderMarketplaceNotSet,
+			fmt.Sprintf("refmode order with id %s marketplace not set %d with receipt %s with ref %s", ord.OrderRefID, ord.ID, ord.Receipt, o.refID),
+			wd_error.Params{
+				"order_ref_id": ord.OrderRefID,
+				"receipt":      ord.Receipt,
+			},
+		)
 	}
 
 	adj, err := o.getAdjustment(&ord, tipe)
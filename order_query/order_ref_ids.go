@@ -1,20 +1,21 @@
 package order_query
 
 import (
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
-	"github.com/pdcgo/shared/events/order_event"
 	"github.com/pdcgo/shared/interfaces/identity_iface"
 	"github.com/pdcgo/shared/pkg/streampipe"
 	"github.com/pdcgo/withdrawal_service/inventory_query"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type orderByRefIDsImpl struct {
 	teamID uint
+	mpID   uint // 0 berarti order semua marketplace
 	refIDs []string
 	tx     *gorm.DB
 	agent  identity_iface.Agent
@@ -50,25 +51,26 @@ func (o *orderByRefIDsImpl) HaveMarketplace(mpID uint) *HaveMarketplaceRes {
 
 	if hasil.Err == nil {
 		if hasil.ValidCount == 0 && hasil.InvalidCount == 0 {
-			hasil.Err = errors.New("does not have any order in refids query")
+			hasil.Err = wd_error.New(wd_error.CodeOrderRefNotFound, "does not have any order in refids query", nil)
 		}
 	}
 	return &hasil
 }
 
-// Completed implements OrderRefIDsQuery.
-func (o *orderByRefIDsImpl) Completed(at time.Time) error {
+// Completed implements OrderRefIDsQuery, mengembalikan id order yang di set completed.
+// event order tidak dikirim di sini, pemanggil kirim setelah transaksi commit
+func (o *orderByRefIDsImpl) Completed(at time.Time) ([]uint, error) {
 	err := o.buildQuery(false).
 		Updates(map[string]interface{}{
 			"wd_fund":    true,
 			"wd_fund_at": at,
 		}).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
 	completeIDs, err := o.completedIDs()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = o.buildQuery(false).
@@ -83,29 +85,291 @@ func (o *orderByRefIDsImpl) Completed(at time.Time) error {
 		Error
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = o.setLogCompleted(completeIDs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// canceling return tx id
 	err = o.setReturnToCancel(completeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return completeIDs, nil
+}
+
+// Cancel implements OrderRefIDsQuery, hanya order milik mpID, order yang sudah selesai atau sudah dicairkan tidak dibatalkan.
+// event order tidak dikirim di sini, pemanggil kirim setelah transaksi commit
+func (o *orderByRefIDsImpl) Cancel(mpID uint) ([]uint, error) {
+	cancelIDs := []uint{}
+	err := o.buildQuery(true).
+		Where("order_mp_id = ?", mpID).
+		Where("wd_fund = ?", false).
+		Where("status NOT IN ?", []db_models.OrdStatus{
+			db_models.OrdReturnCompleted,
+			db_models.OrdCancel,
+			db_models.OrdCompleted,
+		}).
+		Select("id").
+		Find(&cancelIDs).
+		Error
+	if err != nil {
+		return cancelIDs, err
+	}
+
+	if len(cancelIDs) == 0 {
+		return cancelIDs, nil
+	}
+
+	err = o.tx.
+		Model(&db_models.Order{}).
+		Where("id IN ?", cancelIDs).
+		Update("status", db_models.OrdCancel).
+		Error
+	if err != nil {
+		return cancelIDs, err
+	}
+
+	for _, id := range cancelIDs {
+		ts := db_models.OrderTimestamp{
+			OrderID:     id,
+			UserID:      o.agent.GetUserID(),
+			OrderStatus: db_models.OrdCancel,
+			Timestamp:   time.Now(),
+			From:        o.agent.GetAgentType(),
+		}
+		err = o.tx.Save(&ts).Error
+		if err != nil {
+			return cancelIDs, err
+		}
+	}
+
+	return cancelIDs, nil
+}
+
+// OpenReturn implements OrderRefIDsQuery, return tx order milik mpID dibuka lagi supaya barang yang kembali bisa diterima gudang
+func (o *orderByRefIDsImpl) OpenReturn(mpID uint) error {
+	txIDs := []uint{}
+	err := o.buildQuery(false).
+		Where("order_mp_id = ?", mpID).
+		Where("invertory_return_tx_id IS NOT NULL").
+		Where("status != ?", db_models.OrdReturnCompleted).
+		Distinct("invertory_return_tx_id").
+		Pluck("invertory_return_tx_id", &txIDs).
+		Error
 	if err != nil {
 		return err
 	}
 
-	for _, com := range completeIDs {
-		o.pub.Send(streampipe.DEFAULT_TOPIC, &order_event.OrderEvent{
-			Action:  order_event.OrderChangeStatus,
-			Status:  db_models.OrdCompleted,
-			OrderID: com,
-		})
+	if len(txIDs) == 0 {
+		return nil
 	}
 
-	return nil
+	retQuery := inventory_query.NewDataQuery(o.tx, o.agent).ReturnByIDs(o.teamID, txIDs)
+	return retQuery.Open()
+}
+
+// Shipped implements OrderRefIDsQuery, hanya order milik mpID, order yang sudah dikirim, selesai atau batal tidak diubah.
+// event order tidak dikirim di sini, pemanggil kirim setelah transaksi commit
+func (o *orderByRefIDsImpl) Shipped(mpID uint, at time.Time) ([]uint, error) {
+	shippedIDs := []uint{}
+	err := o.buildQuery(true).
+		Where("order_mp_id = ?", mpID).
+		Where("status NOT IN ?", []db_models.OrdStatus{
+			db_models.OrdSent,
+			db_models.OrdReturnCompleted,
+			db_models.OrdProblem,
+			db_models.OrdCancel,
+			db_models.OrdCompleted,
+		}).
+		Select("id").
+		Find(&shippedIDs).
+		Error
+	if err != nil {
+		return shippedIDs, err
+	}
+
+	if len(shippedIDs) == 0 {
+		return shippedIDs, nil
+	}
+
+	err = o.tx.
+		Model(&db_models.Order{}).
+		Where("id IN ?", shippedIDs).
+		Update("status", db_models.OrdSent).
+		Error
+	if err != nil {
+		return shippedIDs, err
+	}
+
+	for _, id := range shippedIDs {
+		ts := db_models.OrderTimestamp{
+			OrderID:     id,
+			UserID:      o.agent.GetUserID(),
+			OrderStatus: db_models.OrdSent,
+			Timestamp:   at,
+			From:        o.agent.GetAgentType(),
+		}
+		err = o.tx.Save(&ts).Error
+		if err != nil {
+			return shippedIDs, err
+		}
+	}
+
+	return shippedIDs, nil
+}
+
+const adjustmentBatchSize = 200
+
+type adjustmentKey struct {
+	orderID uint
+	tipe    db_models.AdjustmentType
+}
+
+// LogAdjustments implements OrderRefIDsQuery, versi batch LogAdjustment untuk banyak order sekaligus
+func (o *orderByRefIDsImpl) LogAdjustments(logs []*AdjustmentLog) error {
+	var err error
+	if len(logs) == 0 {
+		return nil
+	}
+
+	orders := []*db_models.Order{}
+	err = o.buildQuery(false).
+		Where("status != ?", db_models.OrdCancel).
+		Order("id asc").
+		Find(&orders).
+		Error
+	if err != nil {
+		return err
+	}
+
+	orderMap := map[string]*db_models.Order{}
+	orderIDs := []uint{}
+	for _, ord := range orders {
+		if orderMap[ord.OrderRefID] != nil {
+			continue
+		}
+		orderMap[ord.OrderRefID] = ord
+		orderIDs = append(orderIDs, ord.ID)
+	}
+
+	adjs := []*db_models.OrderAdjustment{}
+	if len(orderIDs) != 0 {
+		err = o.tx.
+			Model(&db_models.OrderAdjustment{}).
+			Where("order_id IN ?", orderIDs).
+			Order("id asc").
+			Find(&adjs).
+			Error
+		if err != nil {
+			return err
+		}
+	}
+
+	adjMap := map[adjustmentKey]*db_models.OrderAdjustment{}
+	for _, adj := range adjs {
+		key := adjustmentKey{adj.OrderID, adj.Type}
+		if adjMap[key] != nil {
+			continue
+		}
+		adjMap[key] = adj
+	}
+
+	created := []*db_models.OrderAdjustment{}
+	updated := []*db_models.OrderAdjustment{}
+	updatedIDs := map[uint]bool{}
+	written := make([]*db_models.OrderAdjustment, len(logs))
+
+	for idx, log := range logs {
+		ord := orderMap[log.RefID]
+		if ord == nil {
+			log.NotFound = true
+			continue
+		}
+
+		if ord.OrderMpID == 0 {
+			return wd_error.New(
+				wd_error.CodeOrderMarketplaceNotSet,
+				fmt.Sprintf("refmode order with id %s marketplace not set %d with receipt %s with ref %s", ord.OrderRefID, ord.ID, ord.Receipt, log.RefID),
+				wd_error.Params{
+					"order_ref_id": ord.OrderRefID,
+					"receipt":      ord.Receipt,
+				},
+			)
+		}
+
+		key := adjustmentKey{ord.ID, log.Type}
+		adj := adjMap[key]
+		if adj == nil {
+			adj = &db_models.OrderAdjustment{
+				OrderID: ord.ID,
+				MpID:    ord.OrderMpID,
+				At:      log.At,
+				FundAt:  log.FundAt,
+				Type:    log.Type,
+				Amount:  log.Amount,
+				Desc:    log.Desc,
+			}
+			adjMap[key] = adj
+			created = append(created, adj)
+		} else {
+			prev := *adj
+			log.Prev = &prev
+
+			adj.Amount = log.Amount
+			adj.At = log.At
+			adj.FundAt = log.At
+			if adj.ID != 0 && !updatedIDs[adj.ID] {
+				updatedIDs[adj.ID] = true
+				updated = append(updated, adj)
+			}
+		}
+
+		written[idx] = adj
+	}
+
+	if len(created) != 0 {
+		err = o.tx.CreateInBatches(created, adjustmentBatchSize).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(updated) != 0 {
+		err = o.tx.Save(updated).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	for idx, adj := range written {
+		if adj != nil {
+			logs[idx].ID = adj.ID
+		}
+	}
+
+	if len(orderIDs) == 0 {
+		return nil
+	}
+
+	return o.tx.
+		Model(&db_models.Order{}).
+		Where("id IN ?", orderIDs).
+		Updates(map[string]interface{}{
+			"wd_total": o.tx.
+				Model(&db_models.OrderAdjustment{}).
+				Select("SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END)").
+				Where("order_adjustments.order_id = orders.id"),
+			"adjustment": o.tx.
+				Model(&db_models.OrderAdjustment{}).
+				Select("SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END)").
+				Where("order_adjustments.order_id = orders.id"),
+		}).
+		Error
 }
 
 // Lock implements OrderRefIDsQuery.
@@ -126,20 +390,35 @@ func (o *orderByRefIDsImpl) buildQuery(lock bool) *gorm.DB {
 		Model(&db_models.Order{}).
 		Where("team_id = ?", o.teamID).
 		Where("order_ref_id IN ?", o.refIDs)
+	if o.mpID != 0 {
+		query = query.Where("order_mp_id = ?", o.mpID)
+	}
 	return query
 }
 
-func (o *orderByRefIDsImpl) completedIDs() ([]uint, error) {
-	hasil := []uint{}
-	err := o.buildQuery(false).
-		Select("id").
+// CompletedRefIDs implements OrderRefIDsQuery, order yang akan di set completed tanpa mengubah data
+func (o *orderByRefIDsImpl) CompletedRefIDs() ([]string, error) {
+	hasil := []string{}
+	err := o.completableQuery().
+		Pluck("order_ref_id", &hasil).Error
+	return hasil, err
+}
+
+func (o *orderByRefIDsImpl) completableQuery() *gorm.DB {
+	return o.buildQuery(false).
 		Where("wd_total > 0").
 		Where("status NOT IN ?", []db_models.OrdStatus{
 			db_models.OrdReturnCompleted,
 			db_models.OrdProblem,
 			db_models.OrdCancel,
 			db_models.OrdCompleted,
-		}).
+		})
+}
+
+func (o *orderByRefIDsImpl) completedIDs() ([]uint, error) {
+	hasil := []uint{}
+	err := o.completableQuery().
+		Select("id").
 		Find(&hasil).Error
 	return hasil, err
 }
@@ -0,0 +1,144 @@
+package withdrawal_service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pdcgo/schema/services/common/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/events/order_event"
+	"github.com/pdcgo/shared/interfaces/identity_iface"
+	"github.com/pdcgo/shared/pkg/streampipe"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/order_query"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/gorm"
+)
+
+const shippedBatchSize = 500
+
+type ShippedIterate interface {
+	Iterate(ctx context.Context, handler func(orderRefID string) error) error
+}
+
+func NewShippedImporter(tipe common.MarketplaceType, data []byte) (ShippedIterate, error) {
+	reader := io.NopCloser(bytes.NewReader(data))
+	switch tipe {
+	case common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE:
+		return datasource.NewShopeeShippedXls(reader), nil
+	case common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK:
+		return datasource.NewTiktokShippedXls(reader), nil
+	case common.MarketplaceType_MARKETPLACE_TYPE_LAZADA:
+		return datasource.NewLazadaShippedXls(reader), nil
+	case common.MarketplaceType_MARKETPLACE_TYPE_TOKOPEDIA:
+		return datasource.NewTokopediaShippedXls(reader), nil
+	default:
+		return nil, wd_error.New(
+			wd_error.CodeUnsupportedImporter,
+			fmt.Sprintf("export pengiriman %s not supported", tipe),
+			wd_error.Params{"mp_type": tipe.String()},
+		)
+	}
+}
+
+// ImportShipped order di export pengiriman di set dikirim pada waktu shippedAt,
+// order yang tidak ada di marketplace yang dipilih dikirim ke notFound.
+// diproses per batch, batch yang sudah commit tidak dibatalkan kalau batch berikutnya gagal
+func ImportShipped(
+	ctx context.Context,
+	db *gorm.DB,
+	agent identity_iface.Agent,
+	pub streampipe.PublishProvider,
+	teamID uint,
+	mpID uint,
+	shippedAt time.Time,
+	importer ShippedIterate,
+	notFound func(refID string) error,
+) (*withdrawal_task_iface.ShippedSummary, error) {
+	summary := &withdrawal_task_iface.ShippedSummary{}
+
+	refIDs := []string{}
+	seen := map[string]bool{}
+	err := importer.Iterate(ctx, func(orderRefID string) error {
+		if orderRefID == "" || seen[orderRefID] {
+			return nil
+		}
+		seen[orderRefID] = true
+		refIDs = append(refIDs, orderRefID)
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Total = int32(len(refIDs))
+
+	for start := 0; start < len(refIDs); start += shippedBatchSize {
+		end := min(start+shippedBatchSize, len(refIDs))
+		batch := refIDs[start:end]
+
+		found := []string{}
+		err = db.
+			Model(&db_models.Order{}).
+			Where("team_id = ?", teamID).
+			Where("order_mp_id = ?", mpID).
+			Where("order_ref_id IN ?", batch).
+			Pluck("order_ref_id", &found).
+			Error
+		if err != nil {
+			return summary, err
+		}
+
+		foundMap := map[string]bool{}
+		for _, refID := range found {
+			foundMap[refID] = true
+		}
+
+		shipRefs := []string{}
+		for _, refID := range batch {
+			if foundMap[refID] {
+				shipRefs = append(shipRefs, refID)
+				continue
+			}
+
+			summary.NotFound += 1
+			err = notFound(refID)
+			if err != nil {
+				return summary, err
+			}
+		}
+
+		if len(shipRefs) == 0 {
+			continue
+		}
+
+		shippedIDs := []uint{}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			shippedIDs, err = order_query.
+				NewOrderQuery(tx, agent, pub).
+				ByRefIDs(teamID, shipRefs).
+				Shipped(mpID, shippedAt)
+			return err
+		})
+		if err != nil {
+			return summary, err
+		}
+
+		summary.Shipped += int32(len(shippedIDs))
+		summary.Skipped += int32(len(shipRefs) - len(shippedIDs))
+
+		// event dikirim setelah batch commit supaya rollback tidak ikut terkirim
+		for _, id := range shippedIDs {
+			pub.Send(streampipe.DEFAULT_TOPIC, &order_event.OrderEvent{
+				Action:  order_event.OrderChangeStatus,
+				Status:  db_models.OrdSent,
+				OrderID: id,
+			})
+		}
+	}
+
+	return summary, nil
+}
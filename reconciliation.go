@@ -0,0 +1,144 @@
+package withdrawal_service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var ErrReconciliationNotFound = errors.New("rekonsiliasi withdrawal tidak ditemukan")
+
+type ReconciliationSource string
+
+const (
+	ReconciliationV1 ReconciliationSource = "v1" // withdrawal_id dari withdrawals.id
+	ReconciliationV2 ReconciliationSource = "v2" // withdrawal_id dari v2_withdrawal_logs.id
+)
+
+type ReconciliationEarning struct {
+	OrderRefId  string       `json:"order_ref_id"`
+	Type        string       `json:"type"`
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+	At          int64        `json:"at"`
+}
+
+func (e *ReconciliationEarning) ToIface() *withdrawal_task_iface.ReconciliationEarning {
+	return &withdrawal_task_iface.ReconciliationEarning{
+		OrderRefId:  e.OrderRefId,
+		Type:        e.Type,
+		Description: e.Description,
+		Amount:      e.Amount.Float64(),
+		At:          e.At,
+	}
+}
+
+func reconciliationEarningsIface(items []*ReconciliationEarning) []*withdrawal_task_iface.ReconciliationEarning {
+	hasil := make([]*withdrawal_task_iface.ReconciliationEarning, len(items))
+	for idx, item := range items {
+		hasil[idx] = item.ToIface()
+	}
+	return hasil
+}
+
+// WithdrawalReconciliation laporan earning yang cocok dan tidak cocok ke satu withdrawal.
+// setiap import menambah row baru, yang dibaca yang paling akhir
+type WithdrawalReconciliation struct {
+	ID               uint                 `gorm:"primarykey"`
+	Source           ReconciliationSource `gorm:"index:idx_wd_reconciliation_lookup"`
+	TeamID           uint                 `gorm:"index:idx_wd_reconciliation_lookup"`
+	WithdrawalID     uint                 `gorm:"index:idx_wd_reconciliation_lookup"`
+	MpID             uint
+	TaskID           uint `gorm:"index"`
+	WithdrawalAmount money.Amount
+	Matched          datatypes.JSONType[[]*ReconciliationEarning]
+	Unmatched        datatypes.JSONType[[]*ReconciliationEarning]
+	MatchedAmount    money.Amount
+	UnmatchedAmount  money.Amount
+	CarriedOver      money.Amount
+	DiffAmount       money.Amount
+	CreatedAt        time.Time
+}
+
+// SetEarnings mengisi daftar earning beserta totalnya
+func (r *WithdrawalReconciliation) SetEarnings(matched, unmatched []*db_models.InvoItem) {
+	var matchedAmount, unmatchedAmount money.Amount
+
+	matchedList := make([]*ReconciliationEarning, len(matched))
+	for idx, item := range matched {
+		matchedList[idx] = reconciliationEarning(item)
+		matchedAmount += money.FromFloat(item.Amount)
+	}
+
+	unmatchedList := make([]*ReconciliationEarning, len(unmatched))
+	for idx, item := range unmatched {
+		unmatchedList[idx] = reconciliationEarning(item)
+		unmatchedAmount += money.FromFloat(item.Amount)
+	}
+
+	r.Matched = datatypes.NewJSONType(matchedList)
+	r.Unmatched = datatypes.NewJSONType(unmatchedList)
+	r.MatchedAmount = matchedAmount
+	r.UnmatchedAmount = unmatchedAmount
+}
+
+func (r *WithdrawalReconciliation) ToIface() *withdrawal_task_iface.Reconciliation {
+	return &withdrawal_task_iface.Reconciliation{
+		Id:               uint64(r.ID),
+		Source:           string(r.Source),
+		WithdrawalId:     uint64(r.WithdrawalID),
+		TeamId:           uint64(r.TeamID),
+		MpId:             uint64(r.MpID),
+		TaskId:           uint64(r.TaskID),
+		WithdrawalAmount: r.WithdrawalAmount.Float64(),
+		Matched:          reconciliationEarningsIface(r.Matched.Data()),
+		Unmatched:        reconciliationEarningsIface(r.Unmatched.Data()),
+		MatchedAmount:    r.MatchedAmount.Float64(),
+		UnmatchedAmount:  r.UnmatchedAmount.Float64(),
+		CarriedOver:      r.CarriedOver.Float64(),
+		DiffAmount:       r.DiffAmount.Float64(),
+		CreatedAt:        r.CreatedAt.Unix(),
+	}
+}
+
+func reconciliationEarning(item *db_models.InvoItem) *ReconciliationEarning {
+	return &ReconciliationEarning{
+		OrderRefId:  item.ExternalOrderID,
+		Type:        fmt.Sprint(item.Type),
+		Description: item.Description,
+		Amount:      money.FromFloat(item.Amount),
+		At:          item.TransactionDate.Unix(),
+	}
+}
+
+func SaveReconciliation(tx *gorm.DB, rec *WithdrawalReconciliation) error {
+	return tx.Create(rec).Error
+}
+
+func GetReconciliation(db *gorm.DB, source ReconciliationSource, teamID, withdrawalID uint) (*WithdrawalReconciliation, error) {
+	var rec WithdrawalReconciliation
+	err := db.
+		Model(&WithdrawalReconciliation{}).
+		Where("source = ?", source).
+		Where("team_id = ?", teamID).
+		Where("withdrawal_id = ?", withdrawalID).
+		Order("id desc").
+		Limit(1).
+		Find(&rec).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.ID == 0 {
+		return nil, ErrReconciliationNotFound
+	}
+
+	return &rec, nil
+}
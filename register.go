@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/pdcgo/schema/services/withdrawal_iface/v1/withdrawal_ifaceconnect"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1/withdrawal_task_ifaceconnect"
 	"github.com/pdcgo/shared/custom_connect"
 	"github.com/pdcgo/shared/interfaces/authorization_iface"
 	"github.com/pdcgo/shared/pkg/streampipe"
@@ -21,7 +22,15 @@ func NewRegister(
 ) RegisterHandler {
 	return func() {
 
-		path, handler := withdrawal_ifaceconnect.NewWithdrawalServiceHandler(NewWithdrawalService(db, pub, auth), defaultInterceptor)
+		service := NewWithdrawalService(db, pub, auth)
+
+		path, handler := withdrawal_ifaceconnect.NewWithdrawalServiceHandler(service, defaultInterceptor)
+		mux.Handle(path, handler)
+
+		path, handler = withdrawal_task_ifaceconnect.NewTaskServiceHandler(
+			NewTaskService(auth, service.store, pub, NewGcsFileStorage(tempAssetBucket)),
+			defaultInterceptor,
+		)
 		mux.Handle(path, handler)
 	}
 }
@@ -8,10 +8,14 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/pdcgo/schema/services/common/v1"
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/interfaces/identity_iface"
 	"github.com/pdcgo/shared/pkg/streampipe"
@@ -19,15 +23,17 @@ import (
 	"github.com/pdcgo/withdrawal_service/datasource"
 	"github.com/pdcgo/withdrawal_service/marketplace_query"
 	"github.com/pdcgo/withdrawal_service/order_query"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"gorm.io/gorm"
 )
 
 type runner struct {
-	ctx    context.Context
-	store  TaskStore
-	db     *gorm.DB
-	pub    streampipe.PublishProvider
-	client *storage.Client
+	ctx      context.Context
+	store    TaskStore
+	db       *gorm.DB
+	pub      streampipe.PublishProvider
+	client   *storage.Client
+	taskDone sync.Map
 }
 
 func NewRunner(ctx context.Context, db *gorm.DB, store TaskStore, pub streampipe.PublishProvider, client *storage.Client) *runner {
@@ -40,6 +46,14 @@ func NewRunner(ctx context.Context, db *gorm.DB, store TaskStore, pub streampipe
 	}
 }
 
+// finishTask melepas context task, dipanggil saat task selesai atau error
+func (r *runner) finishTask(taskID uint) {
+	done, ok := r.taskDone.LoadAndDelete(taskID)
+	if ok {
+		done.(context.CancelFunc)()
+	}
+}
+
 type WdImporterIterate interface {
 	GetShopUsername() (string, error)
 	GetRefIDs() (datasource.OrderRefList, error)
@@ -53,6 +67,7 @@ type WdPipeParam struct {
 	task        *TaskItem
 	agent       identity_iface.Agent
 	marketplace marketplace_query.ItemQuery
+	db          *gorm.DB
 	// filecontent []byte
 }
 
@@ -82,174 +97,375 @@ func (r *runner) Run(errEmitter ErrEmitter) {
 	aliveCtx, cancel := context.WithCancel(r.ctx)
 	defer cancel()
 	go r.runKeepAlive(aliveCtx)
+	go r.runOrderNotFoundMatcher(aliveCtx)
+	// listener ikut berhenti saat runner di stop
+	go r.store.Notifier().Listen(aliveCtx)
 
-	runCtx := yenstream.NewRunnerContext(r.ctx)
-	runCtx.
-		CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
-			source := NewTaskSource(
-				"task_source",
-				r.store.GetTx(),
-				ctx,
-				false,
-			).
-				Via("Set Process", yenstream.NewMap(ctx, func(task *TaskItem) (*TaskItem, error) {
-					slog.Info("processing task", slog.String("resource", task.ResourceUri))
-					err := r.store.SetProcess(task.ID)
-					return task, errEmitter(task.ID, err)
-				})).
-				Via("initializing processor", yenstream.NewMap(ctx, func(item *TaskItem) (*WdPipeParam, error) {
-					var err error
-					var importer WdImporterIterate
-
-					// getting file
-					file, err := r.client.Bucket("gudang_assets_temp").Object(item.ResourceUri).NewReader(r.ctx)
-					if err != nil {
-						return nil, errEmitter(item.ID, err)
-					}
+	workers := runnerWorkerCount()
+	err := ensureRunnerPool(r.db, workers)
+	if err != nil {
+		slog.Error(err.Error(), slog.String("function", "runner_pool"))
+	}
 
-					data, err := io.ReadAll(file)
-					if err != nil {
-						return nil, errEmitter(item.ID, err)
-					}
+	// tiap worker punya source sendiri, task di claim atomik jadi aman jalan paralel
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(label string) {
+			defer wg.Done()
+			yenstream.
+				NewRunnerContext(r.ctx).
+				CreatePipeline(r.createPipeline(label, errEmitter))
+		}(fmt.Sprintf("task_source_%d", i))
+	}
+	wg.Wait()
 
-					agent := NewV2ImporterAgent(item.AgentData.Data())
-					importer, err = r.createImporter(item.MpType, data)
-
-					var processor ImporterProcessor = NewImporterProcessor(
-						r.db,
-						order_query.NewFinance(agent, r.db),
-						r.ctx,
-						item.ToLegacyWDImporterQuery(),
-						agent,
-						r.pub,
-					)
-
-					return &WdPipeParam{
-						ctx:       r.ctx,
-						importer:  importer,
-						task:      item,
-						agent:     agent,
-						processor: processor,
-					}, errEmitter(item.ID, err)
-				})).
-				Via("check marketplace", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
-					var err error
-					query := data.task.ToLegacyWDImporterQuery()
-					importer := data.importer
-					mpquery := marketplace_query.NewMarketplaceQuery(r.db, data.agent)
-					item := data.task
-
-					var marketplace marketplace_query.ItemQuery
-					switch query.MpType {
-					case db_models.OrderMpShopee:
-						username, err := importer.GetShopUsername()
-						if err != nil {
-							return data, errEmitter(item.ID, err)
-						}
-						marketplace = mpquery.ByUsername(query.TeamID, db_models.MpShopee, username)
-						_, err = marketplace.Get()
-						if err != nil {
-							if errors.Is(err, marketplace_query.ErrMarketplaceNotFound) {
-								return data, errEmitter(item.ID, fmt.Errorf("marketplace dengan username %s tidak ada", username))
-							}
+	err = r.store.PruneTask()
+	if err != nil {
+		slog.Error(err.Error(), slog.String("function", "prune_task"))
+	}
+	slog.Info("close runner withdrawal")
+}
 
-							return data, errEmitter(item.ID, err)
-						}
+func runnerWorkerCount() int {
+	count, err := strconv.Atoi(os.Getenv("WD_RUNNER_WORKERS"))
+	if err != nil || count < 1 {
+		return 1
+	}
+	return count
+}
 
-					case db_models.OrderMpTiktok:
-						marketplace = mpquery.ByID(query.TeamID, query.MpID)
+// tiap worker menahan satu koneksi selama set withdrawal belum commit atau rollback,
+// sisa koneksi untuk heartbeat, progress, claim dan request api supaya worker tidak saling tunggu
+const runnerReservedConns = 2
 
-					case db_models.OrderMengantar:
-						marketplace = mpquery.ByID(query.TeamID, query.MpID)
-					}
+func runnerPoolSize(workers int) int {
+	return workers + runnerReservedConns
+}
 
-					// initiating asset and history flow
-					err = marketplace.CheckBankAccount()
-					if err != nil {
-						return data, errEmitter(item.ID, err)
-					}
+// ensureRunnerPool besarkan pool kalau lebih kecil dari kebutuhan worker, 0 berarti tidak dibatasi
+func ensureRunnerPool(db *gorm.DB, workers int) error {
+	if db == nil {
+		return nil
+	}
 
-					err = marketplace.CheckHoldAsset()
+	sqldb, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	size := runnerPoolSize(workers)
+	current := sqldb.Stats().MaxOpenConnections
+	if current != 0 && current < size {
+		sqldb.SetMaxOpenConns(size)
+		slog.Info("pool database diperbesar untuk runner",
+			slog.Int("workers", workers),
+			slog.Int("from", current),
+			slog.Int("to", size),
+		)
+	}
+	return nil
+}
+
+func (r *runner) createPipeline(label string, emitter ErrEmitter) func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+	errEmitter := func(taskID uint, err error) error {
+		if err != nil {
+			r.finishTask(taskID)
+		}
+		return emitter(taskID, err)
+	}
+
+	return func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+		source := NewTaskSource(
+			label,
+			r.store.GetTx(),
+			r.store.Notifier(),
+			ctx,
+			false,
+		).
+			Via("Set Process", yenstream.NewMap(ctx, func(task *TaskItem) (*TaskItem, error) {
+				// status process dan attempt sudah di set saat claim
+				slog.Info("processing task",
+					slog.String("resource", task.ResourceUri),
+					slog.String("owner", task.LeaseOwner),
+				)
+				return task, nil
+			})).
+			Via("initializing processor", yenstream.NewMap(ctx, func(item *TaskItem) (*WdPipeParam, error) {
+				var err error
+				var importer WdImporterIterate
+
+				// context per task, supaya bisa dibatalkan tanpa stop runner
+				taskCtx, done := r.store.TaskContext(r.ctx, item)
+				r.taskDone.Store(item.ID, done)
+
+				// dry run memakai db biasa, set withdrawal di rollback oleh processor
+				db := r.db
+
+				// getting file
+				file, err := r.client.Bucket(tempAssetBucket).Object(item.ResourceUri).NewReader(taskCtx)
+				if err != nil {
+					params := wd_error.Params{"resource_uri": item.ResourceUri}
+					if errors.Is(err, storage.ErrObjectNotExist) {
+						return nil, errEmitter(item.ID, wd_error.Wrap(wd_error.CodeFileNotFound, err, params))
+					}
+					return nil, errEmitter(item.ID, NewRetryableErr(wd_error.Wrap(wd_error.CodeStorage, err, params)))
+				}
+				defer file.Close()
+
+				data, err := io.ReadAll(file)
+				if err != nil {
+					return nil, errEmitter(item.ID, NewRetryableErr(wd_error.Wrap(wd_error.CodeStorage, err, wd_error.Params{
+						"resource_uri": item.ResourceUri,
+					})))
+				}
+
+				err = r.checkDuplicateFile(item, ContentHash(data))
+				if err != nil {
+					return nil, errEmitter(item.ID, err)
+				}
+
+				mapping, err := FindColumnMapping(db, uint(item.TeamId), uint(item.MpId))
+				if err != nil {
+					return nil, errEmitter(item.ID, err)
+				}
+
+				detected, err := CheckDetectedFile(item.Source, item.MpType, mapping, data)
+				if err != nil {
+					return nil, errEmitter(item.ID, err)
+				}
+
+				err = CheckV1Parser(detected)
+				if err != nil {
+					return nil, errEmitter(item.ID, err)
+				}
+
+				agent := NewV2ImporterAgent(item.AgentData.Data())
+				importer, err = r.createImporter(ImporterSourceOf(item.Source, detected), item.MpType, mapping, data)
+
+				var processor ImporterProcessor = NewImporterProcessor(
+					db,
+					order_query.NewFinance(agent, db),
+					taskCtx,
+					item.ToLegacyWDImporterQuery(),
+					agent,
+					r.pub,
+					item.ID,
+				)
+				if item.DryRun {
+					processor.EnablePreview()
+				}
+
+				return &WdPipeParam{
+					ctx:       taskCtx,
+					importer:  importer,
+					task:      item,
+					agent:     agent,
+					processor: processor,
+					db:        db,
+				}, errEmitter(item.ID, err)
+			})).
+			Via("check marketplace", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
+				var err error
+				query := data.task.ToLegacyWDImporterQuery()
+				importer := data.importer
+				mpquery := marketplace_query.NewMarketplaceQuery(data.db, data.agent)
+				item := data.task
+
+				var marketplace marketplace_query.ItemQuery
+				switch query.MpType {
+				case db_models.OrderMpShopee:
+					username, err := importer.GetShopUsername()
 					if err != nil {
 						return data, errEmitter(item.ID, err)
 					}
-
-					data.marketplace = marketplace
-					return data, nil
-				})).
-				Via("checking order marketplace sudah benar", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
-					query := data.task.ToLegacyWDImporterQuery()
-					marketplace := data.marketplace
-					importer := data.importer
-					processor := data.processor
-					item := data.task
-
-					refids, err := importer.GetRefIDs()
+					marketplace = mpquery.ByUsername(query.TeamID, db_models.MpShopee, username)
+					_, err = marketplace.Get()
 					if err != nil {
+						if errors.Is(err, marketplace_query.ErrMarketplaceNotFound) {
+							return data, errEmitter(item.ID, wd_error.New(
+								wd_error.CodeMarketplaceNotFound,
+								fmt.Sprintf("marketplace dengan username %s tidak ada", username),
+								wd_error.Params{"username": username},
+							))
+						}
+
 						return data, errEmitter(item.ID, err)
 					}
 
-					// checking order marketplace benar
-					refIDsQuery := order_query.NewOrderQuery(r.db, data.agent, r.pub).ByRefIDs(query.TeamID, refids)
-					ordersMeta := refIDsQuery.HaveMarketplace(query.MpID)
-
-					switch query.MpType {
-					case db_models.OrderMpShopee:
-						if ordersMeta.InvalidCount != 0 {
-							market, err := marketplace.Get()
-							if err != nil {
-								return data, errEmitter(item.ID, err)
-							}
-							err = refIDsQuery.ChangeMarketplace(market.ID)
-							if err != nil {
-								return data, errEmitter(item.ID, err)
-							}
+				case db_models.OrderMpTiktok:
+					marketplace = mpquery.ByID(query.TeamID, query.MpID)
+
+				case db_models.OrderMengantar:
+					marketplace = mpquery.ByID(query.TeamID, query.MpID)
+
+				case db_models.OrderMpLazada, db_models.OrderMpTokopedia, db_models.OrderMpCustom:
+					marketplace = mpquery.ByID(query.TeamID, query.MpID)
+				}
+
+				// initiating asset and history flow
+				err = marketplace.CheckBankAccount()
+				if err != nil {
+					return data, errEmitter(item.ID, err)
+				}
+
+				err = marketplace.CheckHoldAsset()
+				if err != nil {
+					return data, errEmitter(item.ID, err)
+				}
+
+				data.marketplace = marketplace
+				return data, nil
+			})).
+			Via("checking order marketplace sudah benar", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
+				query := data.task.ToLegacyWDImporterQuery()
+				marketplace := data.marketplace
+				importer := data.importer
+				processor := data.processor
+				item := data.task
+
+				refids, err := importer.GetRefIDs()
+				if err != nil {
+					return data, errEmitter(item.ID, err)
+				}
+
+				// checking order marketplace benar
+				refIDsQuery := order_query.NewOrderQuery(data.db, data.agent, r.pub).ByRefIDs(query.TeamID, refids)
+				ordersMeta := refIDsQuery.HaveMarketplace(query.MpID)
+
+				switch query.MpType {
+				case db_models.OrderMpShopee:
+					if ordersMeta.InvalidCount != 0 {
+						market, err := marketplace.Get()
+						if err != nil {
+							return data, errEmitter(item.ID, err)
 						}
-					case db_models.OrderMpTiktok:
-						err = ordersMeta.GetError()
+						err = r.changeMarketplace(data, query.TeamID, refids, market.ID)
 						if err != nil {
 							return data, errEmitter(item.ID, err)
 						}
 					}
-
-					err = processor.SetFilterMarketplace(marketplace)
+				case db_models.OrderMpTiktok, db_models.OrderMpLazada, db_models.OrderMpTokopedia, db_models.OrderMpCustom:
+					err = ordersMeta.GetError()
 					if err != nil {
 						return data, errEmitter(item.ID, err)
 					}
-
-					return data, nil
-				})).
-				Via("starting iter update withdrawal", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
-
-					res, err := r.iterateWithdrawal(data)
+				case db_models.OrderMengantar:
+					// file mengantar tidak punya identitas toko
+					err = CheckShopOrders(data.db, query.TeamID, query.MpID, refids)
+					if err != nil {
+						return data, errEmitter(item.ID, err)
+					}
+				}
+
+				err = processor.SetFilterMarketplace(marketplace)
+				if err != nil {
+					return data, errEmitter(item.ID, err)
+				}
+
+				return data, nil
+			})).
+			Via("starting iter update withdrawal", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
+
+				res, err := r.iterateWithdrawal(data)
+				if err != nil {
+					return res, errEmitter(data.task.ID, err)
+				}
+				return res, nil
+			})).
+			Via("Set Finish", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
+				if data.task.DryRun {
+					err := r.store.SetPreview(data.task.ID, data.processor.Preview())
 					if err != nil {
-						return res, errEmitter(data.task.ID, err)
+						return data, errEmitter(data.task.ID, err)
 					}
-					return res, nil
-				})).
-				Via("Set Finish", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
-					err := r.store.SetFinish(data.task.ID)
+				}
+
+				err := r.store.SetFinish(data.task.ID)
+				r.finishTask(data.task.ID)
+				if err != nil {
 					return data, errEmitter(data.task.ID, err)
-				})).
-				Via("log pipe", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
-					slog.Info(fmt.Sprintf("%s processed", data.task.ResourceUri))
-					return data, nil
-				}))
+				}
+
+				return data, nil
+			})).
+			Via("log pipe", yenstream.NewMap(ctx, func(data *WdPipeParam) (*WdPipeParam, error) {
+				slog.Info(fmt.Sprintf("%s processed", data.task.ResourceUri))
+				return data, nil
+			}))
 
-			return source
-		})
+		return source
+	}
+}
 
-	err := r.store.EmptyTask()
+// checkDuplicateFile menolak file yang sudah pernah diimport atau sedang diproses task lain
+func (r *runner) checkDuplicateFile(item *TaskItem, hash string) error {
+	db := r.store.GetTx()
+
+	item.ContentHash = hash
+	err := db.Model(&TaskItem{}).Where("id = ?", item.ID).Update("content_hash", hash).Error
 	if err != nil {
-		panic(err)
+		return err
 	}
-	slog.Info("close runner withdrawal")
+
+	if item.AllowReimport {
+		return nil
+	}
+
+	var running int64
+	err = db.
+		Model(&TaskItem{}).
+		Where("id != ?", item.ID).
+		Where("team_id = ?", item.TeamId).
+		Where("mp_id = ?", item.MpId).
+		Where("content_hash = ?", hash).
+		Where("dry_run = ?", false).
+		Where("status = ?", withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS).
+		Count(&running).
+		Error
+	if err != nil {
+		return err
+	}
+
+	if running > 0 {
+		return wd_error.New(
+			wd_error.CodeDuplicateFile,
+			fmt.Sprintf("%s, file yang sama sedang diproses", ErrDuplicateFile.Error()),
+			nil,
+		)
+	}
+
+	return CheckFileFingerprint(db, FingerprintV1, uint(item.TeamId), uint(item.MpId), hash)
 }
 
-func (r *runner) createImporter(tipe common.MarketplaceType, data []byte) (WdImporterIterate, error) {
+func (r *runner) createImporter(
+	source withdrawal_iface.ImporterSource,
+	tipe common.MarketplaceType,
+	mapping *ColumnMappingConfig,
+	data []byte,
+) (WdImporterIterate, error) {
 	var err error
 	var importer WdImporterIterate
 
+	// json hanya untuk marketplace custom yang tidak punya export excel
+	if source == withdrawal_iface.ImporterSource_IMPORTER_SOURCE_JSON {
+		if tipe != common.MarketplaceType_MARKETPLACE_TYPE_CUSTOM {
+			return importer, wd_error.New(
+				wd_error.CodeUnsupportedImporter,
+				fmt.Sprintf("source %s untuk %s not supported", source, tipe),
+				wd_error.Params{"mp_type": tipe.String(), "source": source.String()},
+			)
+		}
+
+		importer = datasource.NewCustomWdJson(io.NopCloser(bytes.NewReader(data)))
+		return importer, err
+	}
+
+	// mapping kolom team menggantikan importer bawaan
+	if mapping != nil {
+		return NewMappedImporter(source, tipe, mapping.Mapping.Data(), data)
+	}
+
 	switch tipe {
 	case common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE:
 		importer = datasource.NewShopeeWdXls(io.NopCloser(bytes.NewReader(data)))
@@ -257,23 +473,100 @@ func (r *runner) createImporter(tipe common.MarketplaceType, data []byte) (WdImp
 		importer = datasource.NewTiktokWdXls(io.NopCloser(bytes.NewReader(data)))
 	case common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR:
 		importer = datasource.NewMengantarWdCsv(io.NopCloser(bytes.NewReader(data)))
+	case common.MarketplaceType_MARKETPLACE_TYPE_LAZADA:
+		importer = datasource.NewLazadaWdXls(io.NopCloser(bytes.NewReader(data)))
+	case common.MarketplaceType_MARKETPLACE_TYPE_TOKOPEDIA:
+		importer = datasource.NewTokopediaWdXls(io.NopCloser(bytes.NewReader(data)))
 	default:
-		return importer, fmt.Errorf("%s not supported", tipe)
+		return importer, wd_error.New(
+			wd_error.CodeUnsupportedImporter,
+			fmt.Sprintf("%s not supported", tipe),
+			wd_error.Params{"mp_type": tipe.String()},
+		)
 	}
 
 	return importer, err
 }
 
+// changeMarketplace pindahkan order ke marketplace file, marketplace lama dicatat untuk revert
+func (r *runner) changeMarketplace(data *WdPipeParam, teamID uint, refIDs []string, mpID uint) error {
+	// dry run tidak memindah marketplace order
+	if data.task.DryRun {
+		return nil
+	}
+
+	journal := newTaskJournal(data.task.ID)
+
+	return data.db.Transaction(func(tx *gorm.DB) error {
+		if journal.enabled() {
+			orderIDs := []uint{}
+			err := tx.
+				Model(&db_models.Order{}).
+				Where("team_id = ?", teamID).
+				Where("order_ref_id IN ?", refIDs).
+				Where("order_mp_id != ?", mpID).
+				Pluck("id", &orderIDs).
+				Error
+			if err != nil {
+				return err
+			}
+
+			err = journal.Snapshot(tx, ChangeOrder, orderIDs, "order_mp_id")
+			if err != nil {
+				return err
+			}
+		}
+
+		return order_query.
+			NewOrderQuery(tx, data.agent, r.pub).
+			ByRefIDs(teamID, refIDs).
+			ChangeMarketplace(mpID)
+	})
+}
+
 func (r *runner) iterateWithdrawal(data *WdPipeParam) (*WdPipeParam, error) {
 	importer := data.importer
 	processor := data.processor
+	taskID := data.task.ID
 
+	// file cuma di iterate sekali, importer tiktok menyimpan state antar iterate.
+	// item dikumpulkan dulu supaya total baris bisa dihitung persen
+	items := []*db_models.InvoItem{}
 	err := importer.Iterate(data.ctx, func(item *db_models.InvoItem) error {
-		var err error
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return data, err
+	}
+
+	parsed := len(items)
+
+	var processed int
+	progress := newProgressSaver(func() error {
+		return r.store.SetProgress(taskID, parsed, processed)
+	})
+
+	err = progress.save()
+	if err != nil {
+		return data, err
+	}
+
+	// set withdrawal yang belum commit dibatalkan kalau iterasi berhenti di tengah
+	defer processor.Rollback()
+
+	for _, item := range items {
+		// task dibatalkan, berhenti sebelum item berikutnya
+		err = data.ctx.Err()
+		if err != nil {
+			break
+		}
+
 		switch item.Type {
 		case db_models.AdjOrderFund:
 			err = processor.OrderFund(item)
-		case db_models.AdjCommision, db_models.AdjLostCompensation, db_models.AdjCompensation:
+		case db_models.AdjCommision, db_models.AdjLostCompensation, db_models.AdjCompensation,
+			db_models.AdjReturn, db_models.AdjShipping:
 			err = processor.OrderAdjustment(item)
 		case db_models.AdjUnknown, db_models.AdjUnknownAdj:
 			err = processor.Unknown(item)
@@ -283,17 +576,33 @@ func (r *runner) iterateWithdrawal(data *WdPipeParam) (*WdPipeParam, error) {
 		}
 
 		if err != nil {
-			return err
+			break
 		}
 
-		return nil
-	})
+		processed += 1
+		err = progress.tick()
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		progress.save()
+		return data, err
+	}
+
+	err = processor.Check(0)
+	if err != nil {
+		return data, err
+	}
 
+	// task yang gagal di tengah tidak menyimpan summary, set yang sudah commit dibatalkan saat SetErr
+	err = r.store.SetSummary(taskID, processor.Summary())
 	if err != nil {
 		return data, err
 	}
 
-	err = processor.Check(0.00)
+	err = progress.save()
 	if err != nil {
 		return data, err
 	}
@@ -0,0 +1,242 @@
+package withdrawal_service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/marketplace_query"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type recordProcessor struct {
+	items     []*db_models.InvoItem
+	withdraws []*db_models.InvoItem
+}
+
+func (p *recordProcessor) SetFilterMarketplace(mp marketplace_query.ItemQuery) error { return nil }
+
+func (p *recordProcessor) OrderFund(item *db_models.InvoItem) error {
+	p.items = append(p.items, item)
+	return nil
+}
+
+func (p *recordProcessor) OrderAdjustment(item *db_models.InvoItem) error {
+	p.items = append(p.items, item)
+	return nil
+}
+
+func (p *recordProcessor) Unknown(item *db_models.InvoItem) error {
+	p.items = append(p.items, item)
+	return nil
+}
+
+func (p *recordProcessor) Withdrawal(item *db_models.InvoItem) error {
+	p.items = append(p.items, item)
+	p.withdraws = append(p.withdraws, item)
+	return nil
+}
+
+func (p *recordProcessor) Check(sisaAmount money.Amount) error { return nil }
+func (p *recordProcessor) Summary() *TaskSummary               { return &TaskSummary{} }
+func (p *recordProcessor) Rollback()                           {}
+func (p *recordProcessor) EnablePreview()                      {}
+func (p *recordProcessor) Preview() *TaskPreview               { return NewTaskPreview() }
+
+func TestIterateWithdrawalTiktok(t *testing.T) {
+	var db gorm.DB
+
+	fname := "test/assets/testwd/tiktok_wd_include_gmv.xlsx"
+
+	moretest.Suite(t, "iterate withdrawal tiktok v1 sekali jalan",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+		},
+		func(t *testing.T) {
+			store := NewTempStore(&db)
+
+			task := TaskItem{
+				ID: 1,
+				TaskItem: &withdrawal_iface.TaskItem{
+					TeamId: 1,
+					Status: withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+				},
+			}
+			err := db.Save(&task).Error
+			assert.Nil(t, err)
+
+			// hasil iterate langsung sebagai pembanding
+			file, err := os.Open(fname)
+			assert.Nil(t, err)
+			defer file.Close()
+
+			var expected, expectedWd int
+			err = datasource.NewTiktokWdXls(file).Iterate(context.Background(), func(item *db_models.InvoItem) error {
+				expected += 1
+				if item.Type == db_models.AdjFund {
+					expectedWd += 1
+				}
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.NotZero(t, expectedWd)
+
+			data, err := os.Open(fname)
+			assert.Nil(t, err)
+			defer data.Close()
+
+			processor := recordProcessor{}
+			r := &runner{store: store}
+			_, err = r.iterateWithdrawal(&WdPipeParam{
+				ctx:       t.Context(),
+				importer:  datasource.NewTiktokWdXls(data),
+				processor: &processor,
+				task:      &task,
+			})
+			assert.Nil(t, err)
+			assert.Len(t, processor.items, expected)
+			assert.Len(t, processor.withdraws, expectedWd)
+
+			hasil := TaskItem{
+				TaskItem: &withdrawal_iface.TaskItem{},
+			}
+			err = db.Model(&TaskItem{}).Where("id = ?", 1).Find(&hasil).Error
+			assert.Nil(t, err)
+			assert.Equal(t, expected, hasil.RowsParsed)
+			assert.Equal(t, expected, hasil.RowsProcessed)
+		},
+	)
+}
+
+type listImporter struct {
+	items []*db_models.InvoItem
+}
+
+func (l *listImporter) GetShopUsername() (string, error) { return "", nil }
+
+func (l *listImporter) GetRefIDs() (datasource.OrderRefList, error) {
+	return datasource.OrderRefList{}, nil
+}
+
+func (l *listImporter) Iterate(ctx context.Context, handler func(item *db_models.InvoItem) error) error {
+	for _, item := range l.items {
+		err := handler(item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setProcessor tiap withdrawal satu set yang langsung commit, set kedua gagal
+type setProcessor struct {
+	recordProcessor
+	db      *gorm.DB
+	journal *taskJournal
+	sets    int
+}
+
+func (p *setProcessor) Withdrawal(item *db_models.InvoItem) error {
+	p.sets += 1
+	if p.sets > 1 {
+		return errors.New("set kedua gagal")
+	}
+
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		row := db_models.WdOrderNotFound{
+			OrderRefID: "ORD001",
+			WdID:       1,
+			Amount:     item.Amount,
+			At:         item.TransactionDate,
+		}
+		err := tx.Create(&row).Error
+		if err != nil {
+			return err
+		}
+		return p.journal.Created(tx, ChangeWdOrderNotFound, row.ID)
+	})
+}
+
+func TestIterateWithdrawalRollbackSet(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "set yang sudah commit dibatalkan kalau set berikutnya gagal",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(&db_models.WdOrderNotFound{})
+				assert.Nil(t, err)
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			store := NewTempStore(&db)
+
+			task := TaskItem{
+				ID:      1,
+				Attempt: 1,
+				TaskItem: &withdrawal_iface.TaskItem{
+					TeamId: 1,
+					MpId:   1,
+					Status: withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+				},
+			}
+			err := db.Save(&task).Error
+			assert.Nil(t, err)
+
+			processor := setProcessor{
+				db:      &db,
+				journal: newTaskJournal(task.ID),
+			}
+			r := &runner{store: store}
+			_, err = r.iterateWithdrawal(&WdPipeParam{
+				ctx: t.Context(),
+				importer: &listImporter{
+					items: []*db_models.InvoItem{
+						{Type: db_models.AdjFund, Amount: -10000, TransactionDate: time.Now()},
+						{Type: db_models.AdjFund, Amount: -20000, TransactionDate: time.Now()},
+					},
+				},
+				processor: &processor,
+				task:      &task,
+			})
+			assert.NotNil(t, err)
+
+			var count int64
+			err = db.Model(&db_models.WdOrderNotFound{}).Count(&count).Error
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), count)
+
+			err = store.SetErr(task.ID, NewRetryableErr(err))
+			assert.Nil(t, err)
+
+			t.Run("set pertama ikut hilang", func(t *testing.T) {
+				err = db.Model(&db_models.WdOrderNotFound{}).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+
+				err = db.Model(&TaskChange{}).Where("task_id = ?", task.ID).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+			})
+
+			t.Run("task kembali waiting", func(t *testing.T) {
+				hasil := TaskItem{
+					TaskItem: &withdrawal_iface.TaskItem{},
+				}
+				err = db.Model(&TaskItem{}).Where("id = ?", task.ID).Find(&hasil).Error
+				assert.Nil(t, err)
+				assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_WAITING, hasil.Status)
+			})
+		},
+	)
+}
@@ -0,0 +1,36 @@
+package withdrawal_service
+
+import (
+	"testing"
+
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEnsureRunnerPool(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "pool database cukup untuk worker runner",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+		},
+		func(t *testing.T) {
+			sqldb, err := db.DB()
+			assert.Nil(t, err)
+
+			sqldb.SetMaxOpenConns(2)
+			err = ensureRunnerPool(&db, 3)
+			assert.Nil(t, err)
+			assert.Equal(t, 5, sqldb.Stats().MaxOpenConnections)
+
+			t.Run("pool yang sudah besar tidak dikecilkan", func(t *testing.T) {
+				sqldb.SetMaxOpenConns(10)
+				err = ensureRunnerPool(&db, 3)
+				assert.Nil(t, err)
+				assert.Equal(t, 10, sqldb.Stats().MaxOpenConnections)
+			})
+		},
+	)
+}
@@ -99,7 +99,7 @@ func TestPipeline(t *testing.T) {
 		yenstream.NewRunnerContext(ctx).CreatePipeline(
 			func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
 
-				source := withdrawal_service.NewTaskSource("task_source", &db, ctx, true).
+				source := withdrawal_service.NewTaskSource("task_source", &db, nil, ctx, true).
 					Via("logging", yenstream.NewMap(ctx, func(data *withdrawal_service.TaskItem) (*withdrawal_service.TaskItem, error) {
 						return data, nil
 					}))
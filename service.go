@@ -39,11 +39,13 @@ func (w *wdServiceImpl) GetTaskList(
 
 	pay := req.Msg
 
+	// task selesai sekarang disimpan sebagai history, list lama dibatasi yang terbaru saja
 	tx := w.store.GetTx()
 	query := tx.
 		Model(&TaskItem{}).
 		Where("team_id = ?", pay.TeamId).
-		Order("id desc")
+		Order("id desc").
+		Limit(maxTaskListLimit)
 
 	if pay.Status != withdrawal_iface.TaskStatus_TASK_STATUS_UNSPECIFIED {
 		query = query.Where("status = ?", pay.Status)
@@ -126,7 +128,10 @@ func (w *wdServiceImpl) SubmitWithdrawal(
 		UserID:    agent.IdentityID(),
 		From:      "withdrawal_service",
 		UserAgent: identity_iface.ImporterAgent,
-	}, pay)
+	}, pay, &TaskOption{
+		AllowReimport: pay.Reimport,
+		DryRun:        pay.DryRun,
+	})
 	if err != nil {
 		return connect.NewResponse(&result), err
 	}
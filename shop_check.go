@@ -0,0 +1,90 @@
+package withdrawal_service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/gorm"
+)
+
+type shopOrderCount struct {
+	OrderMpID uint
+	Count     int
+}
+
+// CheckShopOrders memastikan file milik toko yang dipilih dengan mencocokkan order ref id ke order_mp_id.
+// dipakai untuk file yang tidak punya identitas toko (tiktok, mengantar). file ditolak kalau order
+// yang ketemu lebih banyak milik toko lain, order yang belum ada di database atau belum punya marketplace tidak dihitung
+func CheckShopOrders(db *gorm.DB, teamID, mpID uint, refIDs []string) error {
+	uniq := map[string]bool{}
+	refs := []string{}
+	for _, refID := range refIDs {
+		if refID == "" || uniq[refID] {
+			continue
+		}
+		uniq[refID] = true
+		refs = append(refs, refID)
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	counts := []*shopOrderCount{}
+	err := db.
+		Model(&db_models.Order{}).
+		Select("order_mp_id, count(*) as count").
+		Where("team_id = ?", teamID).
+		Where("order_ref_id IN ?", refs).
+		Where("order_mp_id != 0").
+		Group("order_mp_id").
+		Find(&counts).
+		Error
+	if err != nil {
+		return err
+	}
+
+	var valid int
+	var other *shopOrderCount
+	for _, c := range counts {
+		if c.OrderMpID == mpID {
+			valid = c.Count
+			continue
+		}
+		if other == nil || c.Count > other.Count {
+			other = c
+		}
+	}
+
+	if other == nil || other.Count <= valid {
+		return nil
+	}
+
+	shop := strconv.FormatUint(uint64(other.OrderMpID), 10)
+	market := db_models.Marketplace{}
+	err = db.
+		Model(&db_models.Marketplace{}).
+		Where("id = ?", other.OrderMpID).
+		Find(&market).
+		Error
+	if err != nil {
+		return err
+	}
+	if market.MpName != "" {
+		shop = market.MpName
+	}
+
+	return wd_error.New(
+		wd_error.CodeMarketplaceMismatch,
+		fmt.Sprintf("file kemungkinan milik toko %s, %d order milik toko tersebut dan %d order milik toko yang dipilih", shop, other.Count, valid),
+		wd_error.Params{
+			"mp_id":         strconv.FormatUint(uint64(mpID), 10),
+			"other_mp_id":   strconv.FormatUint(uint64(other.OrderMpID), 10),
+			"other_mp_name": market.MpName,
+			"valid_count":   strconv.Itoa(valid),
+			"other_count":   strconv.Itoa(other.Count),
+		},
+	)
+}
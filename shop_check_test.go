@@ -0,0 +1,71 @@
+package withdrawal_service_test
+
+import (
+	"testing"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/pdcgo/withdrawal_service"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCheckShopOrders(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "cek order milik toko yang dipilih",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(&db_models.Order{}, &db_models.Marketplace{})
+				assert.Nil(t, err)
+
+				err = db.Create(&db_models.Marketplace{ID: 2, TeamID: 1, MpName: "toko lain"}).Error
+				assert.Nil(t, err)
+
+				orders := []*db_models.Order{
+					{ID: 1, TeamID: 1, OrderRefID: "ORD001", OrderMpID: 1},
+					{ID: 2, TeamID: 1, OrderRefID: "ORD002", OrderMpID: 2},
+					{ID: 3, TeamID: 1, OrderRefID: "ORD003", OrderMpID: 2},
+					{ID: 4, TeamID: 2, OrderRefID: "ORD004", OrderMpID: 1},
+					{ID: 5, TeamID: 1, OrderRefID: "ORD006", OrderMpID: 0},
+					{ID: 6, TeamID: 1, OrderRefID: "ORD007", OrderMpID: 0},
+				}
+				err = db.Create(&orders).Error
+				assert.Nil(t, err)
+
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			t.Run("order milik toko yang dipilih", func(t *testing.T) {
+				err := withdrawal_service.CheckShopOrders(&db, 1, 1, []string{"ORD001", "ORD005"})
+				assert.Nil(t, err)
+			})
+
+			t.Run("order belum ada tidak diblok", func(t *testing.T) {
+				err := withdrawal_service.CheckShopOrders(&db, 1, 1, []string{"ORD005", ""})
+				assert.Nil(t, err)
+			})
+
+			t.Run("order team lain tidak dihitung", func(t *testing.T) {
+				err := withdrawal_service.CheckShopOrders(&db, 2, 1, []string{"ORD002", "ORD004"})
+				assert.Nil(t, err)
+			})
+
+			t.Run("order tanpa marketplace tidak dianggap toko lain", func(t *testing.T) {
+				err := withdrawal_service.CheckShopOrders(&db, 1, 1, []string{"ORD001", "ORD006", "ORD007"})
+				assert.Nil(t, err)
+			})
+
+			t.Run("file milik toko lain", func(t *testing.T) {
+				err := withdrawal_service.CheckShopOrders(&db, 1, 1, []string{"ORD001", "ORD002", "ORD003", "ORD003"})
+				assert.Equal(t, wd_error.CodeMarketplaceMismatch, wd_error.From(err).Code)
+				assert.Equal(t, "2", wd_error.From(err).Params["other_mp_id"])
+				assert.Equal(t, "toko lain", wd_error.From(err).Params["other_mp_name"])
+			})
+		},
+	)
+}
@@ -1,6 +1,7 @@
 package withdrawal_service
 
 import (
+	"context"
 	"log/slog"
 	"time"
 
@@ -9,55 +10,301 @@ import (
 	"github.com/pdcgo/shared/yenstream"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+type TaskOption struct {
+	AllowReimport bool
+	DryRun        bool
+}
+
 type TaskStore interface {
-	Add(identity *authorization.JwtIdentity, payload *withdrawal_iface.SubmitWithdrawalRequest) error
+	Add(identity *authorization.JwtIdentity, payload *withdrawal_iface.SubmitWithdrawalRequest, opt *TaskOption) error
 	GetTx() *gorm.DB
 	SetErr(taskID uint, err error) error
 	SetFinish(taskID uint) error
 	SetProcess(taskID uint) error
-	EmptyTask() error
+	SetProgress(taskID uint, parsed int, processed int) error
+	SetSummary(taskID uint, summary *TaskSummary) error
+	SetPreview(taskID uint, preview *TaskPreview) error
+	Claim(owner string) (*TaskItem, error)
+	Notifier() TaskNotifier
+	Cancel(teamID uint64, taskID uint, userID uint) (*TaskItem, error)
+	Revert(teamID uint64, taskID uint, userID uint) (*TaskItem, int, error)
+	TaskContext(ctx context.Context, task *TaskItem) (context.Context, context.CancelFunc)
+	PruneTask() error
 }
 
 type tempStore struct {
-	db *gorm.DB
+	db       *gorm.DB
+	retry    *TaskRetryConfig
+	notifier TaskNotifier
+	running  *runningTasks
+	keep     *TaskRetentionConfig
 }
 
-// EmptyTask implements TaskStore.
-func (t *tempStore) EmptyTask() error {
-	return t.db.Model(&TaskItem{}).Where("status in ?", []withdrawal_iface.TaskStatus{withdrawal_iface.TaskStatus_TASK_STATUS_FINISH}).Delete(&TaskItem{}).Error
+// Cancel implements TaskStore.
+func (t *tempStore) Cancel(teamID uint64, taskID uint, userID uint) (*TaskItem, error) {
+	task := TaskItem{
+		TaskItem: &withdrawal_iface.TaskItem{},
+	}
+
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Model(&TaskItem{}).
+			Where("id = ?", taskID).
+			Where("team_id = ?", teamID).
+			Find(&task).
+			Error
+
+		if err != nil {
+			return err
+		}
+
+		if task.ID == 0 {
+			return ErrTaskNotFound
+		}
+
+		switch task.Status {
+		case withdrawal_iface.TaskStatus_TASK_STATUS_WAITING,
+			withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS:
+		default:
+			return ErrTaskNotCancellable
+		}
+
+		task.Status = withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED
+		task.CancelledBy = userID
+		task.CancelledAt = time.Now().Unix()
+		task.FinishedAt = task.CancelledAt
+
+		return tx.Model(&TaskItem{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"status":           task.Status,
+			"cancelled_by":     task.CancelledBy,
+			"cancelled_at":     task.CancelledAt,
+			"finished_at":      task.FinishedAt,
+			"lease_owner":      "",
+			"lease_expired_at": 0,
+		}).Error
+	})
+
+	if err != nil {
+		return &task, err
+	}
+
+	// task yang sedang jalan di instance ini langsung dihentikan
+	t.running.cancel(task.ID)
+	return &task, nil
 }
 
-// SetProcess implements TaskStore.
-func (t *tempStore) SetProcess(taskID uint) error {
+// Revert implements TaskStore.
+func (t *tempStore) Revert(teamID uint64, taskID uint, userID uint) (*TaskItem, int, error) {
+	return revertTask(t.db, teamID, taskID, userID)
+}
+
+// TaskContext implements TaskStore.
+func (t *tempStore) TaskContext(ctx context.Context, task *TaskItem) (context.Context, context.CancelFunc) {
+	taskCtx, cancel := context.WithCancel(ctx)
+	t.running.add(task.ID, cancel)
+	go runHeartbeat(taskCtx, t.db, task, cancel)
+
+	return taskCtx, func() {
+		t.running.remove(task.ID)
+		cancel()
+	}
+}
+
+// Notifier implements TaskStore.
+func (t *tempStore) Notifier() TaskNotifier {
+	return t.notifier
+}
+
+// PruneTask implements TaskStore.
+func (t *tempStore) PruneTask() error {
+	// task selesai disimpan sebagai history, hanya yang lewat masa retensi yang dihapus
+	if t.keep.Retention == 0 {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-t.keep.Retention).Unix()
+	expired := t.db.
+		Model(&TaskItem{}).
+		Select("id").
+		Where("status in ?", terminalTaskStatus).
+		Where("finished_at > 0 and finished_at < ?", cutoff)
+
+	// catatan revert ikut dihapus, task yang sudah lewat retensi tidak bisa direvert
+	err := t.db.
+		Where("task_id IN (?)", expired).
+		Delete(&TaskChange{}).
+		Error
+	if err != nil {
+		return err
+	}
+
+	return t.db.
+		Model(&TaskItem{}).
+		Where("status in ?", terminalTaskStatus).
+		Where("finished_at > 0 and finished_at < ?", cutoff).
+		Delete(&TaskItem{}).
+		Error
+}
+
+// Claim implements TaskStore.
+func (t *tempStore) Claim(owner string) (*TaskItem, error) {
+	return claimTask(t.db, owner)
+}
+
+// SetProgress implements TaskStore.
+func (t *tempStore) SetProgress(taskID uint, parsed int, processed int) error {
 	return t.db.Model(&TaskItem{}).Where("id = ?", taskID).Updates(map[string]interface{}{
-		"is_err":            false,
-		"err_message":       "",
-		"status":            withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
-		"last_processed_at": time.Now().Unix(),
+		"rows_parsed":    parsed,
+		"rows_processed": processed,
 	}).Error
 }
 
-// SetFinish implements TaskStore.
-func (t *tempStore) SetFinish(taskID uint) error {
+// SetPreview implements TaskStore.
+func (t *tempStore) SetPreview(taskID uint, preview *TaskPreview) error {
+	return t.db.Model(&TaskItem{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+		"preview": datatypes.NewJSONType(preview),
+	}).Error
+}
+
+// SetSummary implements TaskStore.
+func (t *tempStore) SetSummary(taskID uint, summary *TaskSummary) error {
 	return t.db.Model(&TaskItem{}).Where("id = ?", taskID).Updates(map[string]interface{}{
-		"is_err":      false,
-		"err_message": "",
-		"status":      withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+		"summary": datatypes.NewJSONType(summary),
 	}).Error
 }
 
+// SetProcess implements TaskStore.
+func (t *tempStore) SetProcess(taskID uint) error {
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		task := TaskItem{
+			TaskItem: &withdrawal_iface.TaskItem{},
+		}
+		err := tx.Model(&TaskItem{}).Where("id = ?", taskID).Find(&task).Error
+		if err != nil {
+			return err
+		}
+
+		// sisa set dari attempt sebelumnya yang berhenti karena crash dibatalkan dulu
+		if task.Attempt > 0 {
+			err = rollbackTaskSets(tx, &task)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&TaskItem{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+			"is_err":            false,
+			"err_message":       "",
+			"status":            withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+			"last_processed_at": time.Now().Unix(),
+			"attempt":           gorm.Expr("attempt + 1"),
+		}).Error
+	})
+}
+
+// SetFinish implements TaskStore.
+// fingerprint file dicatat di transaksi yang sama, file duplikat membuat task gagal selesai
+func (t *tempStore) SetFinish(taskID uint) error {
+	now := time.Now().Unix()
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&TaskItem{}).Where("id = ?", taskID).Where("status != ?", withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED).Updates(map[string]interface{}{
+			"is_err":           false,
+			"err_message":      "",
+			"status":           withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+			"lease_owner":      "",
+			"lease_expired_at": 0,
+			"finished_at":      now,
+			"duration":         gorm.Expr("? - last_processed_at", now),
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+
+		task := TaskItem{}
+		err := tx.Model(&TaskItem{}).Where("id = ?", taskID).First(&task).Error
+		if err != nil {
+			return err
+		}
+
+		// dry run tidak dicatat sebagai file yang sudah diimport
+		if task.DryRun || task.ContentHash == "" {
+			return nil
+		}
+
+		return SaveFileFingerprint(tx, &FileFingerprint{
+			Scope:       FingerprintV1,
+			TeamID:      uint(task.TeamId),
+			MpID:        uint(task.MpId),
+			ContentHash: task.ContentHash,
+			ResourceUri: task.ResourceUri,
+			TaskID:      task.ID,
+			UserID:      task.AgentData.Data().UserID,
+			Reimport:    task.AllowReimport,
+		})
+	})
+}
+
 // SetErr implements TaskStore.
 func (t *tempStore) SetErr(taskID uint, err error) error {
 	if err == nil {
 		return nil
 	}
-	return t.db.Model(&TaskItem{}).Where("id = ?", taskID).Updates(map[string]interface{}{
-		"is_err":      true,
-		"err_message": err.Error(),
-		"status":      withdrawal_iface.TaskStatus_TASK_STATUS_ERROR,
-	}).Error
+
+	coded := taskError(err)
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		task := TaskItem{
+			TaskItem: &withdrawal_iface.TaskItem{},
+		}
+		qerr := tx.Model(&TaskItem{}).Where("id = ?", taskID).Find(&task).Error
+		if qerr != nil {
+			return qerr
+		}
+
+		retry := IsRetryableErr(err) && task.Attempt < t.retry.MaxAttempt
+
+		// set withdrawal yang sudah commit dibatalkan, task tidak boleh diulang di atas data setengah jalan
+		rerr := rollbackTaskSets(tx, &task)
+		if rerr != nil {
+			// data masih setengah jalan, tidak di retry supaya tidak tercatat dua kali. revert manual
+			slog.Error(rerr.Error(), slog.Uint64("task_id", uint64(taskID)), slog.String("function", "rollback_task_sets"))
+			retry = false
+		}
+
+		// error sementara dikembalikan ke waiting sampai batas attempt
+		if retry {
+			return tx.Model(&TaskItem{}).Where("id = ?", taskID).Where("status != ?", withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED).Updates(map[string]interface{}{
+				"is_err":           true,
+				"err_message":      err.Error(),
+				"err_code":         coded.Code,
+				"err_category":     coded.Category,
+				"err_params":       datatypes.NewJSONType(coded.Params),
+				"status":           withdrawal_iface.TaskStatus_TASK_STATUS_WAITING,
+				"next_run_at":      time.Now().Add(t.retry.Backoff(task.Attempt)).Unix(),
+				"lease_owner":      "",
+				"lease_expired_at": 0,
+			}).Error
+		}
+
+		// task yang dibatalkan tetap cancelled, error karena context cancel tidak menimpa
+		now := time.Now().Unix()
+		return tx.Model(&TaskItem{}).Where("id = ?", taskID).Where("status != ?", withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED).Updates(map[string]interface{}{
+			"is_err":           true,
+			"err_message":      err.Error(),
+			"err_code":         coded.Code,
+			"err_category":     coded.Category,
+			"err_params":       datatypes.NewJSONType(coded.Params),
+			"status":           withdrawal_iface.TaskStatus_TASK_STATUS_ERROR,
+			"lease_owner":      "",
+			"lease_expired_at": 0,
+			"finished_at":      now,
+			"duration":         gorm.Expr("? - last_processed_at", now),
+		}).Error
+	})
 }
 
 // GetTx implements TaskStore.
@@ -66,8 +313,17 @@ func (t *tempStore) GetTx() *gorm.DB {
 }
 
 // Add implements TaskStore.
-func (t *tempStore) Add(identity *authorization.JwtIdentity, payload *withdrawal_iface.SubmitWithdrawalRequest) error {
+func (t *tempStore) Add(identity *authorization.JwtIdentity, payload *withdrawal_iface.SubmitWithdrawalRequest, opt *TaskOption) error {
 	var err error
+	if opt == nil {
+		opt = &TaskOption{}
+	}
+
+	hash, err := checkSubmitFile(t.db, payload, opt)
+	if err != nil {
+		return err
+	}
+
 	task := TaskItem{
 		TaskItem: &withdrawal_iface.TaskItem{
 			TeamId:      payload.TeamId,
@@ -78,21 +334,37 @@ func (t *tempStore) Add(identity *authorization.JwtIdentity, payload *withdrawal
 			ResourceUri: payload.ResourceUri,
 			CreatedAt:   time.Now().Unix(),
 		},
-		AgentData: datatypes.NewJSONType(identity),
+		AgentData:     datatypes.NewJSONType(identity),
+		ContentHash:   hash,
+		AllowReimport: opt.AllowReimport,
+		DryRun:        opt.DryRun,
 	}
 	err = t.db.Save(&task).Error
-	return err
+	if err != nil {
+		return err
+	}
+
+	// gagal notify tidak masalah, task tetap diambil saat polling
+	err = t.notifier.Notify(context.Background())
+	if err != nil {
+		slog.Error(err.Error(), slog.String("function", "task_notify"))
+	}
+	return nil
 }
 
 func NewTempStore(db *gorm.DB) TaskStore {
 
-	err := db.AutoMigrate(&TaskItem{})
+	err := db.AutoMigrate(&TaskItem{}, &FileFingerprint{}, &WDResourceHash{}, &TaskChange{}, &WithdrawalReconciliation{}, &ColumnMappingConfig{}, &WdOrderNotFoundResolution{}, &WdOrderNotFoundCheck{})
 	if err != nil {
 		panic(err)
 	}
 
 	return &tempStore{
-		db: db,
+		db:       db,
+		retry:    NewTaskRetryConfig(),
+		notifier: NewTaskNotifier(db),
+		running:  newRunningTasks(),
+		keep:     NewTaskRetentionConfig(),
 	}
 }
 
@@ -104,6 +376,8 @@ type taskSource struct {
 	closeImmediate bool
 	out            yenstream.NodeOut
 	label          string
+	owner          string
+	notifier       TaskNotifier
 }
 
 // Out implements yenstream.Source.
@@ -123,15 +397,52 @@ func (t *taskSource) Via(label string, pipe yenstream.Pipeline) yenstream.Pipeli
 }
 
 func (t *taskSource) process() {
-	var err error
 	out := t.out.C()
 	defer close(out)
 
+	var notify <-chan struct{}
+	if t.notifier != nil {
+		sub, unsubscribe := t.notifier.Subscribe()
+		defer unsubscribe()
+		notify = sub
+	}
+
 	timeoutD := time.Minute * 1
 	timeout := time.NewTimer(timeoutD)
 	defer timeout.Stop()
-Parent:
+
+	poll := time.NewTicker(taskPollInterval)
+	defer poll.Stop()
+
 	for {
+		now := time.Now()
+		task, err := claimTask(t.db, t.owner)
+		if err != nil {
+			slog.Error(err.Error(), slog.String("label", t.label))
+			return
+		}
+
+		if task != nil {
+			select {
+			case out <- task:
+			case <-t.ctx.Done():
+				slog.Info("source closed", slog.String("label", t.label))
+				return
+			}
+			timeout.Reset(timeoutD)
+			continue
+		}
+
+		if t.closeImmediate {
+			return
+		}
+
+		// masih ada task yang menunggu retry, source jangan ditutup dulu
+		if t.hasPendingRetry(now) {
+			timeout.Reset(timeoutD)
+		}
+
+		// tunggu ada task baru, tidak query terus menerus
 		select {
 		case <-timeout.C:
 			slog.Info("source closed", slog.String("label", t.label))
@@ -139,58 +450,40 @@ Parent:
 		case <-t.ctx.Done():
 			slog.Info("source closed", slog.String("label", t.label))
 			return
-		default:
-			task := TaskItem{
-				TaskItem: &withdrawal_iface.TaskItem{},
-			}
-
-			maxProc := time.Now().Add(time.Hour * -6)
-
-			err = t.
-				db.
-				Model(&TaskItem{}).
-				Where("status = ? or (last_processed_at < ?  and status = ?)",
-					withdrawal_iface.TaskStatus_TASK_STATUS_WAITING,
-					maxProc.Unix(),
-					withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
-				).
-				Order("id asc").
-				Find(&task).
-				Error
+		case <-notify:
+		case <-poll.C:
+		}
+	}
 
-			if err != nil {
-				slog.Error(err.Error(), slog.String("label", t.label))
-				return
-			}
+}
 
-			if task.ID != 0 {
-				out <- &task
-			} else {
-				if t.closeImmediate {
-					return
-				}
-				continue Parent
-			}
+func (t *taskSource) hasPendingRetry(now time.Time) bool {
+	var count int64
+	err := t.
+		db.
+		Model(&TaskItem{}).
+		Where("status = ?", withdrawal_iface.TaskStatus_TASK_STATUS_WAITING).
+		Where("next_run_at > ?", now.Unix()).
+		Count(&count).
+		Error
 
-			task.Status = withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS
-			task.TaskItem.LastProcessedAt = time.Now().Unix()
-			err = t.db.Save(&task).Error
-			if err != nil {
-				slog.Error(err.Error(), slog.String("label", t.label))
-			}
-			timeout.Reset(timeoutD)
-		}
+	if err != nil {
+		slog.Error(err.Error(), slog.String("label", t.label))
+		return false
 	}
 
+	return count > 0
 }
 
-func NewTaskSource(label string, db *gorm.DB, ctx *yenstream.RunnerContext, closeImmediate bool) *taskSource {
+func NewTaskSource(label string, db *gorm.DB, notifier TaskNotifier, ctx *yenstream.RunnerContext, closeImmediate bool) *taskSource {
 	source := &taskSource{
 		db:             db,
 		ctx:            ctx,
 		out:            yenstream.NewNodeOut(ctx),
 		closeImmediate: closeImmediate,
 		label:          label,
+		owner:          NewWorkerID(label),
+		notifier:       notifier,
 	}
 
 	ctx.AddProcess(source.process)
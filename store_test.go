@@ -1,6 +1,7 @@
 package withdrawal_service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -10,6 +11,8 @@ import (
 	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
 	"github.com/pdcgo/shared/yenstream"
 	"github.com/pdcgo/withdrawal_service"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -81,7 +84,7 @@ func TestStoreTask(t *testing.T) {
 						NewRunnerContext(t.Context()).
 						CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
 							store := withdrawal_service.
-								NewTaskSource("wd", &db, ctx, true)
+								NewTaskSource("wd", &db, nil, ctx, true)
 
 							return store.Via("mapping test", yenstream.NewMap(ctx, func(item any) (any, error) {
 								count += 1
@@ -120,12 +123,325 @@ func TestStoreTask(t *testing.T) {
 						err := db.Model(&withdrawal_service.TaskItem{}).Where("id = ?", 1).First(&item).Error
 						assert.Nil(t, err)
 						assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_ERROR, item.Status)
+						assert.Equal(t, wd_error.CodeUnknown, item.ErrCode)
+						assert.Equal(t, wd_error.CategoryBug, item.ErrCategory)
+					})
+				},
+			)
+
+			moretest.Suite(t, "test task retry",
+				moretest.SetupListFunc{
+					func(t *testing.T) func() error {
+						task := withdrawal_service.TaskItem{
+							ID:      2,
+							Attempt: 1,
+							TaskItem: &withdrawal_iface.TaskItem{
+								Status: withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+							},
+						}
+
+						err := db.Save(&task).Error
+						assert.Nil(t, err)
+
+						return func() error {
+							return db.Delete(&task).Error
+						}
+					},
+				},
+				func(t *testing.T) {
+					t.Setenv("WD_TASK_MAX_ATTEMPT", "2")
+					st := withdrawal_service.NewTempStore(&db)
+
+					getTask := func(t *testing.T) withdrawal_service.TaskItem {
+						item := withdrawal_service.TaskItem{}
+						err := db.Model(&withdrawal_service.TaskItem{}).Where("id = ?", 2).First(&item).Error
+						assert.Nil(t, err)
+						return item
+					}
+
+					t.Run("error sementara kembali ke waiting", func(t *testing.T) {
+						err := st.SetErr(2, withdrawal_service.NewRetryableErr(errors.New("storage timeout")))
+						assert.Nil(t, err)
+
+						item := getTask(t)
+						assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_WAITING, item.Status)
+						assert.True(t, item.IsErr)
+						assert.Greater(t, item.NextRunAt, time.Now().Unix())
+						assert.Equal(t, wd_error.CodeTemporary, item.ErrCode)
+						assert.Equal(t, wd_error.CategoryRetryable, item.ErrCategory)
+					})
+
+					t.Run("melebihi max attempt jadi error", func(t *testing.T) {
+						err := st.SetProcess(2)
+						assert.Nil(t, err)
+
+						err = st.SetErr(2, withdrawal_service.NewRetryableErr(errors.New("storage timeout")))
+						assert.Nil(t, err)
+
+						item := getTask(t)
+						assert.Equal(t, 2, item.Attempt)
+						assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_ERROR, item.Status)
+					})
 
+					t.Run("error permanen langsung error", func(t *testing.T) {
+						err := db.Model(&withdrawal_service.TaskItem{}).Where("id = ?", 2).Update("attempt", 0).Error
+						assert.Nil(t, err)
+
+						err = st.SetErr(2, datasource.ErrContainInProcessWD)
+						assert.Nil(t, err)
+
+						item := getTask(t)
+						assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_ERROR, item.Status)
+						assert.Equal(t, wd_error.CodeWdInProcess, item.ErrCode)
+						assert.Equal(t, wd_error.CategoryUser, item.ErrCategory)
 					})
 				},
 			)
 
+			moretest.Suite(t, "test claim task",
+				moretest.SetupListFunc{
+					func(t *testing.T) func() error {
+						tasks := []*withdrawal_service.TaskItem{
+							{
+								ID:             3,
+								LeaseOwner:     "worker_lain",
+								LeaseExpiredAt: time.Now().Add(time.Hour).Unix(),
+								TaskItem: &withdrawal_iface.TaskItem{
+									Status:          withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+									LastProcessedAt: time.Now().AddDate(0, -1, 0).Unix(),
+								},
+							},
+							{
+								ID: 4,
+								TaskItem: &withdrawal_iface.TaskItem{
+									Status: withdrawal_iface.TaskStatus_TASK_STATUS_WAITING,
+								},
+							},
+						}
+
+						err := db.Save(&tasks).Error
+						assert.Nil(t, err)
+
+						return func() error {
+							return db.Delete(&tasks).Error
+						}
+					},
+				},
+				func(t *testing.T) {
+					st := withdrawal_service.NewTempStore(&db)
+
+					task, err := st.Claim("worker_a")
+					assert.Nil(t, err)
+					assert.NotNil(t, task)
+					assert.Equal(t, uint(4), task.ID)
+					assert.Equal(t, "worker_a", task.LeaseOwner)
+					assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS, task.Status)
+
+					t.Run("task yang lease nya masih aktif tidak di claim ulang", func(t *testing.T) {
+						task, err := st.Claim("worker_b")
+						assert.Nil(t, err)
+						assert.Nil(t, task)
+					})
+
+					t.Run("heartbeat terlewat bisa di claim worker lain", func(t *testing.T) {
+						err := db.Model(&withdrawal_service.TaskItem{}).Where("id = ?", 3).Update("lease_expired_at", time.Now().Add(-time.Minute).Unix()).Error
+						assert.Nil(t, err)
+
+						task, err := st.Claim("worker_b")
+						assert.Nil(t, err)
+						assert.NotNil(t, task)
+						assert.Equal(t, uint(3), task.ID)
+						assert.Equal(t, "worker_b", task.LeaseOwner)
+						assert.Greater(t, task.HeartbeatAt, int64(0))
+						assert.Greater(t, task.LeaseExpiredAt, time.Now().Unix())
+					})
+
+					t.Run("finish melepas lease", func(t *testing.T) {
+						err := st.SetFinish(3)
+						assert.Nil(t, err)
+
+						item := withdrawal_service.TaskItem{}
+						err = db.Model(&withdrawal_service.TaskItem{}).Where("id = ?", 3).First(&item).Error
+						assert.Nil(t, err)
+						assert.Equal(t, "", item.LeaseOwner)
+						assert.Equal(t, int64(0), item.LeaseExpiredAt)
+					})
+				},
+			)
+
+			moretest.Suite(t, "test cancel task",
+				moretest.SetupListFunc{
+					func(t *testing.T) func() error {
+						tasks := []*withdrawal_service.TaskItem{
+							{
+								ID: 5,
+								TaskItem: &withdrawal_iface.TaskItem{
+									TeamId: 1,
+									Status: withdrawal_iface.TaskStatus_TASK_STATUS_WAITING,
+								},
+							},
+							{
+								ID: 6,
+								TaskItem: &withdrawal_iface.TaskItem{
+									TeamId: 1,
+									Status: withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+								},
+							},
+							{
+								ID: 7,
+								TaskItem: &withdrawal_iface.TaskItem{
+									TeamId: 1,
+									Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+								},
+							},
+						}
+
+						err := db.Save(&tasks).Error
+						assert.Nil(t, err)
+
+						return func() error {
+							return db.Delete(&tasks).Error
+						}
+					},
+				},
+				func(t *testing.T) {
+					st := withdrawal_service.NewTempStore(&db)
+
+					t.Run("cancel task waiting", func(t *testing.T) {
+						task, err := st.Cancel(1, 5, 10)
+						assert.Nil(t, err)
+						assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED, task.Status)
+						assert.Equal(t, uint(10), task.CancelledBy)
+
+						claimed, err := st.Claim("worker_a")
+						assert.Nil(t, err)
+						if claimed != nil {
+							assert.NotEqual(t, uint(5), claimed.ID)
+						}
+					})
+
+					t.Run("cancel task process menghentikan context", func(t *testing.T) {
+						ctx, done := st.TaskContext(t.Context(), &withdrawal_service.TaskItem{ID: 6})
+						defer done()
+
+						_, err := st.Cancel(1, 6, 10)
+						assert.Nil(t, err)
+						assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+						err = st.SetErr(6, ctx.Err())
+						assert.Nil(t, err)
+
+						item := withdrawal_service.TaskItem{}
+						err = db.Model(&withdrawal_service.TaskItem{}).Where("id = ?", 6).First(&item).Error
+						assert.Nil(t, err)
+						assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED, item.Status)
+					})
+
+					t.Run("task beda team tidak ketemu", func(t *testing.T) {
+						_, err := st.Cancel(2, 7, 10)
+						assert.ErrorIs(t, err, withdrawal_service.ErrTaskNotFound)
+					})
+
+					t.Run("task selesai tidak bisa dibatalkan", func(t *testing.T) {
+						_, err := st.Cancel(1, 7, 10)
+						assert.ErrorIs(t, err, withdrawal_service.ErrTaskNotCancellable)
+					})
+				},
+			)
+
+		},
+	)
+
+}
+
+func TestTaskHistory(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "test task history",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(&withdrawal_service.TaskItem{})
+				assert.Nil(t, err)
+
+				tasks := []*withdrawal_service.TaskItem{
+					{
+						ID: 1,
+						TaskItem: &withdrawal_iface.TaskItem{
+							Status:          withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+							LastProcessedAt: time.Now().Add(-time.Minute).Unix(),
+						},
+					},
+					{
+						ID:         2,
+						FinishedAt: time.Now().AddDate(0, 0, -10).Unix(),
+						TaskItem: &withdrawal_iface.TaskItem{
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+				}
+				err = db.Save(&tasks).Error
+				assert.Nil(t, err)
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			t.Setenv("WD_TASK_RETENTION_DAYS", "7")
+			st := withdrawal_service.NewTempStore(&db)
+
+			err := st.SetFinish(1)
+			assert.Nil(t, err)
+
+			err = st.PruneTask()
+			assert.Nil(t, err)
+
+			tasks := []*withdrawal_service.TaskItem{}
+			err = db.Model(&withdrawal_service.TaskItem{}).Find(&tasks).Error
+			assert.Nil(t, err)
+			assert.Len(t, tasks, 1)
+
+			task := tasks[0]
+			assert.Equal(t, uint(1), task.ID)
+			assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_FINISH, task.Status)
+			assert.NotZero(t, task.FinishedAt)
+			assert.GreaterOrEqual(t, task.Duration, int64(60))
 		},
 	)
+}
+
+func TestLocalNotifier(t *testing.T) {
+	notifier := withdrawal_service.NewLocalNotifier()
+
+	sub, unsubscribe := notifier.Subscribe()
+	sub2, unsubscribe2 := notifier.Subscribe()
+	defer unsubscribe2()
 
+	err := notifier.Notify(t.Context())
+	assert.Nil(t, err)
+
+	// notify berturut turut tidak boleh block walau belum dibaca
+	err = notifier.Notify(t.Context())
+	assert.Nil(t, err)
+
+	select {
+	case <-sub:
+	default:
+		t.Error("subscriber pertama tidak dapat notifikasi")
+	}
+
+	select {
+	case <-sub2:
+	default:
+		t.Error("subscriber kedua tidak dapat notifikasi")
+	}
+
+	unsubscribe()
+	err = notifier.Notify(t.Context())
+	assert.Nil(t, err)
+
+	select {
+	case <-sub:
+		t.Error("subscriber yang sudah unsubscribe masih dapat notifikasi")
+	default:
+	}
 }
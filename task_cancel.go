@@ -0,0 +1,45 @@
+package withdrawal_service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pdcgo/withdrawal_service/wd_error"
+)
+
+var ErrTaskNotFound = wd_error.New(wd_error.CodeTaskNotFound, "task tidak ditemukan", nil)
+var ErrTaskNotCancellable = wd_error.New(wd_error.CodeTaskNotCancellable, "task sudah selesai, tidak bisa dibatalkan", nil)
+
+type runningTasks struct {
+	mut     sync.Mutex
+	cancels map[uint]context.CancelFunc
+}
+
+func newRunningTasks() *runningTasks {
+	return &runningTasks{
+		cancels: map[uint]context.CancelFunc{},
+	}
+}
+
+func (r *runningTasks) add(taskID uint, cancel context.CancelFunc) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.cancels[taskID] = cancel
+}
+
+func (r *runningTasks) remove(taskID uint) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	delete(r.cancels, taskID)
+}
+
+func (r *runningTasks) cancel(taskID uint) bool {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	cancel, ok := r.cancels[taskID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
@@ -0,0 +1,113 @@
+package withdrawal_service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var instanceID = func() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}()
+
+// NewWorkerID id unik per worker, dipakai sebagai lease owner task
+func NewWorkerID(label string) string {
+	return fmt.Sprintf("%s-%s", instanceID, label)
+}
+
+// claimTask mengambil satu task siap proses secara atomik, nil jika tidak ada
+func claimTask(db *gorm.DB, owner string) (*TaskItem, error) {
+	var claimed *TaskItem
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		task := TaskItem{
+			TaskItem: &withdrawal_iface.TaskItem{},
+		}
+
+		now := time.Now()
+		maxHeartbeat := now.Add(-taskHeartbeatTimeout)
+
+		err := tx.
+			Clauses(clause.Locking{
+				Strength: "UPDATE",
+				Options:  "SKIP LOCKED",
+			}).
+			Model(&TaskItem{}).
+			Where(
+				tx.
+					Where("status = ?", withdrawal_iface.TaskStatus_TASK_STATUS_WAITING).
+					Where("(next_run_at is null or next_run_at <= ?)", now.Unix()),
+			).
+			Or(
+				tx.
+					Where("status = ?", withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS).
+					Where("((lease_expired_at > 0 and lease_expired_at < ?) or (coalesce(lease_expired_at, 0) = 0 and last_processed_at < ?))",
+						now.Unix(),
+						maxHeartbeat.Unix(),
+					),
+			).
+			Order("id asc").
+			Limit(1).
+			Find(&task).
+			Error
+
+		if err != nil {
+			return err
+		}
+
+		if task.ID == 0 {
+			return nil
+		}
+
+		if task.Status == withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS {
+			slog.Warn("recovered after crash",
+				slog.Uint64("task_id", uint64(task.ID)),
+				slog.String("previous_owner", task.LeaseOwner),
+				slog.Int64("last_heartbeat_at", task.HeartbeatAt),
+				slog.String("owner", owner),
+			)
+		}
+
+		task.Status = withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS
+		task.TaskItem.LastProcessedAt = now.Unix()
+		task.LeaseOwner = owner
+		task.LeaseExpiredAt = now.Add(taskHeartbeatTimeout).Unix()
+		task.HeartbeatAt = now.Unix()
+		task.Attempt += 1
+		task.IsErr = false
+		task.ErrMessage = ""
+		task.ErrCode = ""
+		task.ErrCategory = ""
+
+		err = tx.Model(&TaskItem{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"is_err":            false,
+			"err_message":       "",
+			"err_code":          "",
+			"err_category":      "",
+			"status":            task.Status,
+			"last_processed_at": task.TaskItem.LastProcessedAt,
+			"lease_owner":       task.LeaseOwner,
+			"lease_expired_at":  task.LeaseExpiredAt,
+			"heartbeat_at":      task.HeartbeatAt,
+			"attempt":           task.Attempt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		claimed = &task
+		return nil
+	})
+
+	return claimed, err
+}
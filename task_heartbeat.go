@@ -0,0 +1,62 @@
+package withdrawal_service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"gorm.io/gorm"
+)
+
+// task process yang heartbeat nya lewat dari timeout dianggap runner nya crash
+var taskHeartbeatTimeout = heartbeatTimeoutFromEnv()
+
+func heartbeatTimeoutFromEnv() time.Duration {
+	timeout := time.Minute * 2
+
+	sec, err := strconv.Atoi(os.Getenv("WD_TASK_HEARTBEAT_TIMEOUT"))
+	if err == nil && sec > 0 {
+		timeout = time.Second * time.Duration(sec)
+	}
+
+	return timeout
+}
+
+// runHeartbeat memperpanjang lease selama task jalan, context dicancel kalau lease hilang
+// (task dibatalkan atau sudah diambil runner lain)
+func runHeartbeat(ctx context.Context, db *gorm.DB, task *TaskItem, cancel context.CancelFunc) {
+	tick := time.NewTicker(taskHeartbeatTimeout / 3)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			now := time.Now()
+			res := db.
+				Model(&TaskItem{}).
+				Where("id = ?", task.ID).
+				Where("lease_owner = ?", task.LeaseOwner).
+				Where("status = ?", withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS).
+				Updates(map[string]interface{}{
+					"heartbeat_at":     now.Unix(),
+					"lease_expired_at": now.Add(taskHeartbeatTimeout).Unix(),
+				})
+
+			if res.Error != nil {
+				slog.Error(res.Error.Error(), slog.Uint64("task_id", uint64(task.ID)))
+				continue
+			}
+
+			if res.RowsAffected == 0 {
+				slog.Warn("task lease lost, stop processing", slog.Uint64("task_id", uint64(task.ID)))
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package withdrawal_service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type TaskChangeEntity string
+
+const (
+	ChangeWithdrawal      TaskChangeEntity = "withdrawal"
+	ChangeOrderAdjustment TaskChangeEntity = "order_adjustment"
+	ChangeWdValid         TaskChangeEntity = "wd_valid"
+	ChangeWdOrderNotFound TaskChangeEntity = "wd_order_not_found"
+	ChangeOrder           TaskChangeEntity = "order"
+	ChangeOrderTimestamp  TaskChangeEntity = "order_timestamp"
+	ChangeInvTransaction  TaskChangeEntity = "inv_transaction"
+	ChangeInvTimestamp    TaskChangeEntity = "inv_timestamp"
+
+	// dari pencocokan ulang order tidak ditemukan
+	ChangeWdOrderNotFoundResolution TaskChangeEntity = "wd_order_not_found_resolution"
+)
+
+func (e TaskChangeEntity) model() interface{} {
+	switch e {
+	case ChangeWithdrawal:
+		return &db_models.Withdrawal{}
+	case ChangeOrderAdjustment:
+		return &db_models.OrderAdjustment{}
+	case ChangeWdValid:
+		return &db_models.WdValid{}
+	case ChangeWdOrderNotFound:
+		return &db_models.WdOrderNotFound{}
+	case ChangeOrder:
+		return &db_models.Order{}
+	case ChangeOrderTimestamp:
+		return &db_models.OrderTimestamp{}
+	case ChangeInvTransaction:
+		return &db_models.InvTransaction{}
+	case ChangeInvTimestamp:
+		return &db_models.InvTimestamp{}
+	case ChangeWdOrderNotFoundResolution:
+		return &WdOrderNotFoundResolution{}
+	}
+	return nil
+}
+
+// rowKey kolom unique untuk entity tanpa id, create tanpa id atau key tidak bisa direvert
+func (e TaskChangeEntity) rowKey() []string {
+	switch e {
+	case ChangeWdValid:
+		return []string{"withdrawal_id", "order_adjustment_id"}
+	}
+	return nil
+}
+
+// keyOf ambil kolom key dari data, false kalau entity tidak punya key atau ada kolom yang kosong
+func (e TaskChangeEntity) keyOf(data map[string]interface{}) (map[string]interface{}, bool) {
+	columns := e.rowKey()
+	if len(columns) == 0 {
+		return nil, false
+	}
+
+	key := map[string]interface{}{}
+	for _, column := range columns {
+		value, ok := data[column]
+		if !ok || value == nil || rowUint(value) == 0 {
+			return nil, false
+		}
+		key[column] = value
+	}
+	return key, true
+}
+
+type TaskChangeAction string
+
+const (
+	ChangeCreate TaskChangeAction = "create"
+	ChangeUpdate TaskChangeAction = "update"
+	ChangeDelete TaskChangeAction = "delete"
+)
+
+// TaskChange catatan perubahan data oleh satu task, dipakai untuk revert
+type TaskChange struct {
+	ID       uint `gorm:"primarykey"`
+	TaskID   uint `gorm:"index"`
+	Entity   TaskChangeEntity
+	Action   TaskChangeAction
+	EntityID uint
+	// update: nilai kolom sebelum diubah, delete: isi row yang dihapus, create tanpa id: kolom unique row
+	Data      datatypes.JSONMap
+	CreatedAt time.Time
+}
+
+// taskJournal mencatat perubahan di transaksi yang sama dengan perubahannya
+type taskJournal struct {
+	taskID  uint
+	created map[string]bool
+	updated map[string]bool
+}
+
+func newTaskJournal(taskID uint) *taskJournal {
+	return &taskJournal{
+		taskID:  taskID,
+		created: map[string]bool{},
+		updated: map[string]bool{},
+	}
+}
+
+func (j *taskJournal) enabled() bool {
+	return j != nil && j.taskID != 0
+}
+
+func (j *taskJournal) save(tx *gorm.DB, changes []*TaskChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
+
+func (j *taskJournal) createdKey(entity TaskChangeEntity, id uint) string {
+	return fmt.Sprintf("%s:%d", entity, id)
+}
+
+// Created entity baru dari task, revert nya dihapus
+func (j *taskJournal) Created(tx *gorm.DB, entity TaskChangeEntity, ids ...uint) error {
+	if !j.enabled() {
+		return nil
+	}
+
+	changes := []*TaskChange{}
+	for _, id := range ids {
+		key := j.createdKey(entity, id)
+		if id == 0 || j.created[key] {
+			continue
+		}
+		j.created[key] = true
+		changes = append(changes, &TaskChange{
+			TaskID:   j.taskID,
+			Entity:   entity,
+			Action:   ChangeCreate,
+			EntityID: id,
+		})
+	}
+
+	return j.save(tx, changes)
+}
+
+// CreatedRow untuk entity tanpa id, misal wd_valid, key harus lengkap sesuai rowKey
+func (j *taskJournal) CreatedRow(tx *gorm.DB, entity TaskChangeEntity, data map[string]interface{}) error {
+	if !j.enabled() {
+		return nil
+	}
+
+	key, ok := entity.keyOf(data)
+	if !ok {
+		return wd_error.New(
+			wd_error.CodeTaskChangeNoKey,
+			fmt.Sprintf("create %s tanpa key unique tidak bisa dicatat", entity),
+			wd_error.Params{"entity": string(entity)},
+		)
+	}
+
+	return j.save(tx, []*TaskChange{{
+		TaskID: j.taskID,
+		Entity: entity,
+		Action: ChangeCreate,
+		Data:   datatypes.JSONMap(key),
+	}})
+}
+
+// Deleted simpan isi row sebelum dihapus
+func (j *taskJournal) Deleted(tx *gorm.DB, entity TaskChangeEntity, rows []map[string]interface{}) error {
+	if !j.enabled() {
+		return nil
+	}
+
+	changes := []*TaskChange{}
+	for _, row := range rows {
+		changes = append(changes, &TaskChange{
+			TaskID: j.taskID,
+			Entity: entity,
+			Action: ChangeDelete,
+			Data:   datatypes.JSONMap(row),
+		})
+	}
+
+	return j.save(tx, changes)
+}
+
+// Updated simpan nilai kolom sebelum diubah yang sudah diketahui, hanya perubahan pertama yang dicatat
+func (j *taskJournal) Updated(tx *gorm.DB, entity TaskChangeEntity, id uint, before map[string]interface{}) error {
+	if !j.enabled() || id == 0 || j.created[j.createdKey(entity, id)] {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	for column, value := range before {
+		key := j.updatedKey(entity, id, column)
+		if j.updated[key] {
+			continue
+		}
+		j.updated[key] = true
+		data[column] = value
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return j.save(tx, []*TaskChange{{
+		TaskID:   j.taskID,
+		Entity:   entity,
+		Action:   ChangeUpdate,
+		EntityID: id,
+		Data:     datatypes.JSONMap(data),
+	}})
+}
+
+func (j *taskJournal) updatedKey(entity TaskChangeEntity, id uint, column string) string {
+	return fmt.Sprintf("%s:%d:%s", entity, id, column)
+}
+
+func (j *taskJournal) hasUpdated(entity TaskChangeEntity, id uint, columns []string) bool {
+	for _, column := range columns {
+		if !j.updated[j.updatedKey(entity, id, column)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Snapshot ambil nilai kolom sekarang dari database sebelum diubah
+func (j *taskJournal) Snapshot(tx *gorm.DB, entity TaskChangeEntity, ids []uint, columns ...string) error {
+	if !j.enabled() {
+		return nil
+	}
+
+	pending := []uint{}
+	for _, id := range ids {
+		if id == 0 || j.created[j.createdKey(entity, id)] {
+			continue
+		}
+		if j.hasUpdated(entity, id, columns) {
+			continue
+		}
+		pending = append(pending, id)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rows := []map[string]interface{}{}
+	err := tx.
+		Model(entity.model()).
+		Select(append([]string{"id"}, columns...)).
+		Where("id IN ?", pending).
+		Find(&rows).
+		Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		id := rowUint(row["id"])
+		delete(row, "id")
+
+		err = j.Updated(tx, entity, id, row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreatedAfter catat row yang id nya lebih besar dari lastID, dipakai untuk log yang dibuat di query lain
+func (j *taskJournal) CreatedAfter(tx *gorm.DB, entity TaskChangeEntity, lastID uint, query func(tx *gorm.DB) *gorm.DB) error {
+	if !j.enabled() {
+		return nil
+	}
+
+	ids := []uint{}
+	err := query(tx.Model(entity.model())).
+		Where("id > ?", lastID).
+		Pluck("id", &ids).
+		Error
+	if err != nil {
+		return err
+	}
+
+	return j.Created(tx, entity, ids...)
+}
+
+// LastID id terakhir entity, dipanggil sebelum CreatedAfter
+func (j *taskJournal) LastID(tx *gorm.DB, entity TaskChangeEntity) (uint, error) {
+	var lastID uint
+	if !j.enabled() {
+		return lastID, nil
+	}
+
+	err := tx.
+		Model(entity.model()).
+		Select("coalesce(max(id), 0)").
+		Scan(&lastID).
+		Error
+	return lastID, err
+}
+
+func rowUint(value interface{}) uint {
+	switch v := value.(type) {
+	case int64:
+		return uint(v)
+	case int32:
+		return uint(v)
+	case int:
+		return uint(v)
+	case uint64:
+		return uint(v)
+	case uint32:
+		return uint(v)
+	case uint:
+		return v
+	case float64:
+		return uint(v)
+	}
+	return 0
+}
+
+// restoreData angka bulat dari json dikembalikan ke integer supaya cocok dengan kolom id
+func restoreData(data datatypes.JSONMap) map[string]interface{} {
+	hasil := map[string]interface{}{}
+	for key, value := range data {
+		num, ok := value.(float64)
+		if ok && num == math.Trunc(num) && math.Abs(num) < 1<<53 {
+			hasil[key] = int64(num)
+			continue
+		}
+		hasil[key] = value
+	}
+	return hasil
+}
@@ -0,0 +1,146 @@
+package withdrawal_service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const taskNotifyChannel = "wd_task_items"
+
+// interval cek ulang task walau tidak ada notifikasi, untuk retry dan task yang ditinggal
+var taskPollInterval = time.Second * 15
+
+var taskListenPingInterval = time.Minute * 1
+
+type TaskNotifier interface {
+	Notify(ctx context.Context) error
+	Subscribe() (<-chan struct{}, func())
+	// Listen block sampai ctx selesai, dijalankan runner
+	Listen(ctx context.Context)
+}
+
+type localNotifier struct {
+	mut  sync.Mutex
+	subs map[chan struct{}]bool
+}
+
+// Notify implements TaskNotifier.
+func (l *localNotifier) Notify(ctx context.Context) error {
+	l.broadcast()
+	return nil
+}
+
+// Listen implements TaskNotifier.
+func (l *localNotifier) Listen(ctx context.Context) {}
+
+// Subscribe implements TaskNotifier.
+func (l *localNotifier) Subscribe() (<-chan struct{}, func()) {
+	sub := make(chan struct{}, 1)
+
+	l.mut.Lock()
+	l.subs[sub] = true
+	l.mut.Unlock()
+
+	return sub, func() {
+		l.mut.Lock()
+		delete(l.subs, sub)
+		l.mut.Unlock()
+	}
+}
+
+func (l *localNotifier) broadcast() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for sub := range l.subs {
+		select {
+		case sub <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func NewLocalNotifier() *localNotifier {
+	return &localNotifier{
+		subs: map[chan struct{}]bool{},
+	}
+}
+
+type pgNotifier struct {
+	*localNotifier
+	db        *gorm.DB
+	dialector *postgres.Dialector
+}
+
+// Notify implements TaskNotifier.
+// tidak broadcast langsung, notifikasi sendiri juga diterima listener jadi worker cukup dibangunkan sekali
+func (p *pgNotifier) Notify(ctx context.Context) error {
+	return p.db.WithContext(ctx).Exec("select pg_notify(?, '')", taskNotifyChannel).Error
+}
+
+// Listen implements TaskNotifier.
+// pakai koneksi sendiri di luar pool gorm, reconnect diurus pq.Listener, koneksi ditutup saat ctx selesai
+func (p *pgNotifier) Listen(ctx context.Context) {
+	onEvent := func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error(err.Error(), slog.String("function", "task_listen"))
+		}
+	}
+
+	var listener *pq.Listener
+	switch p.dialector.DriverName {
+	case "cloudsqlpostgres":
+		listener = pq.NewDialListener(cloudsqlDialer{}, p.dialector.DSN, time.Second, time.Minute, onEvent)
+	default:
+		listener = pq.NewListener(p.dialector.DSN, time.Second, time.Minute, onEvent)
+	}
+	defer listener.Close()
+
+	err := listener.Listen(taskNotifyChannel)
+	if err != nil {
+		slog.Warn("listen task tidak aktif, kembali ke polling", slog.String("error", err.Error()))
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+			// nil dikirim setelah reconnect, notifikasi selama putus bisa hilang jadi tetap dibangunkan
+			p.broadcast()
+		case <-time.After(taskListenPingInterval):
+			go func() {
+				err := listener.Ping()
+				if err != nil {
+					slog.Error(err.Error(), slog.String("function", "task_listen_ping"))
+				}
+			}()
+		}
+	}
+}
+
+// NewTaskNotifier pakai listen/notify kalau database postgres, selain itu notifier in process
+func NewTaskNotifier(db *gorm.DB) TaskNotifier {
+	if db.Dialector.Name() != "postgres" {
+		return NewLocalNotifier()
+	}
+
+	dialector, ok := db.Dialector.(*postgres.Dialector)
+	if !ok || dialector.DSN == "" {
+		slog.Warn("listen task tidak aktif, dsn database tidak diketahui, kembali ke polling")
+		return NewLocalNotifier()
+	}
+
+	return &pgNotifier{
+		localNotifier: NewLocalNotifier(),
+		db:            db,
+		dialector:     dialector,
+	}
+}
@@ -0,0 +1,82 @@
+package withdrawal_service
+
+import (
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+)
+
+type PreviewWithdrawal struct {
+	At           int64   `json:"at"`
+	Amount       float64 `json:"amount"`
+	BalanceAfter float64 `json:"balance_after"`
+	IsNew        bool    `json:"is_new"` // false berarti withdrawal sudah ada dan hanya dicocokkan
+}
+
+type PreviewAdjustment struct {
+	OrderRefId string  `json:"order_ref_id"`
+	Type       string  `json:"type"`
+	Amount     float64 `json:"amount"`
+	At         int64   `json:"at"`
+	IsNew      bool    `json:"is_new"` // false berarti adjustment lama diupdate
+}
+
+type PreviewOrderNotFound struct {
+	OrderRefId string  `json:"order_ref_id"`
+	Amount     float64 `json:"amount"`
+	At         int64   `json:"at"`
+}
+
+// TaskPreview hasil dry run, apa saja yang akan diubah kalau file diimport
+type TaskPreview struct {
+	Withdrawals     []*PreviewWithdrawal    `json:"withdrawals"`
+	Adjustments     []*PreviewAdjustment    `json:"adjustments"`
+	CompletedOrders []string                `json:"completed_orders"`
+	OrderNotFound   []*PreviewOrderNotFound `json:"order_not_found"`
+}
+
+func NewTaskPreview() *TaskPreview {
+	return &TaskPreview{
+		Withdrawals:     []*PreviewWithdrawal{},
+		Adjustments:     []*PreviewAdjustment{},
+		CompletedOrders: []string{},
+		OrderNotFound:   []*PreviewOrderNotFound{},
+	}
+}
+
+func (p *TaskPreview) ToIface() *withdrawal_task_iface.TaskPreview {
+	if p == nil {
+		return nil
+	}
+
+	hasil := &withdrawal_task_iface.TaskPreview{
+		Withdrawals:     make([]*withdrawal_task_iface.PreviewWithdrawal, len(p.Withdrawals)),
+		Adjustments:     make([]*withdrawal_task_iface.PreviewAdjustment, len(p.Adjustments)),
+		CompletedOrders: p.CompletedOrders,
+		OrderNotFound:   make([]*withdrawal_task_iface.PreviewOrderNotFound, len(p.OrderNotFound)),
+	}
+	for idx, wd := range p.Withdrawals {
+		hasil.Withdrawals[idx] = &withdrawal_task_iface.PreviewWithdrawal{
+			At:           wd.At,
+			Amount:       wd.Amount,
+			BalanceAfter: wd.BalanceAfter,
+			IsNew:        wd.IsNew,
+		}
+	}
+	for idx, adj := range p.Adjustments {
+		hasil.Adjustments[idx] = &withdrawal_task_iface.PreviewAdjustment{
+			OrderRefId: adj.OrderRefId,
+			Type:       adj.Type,
+			Amount:     adj.Amount,
+			At:         adj.At,
+			IsNew:      adj.IsNew,
+		}
+	}
+	for idx, item := range p.OrderNotFound {
+		hasil.OrderNotFound[idx] = &withdrawal_task_iface.PreviewOrderNotFound{
+			OrderRefId: item.OrderRefId,
+			Amount:     item.Amount,
+			At:         item.At,
+		}
+	}
+
+	return hasil
+}
@@ -0,0 +1,64 @@
+package withdrawal_service
+
+import (
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/withdrawal_service/money"
+)
+
+// TaskSummary hasil import satu task, disimpan saat task selesai
+type TaskSummary struct {
+	WithdrawalCreated int          `json:"withdrawal_created"`
+	OrderFundApplied  int          `json:"order_fund_applied"`
+	AdjustmentLogged  int          `json:"adjustment_logged"`
+	OrderNotFound     int          `json:"order_not_found"`
+	TotalAmount       money.Amount `json:"total_amount"` // total nominal withdrawal yang dibuat
+}
+
+func (s *TaskSummary) ToIface() *withdrawal_task_iface.TaskSummary {
+	if s == nil {
+		return nil
+	}
+
+	return &withdrawal_task_iface.TaskSummary{
+		WithdrawalCreated: int32(s.WithdrawalCreated),
+		OrderFundApplied:  int32(s.OrderFundApplied),
+		AdjustmentLogged:  int32(s.AdjustmentLogged),
+		OrderNotFound:     int32(s.OrderNotFound),
+		TotalAmount:       s.TotalAmount.Float64(),
+	}
+}
+
+// progress disimpan tiap sekian baris atau sekian detik, tidak tiap baris
+var (
+	progressSaveRows     = 200
+	progressSaveInterval = time.Second * 5
+)
+
+type progressSaver struct {
+	saveFunc func() error
+	rows     int
+	lastSave time.Time
+}
+
+func newProgressSaver(saveFunc func() error) *progressSaver {
+	return &progressSaver{
+		saveFunc: saveFunc,
+	}
+}
+
+func (p *progressSaver) tick() error {
+	p.rows += 1
+	if p.rows < progressSaveRows && time.Since(p.lastSave) < progressSaveInterval {
+		return nil
+	}
+
+	return p.save()
+}
+
+func (p *progressSaver) save() error {
+	p.rows = 0
+	p.lastSave = time.Now()
+	return p.saveFunc()
+}
@@ -0,0 +1,34 @@
+package withdrawal_service
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+)
+
+var terminalTaskStatus = []withdrawal_iface.TaskStatus{
+	withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+	withdrawal_iface.TaskStatus_TASK_STATUS_ERROR,
+	withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED,
+	withdrawal_iface.TaskStatus_TASK_STATUS_REVERTED,
+}
+
+type TaskRetentionConfig struct {
+	// task selesai lebih lama dari ini dihapus, 0 berarti disimpan selamanya
+	Retention time.Duration
+}
+
+func NewTaskRetentionConfig() *TaskRetentionConfig {
+	cfg := TaskRetentionConfig{
+		Retention: time.Hour * 24 * 90,
+	}
+
+	days, err := strconv.Atoi(os.Getenv("WD_TASK_RETENTION_DAYS"))
+	if err == nil && days >= 0 {
+		cfg.Retention = time.Hour * 24 * time.Duration(days)
+	}
+
+	return &cfg
+}
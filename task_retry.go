@@ -0,0 +1,135 @@
+package withdrawal_service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdcgo/withdrawal_service/wd_error"
+)
+
+type TaskRetryConfig struct {
+	MaxAttempt  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewTaskRetryConfig() *TaskRetryConfig {
+	cfg := TaskRetryConfig{
+		MaxAttempt:  5,
+		BaseBackoff: time.Second * 30,
+		MaxBackoff:  time.Minute * 30,
+	}
+
+	maxAttempt, err := strconv.Atoi(os.Getenv("WD_TASK_MAX_ATTEMPT"))
+	if err == nil && maxAttempt > 0 {
+		cfg.MaxAttempt = maxAttempt
+	}
+
+	return &cfg
+}
+
+// Backoff jeda sebelum attempt berikutnya, dobel tiap attempt sampai MaxBackoff
+func (c *TaskRetryConfig) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	backoff := c.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+
+	return backoff
+}
+
+type retryableErr struct {
+	err error
+}
+
+func (r *retryableErr) Error() string {
+	return r.err.Error()
+}
+
+func (r *retryableErr) Unwrap() error {
+	return r.err
+}
+
+// NewRetryableErr menandai error sementara (storage, koneksi) supaya task dicoba ulang
+func NewRetryableErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableErr{err: err}
+}
+
+// sqlstate postgres yang aman untuk dicoba ulang
+var retryableSqlState = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available, dari NOWAIT
+	"57P01": true, // admin_shutdown
+	"57P03": true, // cannot_connect_now
+	"53300": true, // too_many_connections
+}
+
+type sqlStateErr interface {
+	SQLState() string
+}
+
+// taskError error terstruktur untuk disimpan di task, error tanpa code dikategorikan dari IsRetryableErr
+func taskError(err error) *wd_error.Error {
+	coded := wd_error.From(err)
+	if coded.Code == wd_error.CodeUnknown && IsRetryableErr(err) {
+		return wd_error.Wrap(wd_error.CodeTemporary, err, nil)
+	}
+	return coded
+}
+
+func IsRetryableErr(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var retryErr *retryableErr
+	if errors.As(err, &retryErr) {
+		return true
+	}
+
+	// error dari catalog sudah punya kategori sendiri
+	var coded *wd_error.Error
+	if errors.As(err, &coded) {
+		return coded.Category == wd_error.CategoryRetryable
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var stateErr sqlStateErr
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		if strings.HasPrefix(state, "08") { // connection exception
+			return true
+		}
+		return retryableSqlState[state]
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return false
+}
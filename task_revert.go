@@ -0,0 +1,304 @@
+package withdrawal_service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/shared/authorization"
+	"github.com/pdcgo/shared/interfaces/identity_iface"
+	"github.com/pdcgo/withdrawal_service/marketplace_query"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTaskNotRevertable = wd_error.New(wd_error.CodeTaskNotRevertable, "task masih berjalan atau sudah direvert", nil)
+var ErrTaskRevertConflict = wd_error.New(wd_error.CodeTaskRevertConflict, "data task sudah diubah task lain", nil)
+
+var revertableTaskStatus = []withdrawal_iface.TaskStatus{
+	withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+	withdrawal_iface.TaskStatus_TASK_STATUS_ERROR,
+	withdrawal_iface.TaskStatus_TASK_STATUS_CANCELLED,
+}
+
+// revertTask kembalikan semua perubahan task dari yang terakhir, dalam satu transaksi
+func revertTask(db *gorm.DB, teamID uint64, taskID uint, userID uint) (*TaskItem, int, error) {
+	var reverted int
+	task := TaskItem{
+		TaskItem: &withdrawal_iface.TaskItem{},
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Model(&TaskItem{}).
+			Where("id = ?", taskID).
+			Where("team_id = ?", teamID).
+			Find(&task).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if task.ID == 0 {
+			return ErrTaskNotFound
+		}
+
+		revertable := false
+		for _, status := range revertableTaskStatus {
+			if task.Status == status {
+				revertable = true
+			}
+		}
+		if !revertable {
+			return ErrTaskNotRevertable
+		}
+
+		reverted, err = undoTaskChanges(tx, &task, userID)
+		if err != nil {
+			return err
+		}
+
+		task.Status = withdrawal_iface.TaskStatus_TASK_STATUS_REVERTED
+		task.RevertedBy = userID
+		task.RevertedAt = time.Now().Unix()
+
+		return tx.Model(&TaskItem{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"status":      task.Status,
+			"reverted_by": task.RevertedBy,
+			"reverted_at": task.RevertedAt,
+		}).Error
+	})
+
+	return &task, reverted, err
+}
+
+// undoTaskChanges kembalikan perubahan task dari catatan terakhir, dipakai revert dan task yang gagal
+func undoTaskChanges(tx *gorm.DB, task *TaskItem, userID uint) (int, error) {
+	// task setelahnya yang mengubah row yang sama harus direvert dulu, kalau tidak nilai nya tertimpa
+	laterID, err := laterTaskChange(tx, task.ID)
+	if err != nil {
+		return 0, err
+	}
+	if laterID != 0 {
+		return 0, wd_error.New(
+			wd_error.CodeTaskRevertConflict,
+			fmt.Sprintf("data task sudah diubah task %d, revert task %d dulu", laterID, laterID),
+			wd_error.Params{
+				"task_id":       strconv.FormatUint(uint64(task.ID), 10),
+				"later_task_id": strconv.FormatUint(uint64(laterID), 10),
+			},
+		)
+	}
+
+	changes := []*TaskChange{}
+	err = tx.
+		Model(&TaskChange{}).
+		Where("task_id = ?", task.ID).
+		Order("id desc").
+		Find(&changes).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	agent := NewV2ImporterAgent(&authorization.JwtIdentity{
+		UserID:    userID,
+		From:      "withdrawal_service",
+		UserAgent: identity_iface.ImporterAgent,
+	})
+	mpquery := marketplace_query.
+		NewMarketplaceQuery(tx, agent).
+		ByID(uint(task.TeamId), uint(task.MpId))
+
+	for _, change := range changes {
+		err = revertChange(tx, mpquery, change)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Where("task_id = ?", task.ID).Delete(&TaskChange{}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Where("task_id = ?", task.ID).Delete(&WithdrawalReconciliation{}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// file yang sama boleh diimport lagi setelah revert
+	err = tx.
+		Where("scope = ?", FingerprintV1).
+		Where("task_id = ?", task.ID).
+		Delete(&FileFingerprint{}).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	return len(changes), nil
+}
+
+// rollbackTaskSets batalkan set withdrawal yang sudah commit dari task yang gagal atau dibatalkan.
+// kalau gagal perubahan di tx dikembalikan ke savepoint, status task tetap bisa disimpan
+func rollbackTaskSets(tx *gorm.DB, task *TaskItem) error {
+	if task.ID == 0 || task.DryRun {
+		return nil
+	}
+
+	var userID uint
+	identity := task.AgentData.Data()
+	if identity != nil {
+		userID = identity.UserID
+	}
+
+	err := tx.SavePoint("rollback_task_sets").Error
+	if err != nil {
+		return err
+	}
+
+	_, err = undoTaskChanges(tx, task, userID)
+	if err != nil {
+		tx.RollbackTo("rollback_task_sets")
+		return err
+	}
+	return nil
+}
+
+func laterTaskChange(tx *gorm.DB, taskID uint) (uint, error) {
+	var laterID uint
+	err := tx.
+		Table("task_changes AS later").
+		Select("later.task_id").
+		Joins("JOIN task_changes AS own ON own.entity = later.entity AND own.entity_id = later.entity_id").
+		Where("own.task_id = ?", taskID).
+		Where("own.entity_id != 0").
+		Where("later.task_id != ?", taskID).
+		Where("later.id > own.id").
+		Order("later.task_id desc").
+		Limit(1).
+		Scan(&laterID).
+		Error
+	if err != nil || laterID != 0 {
+		return laterID, err
+	}
+
+	// row tanpa id dicocokkan lewat key unique nya
+	own := []*TaskChange{}
+	err = tx.
+		Model(&TaskChange{}).
+		Where("task_id = ?", taskID).
+		Where("entity_id = 0").
+		Order("id asc").
+		Find(&own).
+		Error
+	if err != nil || len(own) == 0 {
+		return laterID, err
+	}
+
+	ownKeys := map[string]uint{}
+	entities := []TaskChangeEntity{}
+	for _, change := range own {
+		key, ok := change.Entity.keyOf(restoreData(change.Data))
+		if !ok {
+			continue
+		}
+		name := changeKeyName(change.Entity, key)
+		if _, ok := ownKeys[name]; !ok {
+			ownKeys[name] = change.ID
+		}
+		entities = append(entities, change.Entity)
+	}
+	if len(ownKeys) == 0 {
+		return laterID, nil
+	}
+
+	later := []*TaskChange{}
+	err = tx.
+		Model(&TaskChange{}).
+		Where("task_id != ?", taskID).
+		Where("entity_id = 0").
+		Where("entity IN ?", entities).
+		Where("id > ?", own[0].ID).
+		Order("task_id desc").
+		Find(&later).
+		Error
+	if err != nil {
+		return laterID, err
+	}
+
+	for _, change := range later {
+		key, ok := change.Entity.keyOf(restoreData(change.Data))
+		if !ok {
+			continue
+		}
+		ownID, ok := ownKeys[changeKeyName(change.Entity, key)]
+		if ok && change.ID > ownID {
+			return change.TaskID, nil
+		}
+	}
+
+	return laterID, nil
+}
+
+func changeKeyName(entity TaskChangeEntity, key map[string]interface{}) string {
+	name := string(entity)
+	for _, column := range entity.rowKey() {
+		name += fmt.Sprintf(":%d", rowUint(key[column]))
+	}
+	return name
+}
+
+func revertChange(tx *gorm.DB, mpquery marketplace_query.ItemQuery, change *TaskChange) error {
+	model := change.Entity.model()
+
+	switch change.Action {
+	case ChangeCreate:
+		// withdrawal dihapus beserta asset history nya
+		if change.Entity == ChangeWithdrawal {
+			err := mpquery.DeleteWithdrawal(change.EntityID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		if change.EntityID != 0 {
+			return tx.Unscoped().Where("id = ?", change.EntityID).Delete(model).Error
+		}
+
+		// row tanpa id hanya dihapus lewat key unique nya, supaya row sama dari task lain tidak ikut terhapus
+		key, ok := change.Entity.keyOf(restoreData(change.Data))
+		if !ok {
+			return wd_error.New(
+				wd_error.CodeTaskChangeNoKey,
+				fmt.Sprintf("perubahan %d create %s tanpa id tidak bisa direvert", change.ID, change.Entity),
+				wd_error.Params{
+					"change_id": strconv.FormatUint(uint64(change.ID), 10),
+					"entity":    string(change.Entity),
+				},
+			)
+		}
+		return tx.Unscoped().Where(key).Delete(model).Error
+
+	case ChangeUpdate:
+		return tx.
+			Model(model).
+			Where("id = ?", change.EntityID).
+			Updates(restoreData(change.Data)).
+			Error
+
+	case ChangeDelete:
+		return tx.
+			Model(model).
+			Create(restoreData(change.Data)).
+			Error
+	}
+
+	return nil
+}
@@ -0,0 +1,303 @@
+package withdrawal_service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/pdcgo/withdrawal_service"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type orderTotal struct {
+	WdTotal    float64
+	Adjustment float64
+}
+
+func TestTaskRevert(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "revert task",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(
+					&withdrawal_service.TaskItem{},
+					&withdrawal_service.TaskChange{},
+					&withdrawal_service.FileFingerprint{},
+					&db_models.WdOrderNotFound{},
+					&db_models.OrderAdjustment{},
+					&db_models.Order{},
+					&db_models.WdValid{},
+				)
+				assert.Nil(t, err)
+
+				tasks := []*withdrawal_service.TaskItem{
+					{
+						ID: 1,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+					{
+						ID: 2,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+						},
+					},
+					{
+						ID: 3,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+					{
+						ID: 4,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+					{
+						ID: 5,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+					{
+						ID: 6,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+					{
+						ID: 7,
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId: 1,
+							MpId:   1,
+							Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+						},
+					},
+				}
+				err = db.Save(&tasks).Error
+				assert.Nil(t, err)
+
+				// hasil import task 1
+				err = db.Save(&db_models.WdOrderNotFound{
+					ID:         1,
+					OrderRefID: "ORD001",
+					WdID:       1,
+					Amount:     1000,
+					At:         time.Now(),
+				}).Error
+				assert.Nil(t, err)
+
+				err = db.Save(&db_models.OrderAdjustment{
+					ID:      1,
+					OrderID: 1,
+					Amount:  7000,
+				}).Error
+				assert.Nil(t, err)
+
+				orders := []*db_models.Order{
+					{ID: 1, TeamID: 1, OrderRefID: "ORD001", OrderMpID: 1},
+					{ID: 2, TeamID: 1, OrderRefID: "ORD002", OrderMpID: 1},
+				}
+				err = db.Create(&orders).Error
+				assert.Nil(t, err)
+
+				// total order setelah dihitung ulang oleh import task 1
+				err = db.Model(&db_models.Order{}).Where("id = ?", 1).Updates(map[string]interface{}{
+					"wd_total":   12000,
+					"adjustment": -500,
+				}).Error
+				assert.Nil(t, err)
+
+				// wd valid 1-2 dibuat di luar task 1
+				valids := []*db_models.WdValid{
+					{WithdrawalID: 1, OrderAdjustmentID: 1},
+					{WithdrawalID: 1, OrderAdjustmentID: 2},
+				}
+				err = db.Create(&valids).Error
+				assert.Nil(t, err)
+
+				err = db.Save(&withdrawal_service.FileFingerprint{
+					Scope:       withdrawal_service.FingerprintV1,
+					TeamID:      1,
+					MpID:        1,
+					ContentHash: "hash",
+					TaskID:      1,
+				}).Error
+				assert.Nil(t, err)
+
+				err = db.Save(&[]*withdrawal_service.TaskChange{
+					{
+						TaskID:   1,
+						Entity:   withdrawal_service.ChangeOrderAdjustment,
+						Action:   withdrawal_service.ChangeUpdate,
+						EntityID: 1,
+						Data:     datatypes.JSONMap{"amount": 5000},
+					},
+					{
+						TaskID:   1,
+						Entity:   withdrawal_service.ChangeWdOrderNotFound,
+						Action:   withdrawal_service.ChangeCreate,
+						EntityID: 1,
+					},
+					{
+						TaskID:   1,
+						Entity:   withdrawal_service.ChangeOrder,
+						Action:   withdrawal_service.ChangeUpdate,
+						EntityID: 1,
+						Data:     datatypes.JSONMap{"wd_total": 5000, "adjustment": 0},
+					},
+					{
+						TaskID: 1,
+						Entity: withdrawal_service.ChangeWdValid,
+						Action: withdrawal_service.ChangeCreate,
+						Data:   datatypes.JSONMap{"withdrawal_id": 1, "order_adjustment_id": 1},
+					},
+					// create tanpa key lengkap
+					{
+						TaskID: 5,
+						Entity: withdrawal_service.ChangeWdValid,
+						Action: withdrawal_service.ChangeCreate,
+						Data:   datatypes.JSONMap{"withdrawal_id": 1},
+					},
+					// task 6 dan 7 sama sama mengubah wd valid 2-1
+					{
+						TaskID: 6,
+						Entity: withdrawal_service.ChangeWdValid,
+						Action: withdrawal_service.ChangeCreate,
+						Data:   datatypes.JSONMap{"withdrawal_id": 2, "order_adjustment_id": 1},
+					},
+					{
+						TaskID: 7,
+						Entity: withdrawal_service.ChangeWdValid,
+						Action: withdrawal_service.ChangeDelete,
+						Data:   datatypes.JSONMap{"withdrawal_id": 2, "order_adjustment_id": 1},
+					},
+					// task 3 dan 4 sama sama mengubah order 2
+					{
+						TaskID:   3,
+						Entity:   withdrawal_service.ChangeOrder,
+						Action:   withdrawal_service.ChangeUpdate,
+						EntityID: 2,
+						Data:     datatypes.JSONMap{"order_mp_id": 2},
+					},
+					{
+						TaskID:   4,
+						Entity:   withdrawal_service.ChangeOrder,
+						Action:   withdrawal_service.ChangeUpdate,
+						EntityID: 2,
+						Data:     datatypes.JSONMap{"wd_total": 0},
+					},
+				}).Error
+				assert.Nil(t, err)
+
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			st := withdrawal_service.NewTempStore(&db)
+
+			t.Run("task team lain tidak ditemukan", func(t *testing.T) {
+				_, _, err := st.Revert(2, 1, 10)
+				assert.ErrorIs(t, err, withdrawal_service.ErrTaskNotFound)
+			})
+
+			t.Run("task yang masih jalan tidak bisa direvert", func(t *testing.T) {
+				_, _, err := st.Revert(1, 2, 10)
+				assert.ErrorIs(t, err, withdrawal_service.ErrTaskNotRevertable)
+			})
+
+			t.Run("revert task selesai", func(t *testing.T) {
+				task, reverted, err := st.Revert(1, 1, 10)
+				assert.Nil(t, err)
+				assert.Equal(t, 4, reverted)
+				assert.Equal(t, withdrawal_iface.TaskStatus_TASK_STATUS_REVERTED, task.Status)
+				assert.Equal(t, uint(10), task.RevertedBy)
+
+				var count int64
+				err = db.Model(&db_models.WdOrderNotFound{}).Where("id = ?", 1).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+
+				adj := db_models.OrderAdjustment{}
+				err = db.Model(&db_models.OrderAdjustment{}).First(&adj, 1).Error
+				assert.Nil(t, err)
+				assert.Equal(t, float64(5000), adj.Amount)
+
+				total := orderTotal{}
+				err = db.Model(&db_models.Order{}).Select("wd_total, adjustment").Where("id = ?", 1).Scan(&total).Error
+				assert.Nil(t, err)
+				assert.Equal(t, float64(5000), total.WdTotal)
+				assert.Equal(t, float64(0), total.Adjustment)
+
+				// hanya wd valid yang dibuat task 1 yang dihapus
+				adjIDs := []uint{}
+				err = db.Model(&db_models.WdValid{}).Where("withdrawal_id = ?", 1).Pluck("order_adjustment_id", &adjIDs).Error
+				assert.Nil(t, err)
+				assert.Equal(t, []uint{2}, adjIDs)
+
+				err = db.Model(&withdrawal_service.FileFingerprint{}).Where("task_id = ?", 1).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+
+				err = db.Model(&withdrawal_service.TaskChange{}).Where("task_id = ?", 1).Count(&count).Error
+				assert.Nil(t, err)
+				assert.Equal(t, int64(0), count)
+			})
+
+			t.Run("revert dua kali ditolak", func(t *testing.T) {
+				_, _, err := st.Revert(1, 1, 10)
+				assert.ErrorIs(t, err, withdrawal_service.ErrTaskNotRevertable)
+			})
+
+			t.Run("row diubah task setelahnya harus revert task terbaru dulu", func(t *testing.T) {
+				_, _, err := st.Revert(1, 3, 10)
+				assert.ErrorIs(t, err, withdrawal_service.ErrTaskRevertConflict)
+
+				_, _, err = st.Revert(1, 4, 10)
+				assert.Nil(t, err)
+
+				_, reverted, err := st.Revert(1, 3, 10)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, reverted)
+
+				ord := db_models.Order{}
+				err = db.Model(&db_models.Order{}).First(&ord, 2).Error
+				assert.Nil(t, err)
+				assert.Equal(t, uint(2), ord.OrderMpID)
+			})
+
+			t.Run("create tanpa key tidak bisa direvert", func(t *testing.T) {
+				_, _, err := st.Revert(1, 5, 10)
+				assert.Equal(t, wd_error.CodeTaskChangeNoKey, wd_error.From(err).Code)
+			})
+
+			t.Run("row tanpa id diubah task setelahnya", func(t *testing.T) {
+				_, _, err := st.Revert(1, 6, 10)
+				assert.ErrorIs(t, err, withdrawal_service.ErrTaskRevertConflict)
+				assert.Equal(t, "7", wd_error.From(err).Params["later_task_id"])
+			})
+		},
+	)
+}
@@ -0,0 +1,608 @@
+package withdrawal_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/shared/authorization"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/interfaces/authorization_iface"
+	"github.com/pdcgo/shared/pkg/streampipe"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTaskListLimit = 20
+	maxTaskListLimit     = 100
+)
+
+type taskServiceImpl struct {
+	auth    authorization_iface.Authorization
+	store   TaskStore
+	pub     streampipe.PublishProvider
+	storage FileStorage
+}
+
+// GetTaskList implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) GetTaskList(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.GetTaskListRequest],
+) (*connect.Response[withdrawal_task_iface.GetTaskListResponse], error) {
+	var err error
+	result := withdrawal_task_iface.GetTaskListResponse{
+		Items: []*withdrawal_task_iface.TaskItem{},
+	}
+
+	pay := req.Msg
+
+	err = t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Read},
+			},
+		}).
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	if pay.Page < 1 {
+		pay.Page = 1
+	}
+	if pay.Limit < 1 || pay.Limit > maxTaskListLimit {
+		pay.Limit = defaultTaskListLimit
+	}
+	result.Page = pay.Page
+	result.Limit = pay.Limit
+
+	query := t.store.
+		GetTx().
+		WithContext(ctx).
+		Model(&TaskItem{}).
+		Where("team_id = ?", pay.TeamId)
+
+	if pay.Status != withdrawal_iface.TaskStatus_TASK_STATUS_UNSPECIFIED {
+		query = query.Where("status = ?", pay.Status)
+	}
+	if pay.CreatedFrom != 0 {
+		query = query.Where("created_at >= ?", pay.CreatedFrom)
+	}
+	if pay.CreatedTo != 0 {
+		query = query.Where("created_at <= ?", pay.CreatedTo)
+	}
+
+	query = query.Session(&gorm.Session{})
+	err = query.
+		Count(&result.Total).
+		Error
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	tasks := []*TaskItem{}
+	err = query.
+		Order("id desc").
+		Offset(int((pay.Page - 1) * pay.Limit)).
+		Limit(int(pay.Limit)).
+		Find(&tasks).
+		Error
+
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	for _, task := range tasks {
+		result.Items = append(result.Items, task.ToTaskIface())
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+// GetTaskPreview implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) GetTaskPreview(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.GetTaskPreviewRequest],
+) (*connect.Response[withdrawal_task_iface.GetTaskPreviewResponse], error) {
+	var err error
+	var result withdrawal_task_iface.GetTaskPreviewResponse
+
+	pay := req.Msg
+
+	err = t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Read},
+			},
+		}).
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	task := TaskItem{
+		TaskItem: &withdrawal_iface.TaskItem{},
+	}
+	err = t.store.
+		GetTx().
+		WithContext(ctx).
+		Model(&TaskItem{}).
+		Where("id = ?", pay.TaskId).
+		Where("team_id = ?", pay.TeamId).
+		Find(&task).
+		Error
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	if task.ID == 0 {
+		return connect.NewResponse(&result), ErrTaskNotFound
+	}
+
+	result = withdrawal_task_iface.GetTaskPreviewResponse{
+		TaskId:  uint64(task.ID),
+		Status:  task.Status,
+		Summary: task.Summary.Data().ToIface(),
+		Preview: task.Preview.Data().ToIface(),
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+// GetReconciliation implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) GetReconciliation(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.GetReconciliationRequest],
+) (*connect.Response[withdrawal_task_iface.GetReconciliationResponse], error) {
+	var err error
+	var result withdrawal_task_iface.GetReconciliationResponse
+
+	pay := req.Msg
+
+	err = t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Read},
+			},
+		}).
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	source := ReconciliationSource(pay.Source)
+	if source == "" {
+		source = ReconciliationV1
+	}
+
+	rec, err := GetReconciliation(
+		t.store.GetTx().WithContext(ctx),
+		source,
+		uint(pay.TeamId),
+		uint(pay.WithdrawalId),
+	)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	result.Reconciliation = rec.ToIface()
+	return connect.NewResponse(&result), nil
+}
+
+// SetColumnMapping implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) SetColumnMapping(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.SetColumnMappingRequest],
+) (*connect.Response[withdrawal_task_iface.SetColumnMappingResponse], error) {
+	var err error
+	var result withdrawal_task_iface.SetColumnMappingResponse
+
+	pay := req.Msg
+
+	identity := t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Update},
+			},
+		})
+
+	agent := identity.Identity()
+
+	err = identity.
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	if pay.Mapping == nil {
+		return connect.NewResponse(&result), wd_error.New(wd_error.CodeMappingInvalid, "mapping kolom kosong", nil)
+	}
+
+	config, err := SaveColumnMapping(
+		t.store.GetTx().WithContext(ctx),
+		uint(pay.TeamId),
+		uint(pay.MpId),
+		ColumnMappingFromSpec(pay.Mapping),
+		agent.IdentityID(),
+	)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	result.ColumnMapping = config.ToIface()
+	return connect.NewResponse(&result), nil
+}
+
+// GetColumnMapping implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) GetColumnMapping(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.GetColumnMappingRequest],
+) (*connect.Response[withdrawal_task_iface.GetColumnMappingResponse], error) {
+	var err error
+	var result withdrawal_task_iface.GetColumnMappingResponse
+
+	pay := req.Msg
+
+	err = t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Read},
+			},
+		}).
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	config, err := FindColumnMapping(
+		t.store.GetTx().WithContext(ctx),
+		uint(pay.TeamId),
+		uint(pay.MpId),
+	)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	if config == nil {
+		return connect.NewResponse(&result), ErrColumnMappingNotFound
+	}
+
+	result.ColumnMapping = config.ToIface()
+	return connect.NewResponse(&result), nil
+}
+
+// ImportOrderCancel implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) ImportOrderCancel(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.ImportOrderCancelRequest],
+) (*connect.Response[withdrawal_task_iface.ImportOrderCancelResponse], error) {
+	var err error
+	var result withdrawal_task_iface.ImportOrderCancelResponse
+
+	pay := req.Msg
+
+	identity := t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Update},
+			},
+		})
+
+	agent := identity.Identity()
+
+	err = identity.
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	data, err := t.storage.GetContent(ctx, pay.ResourceUri)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	importer, err := NewOrderCancelImporter(pay.MpType, data)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	result.Summary, err = ImportOrderCancel(
+		ctx,
+		t.store.GetTx().WithContext(ctx),
+		NewV2ImporterAgent(&authorization.JwtIdentity{
+			UserID: agent.IdentityID(),
+			From:   "withdrawal_service",
+		}),
+		t.pub,
+		uint(pay.TeamId),
+		uint(pay.MpId),
+		importer,
+	)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+// ImportShipped implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) ImportShipped(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.ImportShippedRequest],
+	stream *connect.ServerStream[withdrawal_task_iface.ImportShippedResponse],
+) error {
+	var err error
+
+	pay := req.Msg
+
+	identity := t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Update},
+			},
+		})
+
+	agent := identity.Identity()
+
+	err = identity.
+		Err()
+	if err != nil {
+		return err
+	}
+
+	data, err := t.storage.GetContent(ctx, pay.ResourceUri)
+	if err != nil {
+		return err
+	}
+
+	importer, err := NewShippedImporter(pay.MpType, data)
+	if err != nil {
+		return err
+	}
+
+	shippedAt := time.Now()
+	if pay.ShippedAt != 0 {
+		shippedAt = time.Unix(pay.ShippedAt, 0)
+	}
+
+	summary, err := ImportShipped(
+		ctx,
+		t.store.GetTx().WithContext(ctx),
+		NewV2ImporterAgent(&authorization.JwtIdentity{
+			UserID: agent.IdentityID(),
+			From:   "withdrawal_service",
+		}),
+		t.pub,
+		uint(pay.TeamId),
+		uint(pay.MpId),
+		shippedAt,
+		importer,
+		func(refID string) error {
+			return stream.Send(&withdrawal_task_iface.ImportShippedResponse{
+				Message:       fmt.Sprintf("order %s tidak ditemukan", refID),
+				NotFoundRefId: refID,
+			})
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return stream.Send(&withdrawal_task_iface.ImportShippedResponse{
+		Message: "import pengiriman selesai",
+		Summary: summary,
+	})
+}
+
+// ListOrderNotFound implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) ListOrderNotFound(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.ListOrderNotFoundRequest],
+) (*connect.Response[withdrawal_task_iface.ListOrderNotFoundResponse], error) {
+	var err error
+	result := withdrawal_task_iface.ListOrderNotFoundResponse{
+		Items: []*withdrawal_task_iface.OrderNotFound{},
+	}
+
+	pay := req.Msg
+
+	err = t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Read},
+			},
+		}).
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	if pay.Page < 1 {
+		pay.Page = 1
+	}
+	if pay.Limit < 1 || pay.Limit > maxTaskListLimit {
+		pay.Limit = defaultTaskListLimit
+	}
+	result.Page = pay.Page
+	result.Limit = pay.Limit
+
+	result.Items, result.Total, err = ListOrderNotFound(
+		t.store.GetTx().WithContext(ctx),
+		uint(pay.TeamId),
+		uint(pay.MpId),
+		uint(pay.WdId),
+		int(pay.Page),
+		int(pay.Limit),
+	)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+// DetectWithdrawalFile implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) DetectWithdrawalFile(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.DetectWithdrawalFileRequest],
+) (*connect.Response[withdrawal_task_iface.DetectWithdrawalFileResponse], error) {
+	var err error
+	var result withdrawal_task_iface.DetectWithdrawalFileResponse
+
+	pay := req.Msg
+
+	err = t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Read},
+			},
+		}).
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	data, err := t.storage.GetContent(ctx, pay.ResourceUri)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	result.File, err = DetectWdFile(data)
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+// CancelTask implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) CancelTask(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.CancelTaskRequest],
+) (*connect.Response[withdrawal_task_iface.CancelTaskResponse], error) {
+	var err error
+	var result withdrawal_task_iface.CancelTaskResponse
+
+	pay := req.Msg
+
+	identity := t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Update},
+			},
+		})
+
+	agent := identity.Identity()
+
+	err = identity.
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	task, err := t.store.Cancel(pay.TeamId, uint(pay.TaskId), agent.IdentityID())
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	result = withdrawal_task_iface.CancelTaskResponse{
+		TaskId:      uint64(task.ID),
+		Status:      task.Status,
+		CancelledBy: uint64(task.CancelledBy),
+		CancelledAt: task.CancelledAt,
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+// RevertTask implements withdrawal_task_ifaceconnect.TaskServiceHandler.
+func (t *taskServiceImpl) RevertTask(
+	ctx context.Context,
+	req *connect.Request[withdrawal_task_iface.RevertTaskRequest],
+) (*connect.Response[withdrawal_task_iface.RevertTaskResponse], error) {
+	var err error
+	var result withdrawal_task_iface.RevertTaskResponse
+
+	pay := req.Msg
+
+	identity := t.
+		auth.
+		AuthIdentityFromHeader(req.Header()).
+		HasPermission(authorization_iface.CheckPermissionGroup{
+			&db_models.Order{}: &authorization_iface.CheckPermission{
+				DomainID: uint(pay.TeamId),
+				Actions:  []authorization_iface.Action{authorization_iface.Update},
+			},
+		})
+
+	agent := identity.Identity()
+
+	err = identity.
+		Err()
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	task, reverted, err := t.store.Revert(pay.TeamId, uint(pay.TaskId), agent.IdentityID())
+	if err != nil {
+		return connect.NewResponse(&result), err
+	}
+
+	result = withdrawal_task_iface.RevertTaskResponse{
+		TaskId:     uint64(task.ID),
+		Status:     task.Status,
+		RevertedBy: uint64(task.RevertedBy),
+		RevertedAt: task.RevertedAt,
+		Reverted:   int32(reverted),
+	}
+
+	return connect.NewResponse(&result), nil
+}
+
+func NewTaskService(
+	auth authorization_iface.Authorization,
+	store TaskStore,
+	pub streampipe.PublishProvider,
+	storage FileStorage,
+) *taskServiceImpl {
+	return &taskServiceImpl{
+		auth:    auth,
+		store:   store,
+		pub:     pub,
+		storage: storage,
+	}
+}
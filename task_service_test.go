@@ -0,0 +1,248 @@
+package withdrawal_service_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1/withdrawal_task_ifaceconnect"
+	"github.com/pdcgo/shared/authorization/authorization_mock"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/pdcgo/withdrawal_service"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTaskService(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "test task service",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+			func(t *testing.T) func() error {
+				err := db.AutoMigrate(&withdrawal_service.TaskItem{})
+				assert.Nil(t, err)
+
+				task := withdrawal_service.TaskItem{
+					ID: 1,
+					TaskItem: &withdrawal_iface.TaskItem{
+						TeamId:      1,
+						Status:      withdrawal_iface.TaskStatus_TASK_STATUS_PROCESS,
+						ResourceUri: "withdrawal_resources/test.xlsx",
+					},
+				}
+
+				err = db.Save(&task).Error
+				assert.Nil(t, err)
+				return nil
+			},
+		},
+		func(t *testing.T) {
+			store := withdrawal_service.NewTempStore(&db)
+
+			auth := authorization_mock.EmptyAuthorizationMock{
+				AuthIdentityMock: &authorization_mock.AuthIdentityMock{
+					IdentityMock: &authorization_mock.IdentityMock{ID: 1},
+				},
+			}
+
+			_, handler := withdrawal_task_ifaceconnect.NewTaskServiceHandler(withdrawal_service.NewTaskService(&auth, store, nil, nil))
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			client := withdrawal_task_ifaceconnect.NewTaskServiceClient(ts.Client(), ts.URL)
+
+			err := store.SetProgress(1, 100, 40)
+			assert.Nil(t, err)
+
+			err = store.SetSummary(1, &withdrawal_service.TaskSummary{
+				WithdrawalCreated: 2,
+				OrderFundApplied:  30,
+				AdjustmentLogged:  5,
+				OrderNotFound:     3,
+				TotalAmount:       money.FromFloat(150000),
+			})
+			assert.Nil(t, err)
+
+			res, err := client.GetTaskList(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetTaskListRequest{
+				TeamId: 1,
+			}))
+			assert.Nil(t, err)
+			assert.Len(t, res.Msg.Items, 1)
+
+			item := res.Msg.Items[0]
+			assert.Equal(t, uint64(1), item.Id)
+			assert.Equal(t, "withdrawal_resources/test.xlsx", item.Task.ResourceUri)
+			assert.Equal(t, int32(100), item.RowsParsed)
+			assert.Equal(t, int32(40), item.RowsProcessed)
+			assert.Equal(t, int32(30), item.Summary.OrderFundApplied)
+			assert.Equal(t, 150000.0, item.Summary.TotalAmount)
+
+			t.Run("history dengan filter tanggal dan pagination", func(t *testing.T) {
+				for i := 2; i <= 6; i++ {
+					err := db.Save(&withdrawal_service.TaskItem{
+						ID: uint(i),
+						TaskItem: &withdrawal_iface.TaskItem{
+							TeamId:    1,
+							Status:    withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+							CreatedAt: int64(i * 1000),
+						},
+					}).Error
+					assert.Nil(t, err)
+				}
+
+				res, err := client.GetTaskList(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetTaskListRequest{
+					TeamId:      1,
+					CreatedFrom: 2000,
+					CreatedTo:   5000,
+					Page:        2,
+					Limit:       3,
+				}))
+				assert.Nil(t, err)
+				assert.Equal(t, int64(4), res.Msg.Total)
+				assert.Len(t, res.Msg.Items, 1)
+				assert.Equal(t, uint64(2), res.Msg.Items[0].Id)
+			})
+
+			t.Run("preview hasil dry run", func(t *testing.T) {
+				err := db.Save(&withdrawal_service.TaskItem{
+					ID:     7,
+					DryRun: true,
+					TaskItem: &withdrawal_iface.TaskItem{
+						TeamId: 1,
+						Status: withdrawal_iface.TaskStatus_TASK_STATUS_FINISH,
+					},
+				}).Error
+				assert.Nil(t, err)
+
+				preview := withdrawal_service.NewTaskPreview()
+				preview.Withdrawals = append(preview.Withdrawals, &withdrawal_service.PreviewWithdrawal{
+					Amount: 150000,
+					IsNew:  true,
+				})
+				preview.CompletedOrders = append(preview.CompletedOrders, "ORD001", "ORD002")
+				preview.OrderNotFound = append(preview.OrderNotFound, &withdrawal_service.PreviewOrderNotFound{
+					OrderRefId: "ORD003",
+					Amount:     5000,
+				})
+
+				err = store.SetPreview(7, preview)
+				assert.Nil(t, err)
+
+				res, err := client.GetTaskPreview(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetTaskPreviewRequest{
+					TeamId: 1,
+					TaskId: 7,
+				}))
+				assert.Nil(t, err)
+				assert.Len(t, res.Msg.Preview.Withdrawals, 1)
+				assert.Equal(t, []string{"ORD001", "ORD002"}, res.Msg.Preview.CompletedOrders)
+				assert.Equal(t, "ORD003", res.Msg.Preview.OrderNotFound[0].OrderRefId)
+
+				_, err = client.GetTaskPreview(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetTaskPreviewRequest{
+					TeamId: 2,
+					TaskId: 7,
+				}))
+				assert.NotNil(t, err)
+			})
+
+			t.Run("rekonsiliasi per withdrawal", func(t *testing.T) {
+				at := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+				for _, diff := range []float64{-5000, 0} {
+					rec := withdrawal_service.WithdrawalReconciliation{
+						Source:           withdrawal_service.ReconciliationV1,
+						TeamID:           1,
+						WithdrawalID:     10,
+						TaskID:           1,
+						WithdrawalAmount: money.FromFloat(20000),
+						CarriedOver:      money.FromFloat(1500),
+						DiffAmount:       money.FromFloat(diff),
+					}
+					rec.SetEarnings(
+						[]*db_models.InvoItem{
+							{ExternalOrderID: "ORD001", Type: db_models.AdjOrderFund, Amount: 10000.1, TransactionDate: at},
+							{ExternalOrderID: "ORD002", Type: db_models.AdjCommision, Amount: 8500.2, TransactionDate: at},
+						},
+						[]*db_models.InvoItem{
+							{ExternalOrderID: "ORD003", Type: db_models.AdjOrderFund, Amount: 5000, TransactionDate: at},
+						},
+					)
+
+					err := withdrawal_service.SaveReconciliation(&db, &rec)
+					assert.Nil(t, err)
+				}
+
+				res, err := client.GetReconciliation(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetReconciliationRequest{
+					TeamId:       1,
+					WithdrawalId: 10,
+				}))
+				assert.Nil(t, err)
+
+				rec := res.Msg.Reconciliation
+				assert.Equal(t, 0.0, rec.DiffAmount) // yang terakhir disimpan
+				assert.Equal(t, 18500.3, rec.MatchedAmount)
+				assert.Equal(t, 5000.0, rec.UnmatchedAmount)
+				assert.Equal(t, 1500.0, rec.CarriedOver)
+				assert.Len(t, rec.Matched, 2)
+				assert.Equal(t, "ORD003", rec.Unmatched[0].OrderRefId)
+
+				_, err = client.GetReconciliation(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetReconciliationRequest{
+					TeamId:       1,
+					Source:       string(withdrawal_service.ReconciliationV2),
+					WithdrawalId: 10,
+				}))
+				assert.NotNil(t, err)
+			})
+
+			t.Run("mapping kolom per team dan marketplace", func(t *testing.T) {
+				_, err := client.GetColumnMapping(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetColumnMappingRequest{
+					TeamId: 1,
+					MpId:   3,
+				}))
+				assert.NotNil(t, err)
+
+				_, err = withdrawal_service.SaveColumnMapping(&db, 1, 0, &datasource.ColumnMapping{
+					DateColumn:     "Tanggal",
+					DateLayout:     "2006-01-02",
+					OrderRefColumn: "Resi",
+					AmountColumn:   "Nominal",
+				}, 1)
+				assert.Nil(t, err)
+
+				_, err = withdrawal_service.SaveColumnMapping(&db, 1, 0, &datasource.ColumnMapping{}, 1)
+				assert.NotNil(t, err)
+
+				res, err := client.GetColumnMapping(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetColumnMappingRequest{
+					TeamId: 1,
+					MpId:   3,
+				}))
+				assert.Nil(t, err)
+				assert.Equal(t, uint64(0), res.Msg.ColumnMapping.MpId)
+				assert.Equal(t, "Resi", res.Msg.ColumnMapping.Mapping.OrderRefColumn)
+
+				// mapping marketplace didahulukan dari mapping team
+				_, err = withdrawal_service.SaveColumnMapping(&db, 1, 3, &datasource.ColumnMapping{
+					DateColumn:     "Tanggal",
+					DateLayout:     "2006-01-02",
+					OrderRefColumn: "No Order",
+					AmountColumn:   "Nominal",
+				}, 1)
+				assert.Nil(t, err)
+
+				res, err = client.GetColumnMapping(t.Context(), connect.NewRequest(&withdrawal_task_iface.GetColumnMappingRequest{
+					TeamId: 1,
+					MpId:   3,
+				}))
+				assert.Nil(t, err)
+				assert.Equal(t, uint64(3), res.Msg.ColumnMapping.MpId)
+				assert.Equal(t, "No Order", res.Msg.ColumnMapping.Mapping.OrderRefColumn)
+			})
+		},
+	)
+}
@@ -1,10 +1,10 @@
 package datasource
 
 import (
-	"math"
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
 )
 
 type DetailSet struct {
@@ -30,8 +30,8 @@ func (ds *DetailSet) GetGmvDeduction(txDate time.Time, amount float64) (InvoItem
 	var err error
 
 	result := InvoItemList{}
-	amount = math.Abs(amount)
-	var iterAmount float64
+	target := money.FromFloat(amount).Abs()
+	var iterAmount money.Amount
 
 	// log.Println("-----------------------------------")
 	for _, invo := range ds.Data {
@@ -50,9 +50,9 @@ func (ds *DetailSet) GetGmvDeduction(txDate time.Time, amount float64) (InvoItem
 			continue
 		}
 
-		iterAmount += math.Abs(invo.Amount)
+		iterAmount += money.FromFloat(invo.Amount).Abs()
 		// log.Printf("iter: %.3f amount: %.3f need: %.3f\n", iterAmount, math.Abs(invo.Amount), amount)
-		if iterAmount <= amount {
+		if iterAmount <= target {
 			result = append(result, invo)
 			ds.getted[invo.ExternalOrderID] = true
 			continue
@@ -9,6 +9,7 @@ import (
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/excel_reader"
 	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -113,7 +114,7 @@ func (t *tiktokWdXlsImpl) Iterate(ctx context.Context, handler func(item *db_mod
 			ExternalOrderID: item.ExternalOrderID,
 			TransactionDate: item.OrderSettledTime,
 			Description:     item.Type,
-			Amount:          item.SettlementAmount,
+			Amount:          money.FromFloat(item.SettlementAmount).Float64(),
 			BalanceAfter:    0,
 			Type:            tipe,
 		}
@@ -167,8 +168,8 @@ func (t *tiktokWdXlsImpl) Iterate(ctx context.Context, handler func(item *db_mod
 				MpFrom:          db_models.OrderMpTiktok,
 				TransactionDate: item.SuccessTime,
 				Type:            db_models.AdjFund,
-				Amount:          item.Amount,
-				BalanceAfter:    item.AfterAmount,
+				Amount:          money.FromFloat(item.Amount).Float64(),
+				BalanceAfter:    money.FromFloat(item.AfterAmount).Float64(),
 				Description:     "penarikan dana tiktok",
 			}
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/excel_reader"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -34,7 +35,7 @@ func (s *v2TiktokWdImpl) IterateValidWithdrawal() ([]*WdSet, error) {
 
 	for i, wd := range wds {
 
-		fundedEarning, _, err := wd.FundedEarning()
+		fundedEarning, notFunded, err := wd.FundedEarning()
 		if err != nil {
 			if i == 0 {
 				return result, err
@@ -56,6 +57,7 @@ func (s *v2TiktokWdImpl) IterateValidWithdrawal() ([]*WdSet, error) {
 			WdSetBefore: wd.WdSetBefore,
 			WdSetNext:   wd.WdSetNext,
 			Earning:     fundedEarning,
+			NotFunded:   notFunded,
 			IsLast:      wd.IsLast,
 		})
 	}
@@ -135,7 +137,7 @@ func (s *v2TiktokWdImpl) IterateWithdrawal() ([]*WdSet, error) {
 		case "GMV Pay Deduction":
 			invos, err = details.GetGmvDeduction(item.RequestTime, item.Amount)
 
-			if item.Amount != invos.GetAmount() {
+			if money.FromFloat(item.Amount) != invos.GetAmount() {
 				return fmt.Errorf(
 					"error transaction %s amount %.3f time %s",
 					item.Type,
@@ -199,10 +201,10 @@ func (invs InvoItemList) AdsPayment() InvoItemList {
 // 	return hasil
 // }
 
-func (invs InvoItemList) GetAmount() float64 {
-	hasil := 0.00
+func (invs InvoItemList) GetAmount() money.Amount {
+	var hasil money.Amount
 	for _, inv := range invs {
-		hasil += inv.Amount
+		hasil += money.FromFloat(inv.Amount)
 	}
 	return hasil
 }
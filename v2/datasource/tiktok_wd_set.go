@@ -3,7 +3,9 @@ package datasource
 import (
 	"errors"
 	"fmt"
-	"math"
+
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 )
 
 type Earning struct {
@@ -13,8 +15,8 @@ type Earning struct {
 
 type EarningList []*Earning
 
-func (e EarningList) GetAmount() float64 {
-	hasil := 0.00
+func (e EarningList) GetAmount() money.Amount {
+	var hasil money.Amount
 	for _, earning := range e {
 		// log.Println(earning.Earning.Type)
 		invoAmount := earning.Involist.GetAmount()
@@ -55,6 +57,7 @@ type WdSet struct {
 	WdSetBefore *WdSet
 	WdSetNext   *WdSet
 	Earning     EarningList
+	NotFunded   EarningList // earning yang masih jadi saldo setelah withdrawal
 	IsLast      bool
 }
 
@@ -65,8 +68,8 @@ func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
 	notfundedlist := EarningList{}
 
 	i := len(wd.Earning)
-	var fundedAmount float64 // dont use this for checking further
-	wdAmount := math.Abs(wd.Withdrawal.Amount)
+	var fundedAmount money.Amount // dont use this for checking further
+	wdAmount := money.FromFloat(wd.Withdrawal.Amount).Abs()
 
 	// sos untuk fian karena ada gmv
 	if wdAmount == wd.Earning.GetAmount() {
@@ -76,7 +79,7 @@ func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
 		for i > 0 {
 			i--
 			earning := wd.Earning[i]
-			fundedAmount += earning.Earning.Amount
+			fundedAmount += money.FromFloat(earning.Earning.Amount)
 			if fundedAmount <= wdAmount {
 				earninglist = append(earninglist, earning)
 			} else {
@@ -97,9 +100,9 @@ func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
 						return earninglist, notfundedlist, wd.WithErrf("onbefore negative")
 					}
 
-					var needBeforeAmount float64
+					var needBeforeAmount money.Amount
 					for _, earn := range before.Earning {
-						needBeforeAmount += earn.Earning.Amount
+						needBeforeAmount += money.FromFloat(earn.Earning.Amount)
 						if needBeforeAmount <= onbeforeAmount {
 							beforeNotFunded = append(beforeNotFunded, earn)
 						} else {
@@ -108,7 +111,7 @@ func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
 					}
 					if onbeforeAmount != beforeNotFunded.GetAmount() {
 						// fmt.Printf("%.3f - %.3f - %d\n\n\n\n", needBeforeAmount, onbeforeAmount, len(earninglist))
-						return earninglist, notfundedlist, fmt.Errorf("need before not same, %.2f vs %.2f", onbeforeAmount, beforeNotFunded.GetAmount())
+						return earninglist, notfundedlist, fmt.Errorf("need before not same, %s vs %s", onbeforeAmount, beforeNotFunded.GetAmount())
 					}
 
 				} else {
@@ -121,7 +124,11 @@ func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
 
 				earninglist = append(beforeNotFunded, earninglist...)
 				if earninglist.GetAmount() != wdAmount {
-					return earninglist, notfundedlist, wd.WithErr(fmt.Errorf("ls cannot trace funded earnings wd %.3f and earn %.3f", wdAmount, earninglist.GetAmount()))
+					return earninglist, notfundedlist, wd.WithErr(errEarningNotTraced(
+						fmt.Sprintf("ls cannot trace funded earnings wd %s and earn %s", wdAmount, earninglist.GetAmount()),
+						wdAmount,
+						earninglist.GetAmount(),
+					))
 				}
 
 				return earninglist, notfundedlist, nil
@@ -129,13 +136,23 @@ func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
 			} else {
 				if !wd.IsLast {
 					earn := wd.Earning[len(wd.Earning)-1]
-					err = fmt.Errorf("butuh range lebih lama dari %s", earn.Earning.RequestTime.String())
+					err = wd_error.New(
+						wd_error.CodeRangeTooShort,
+						fmt.Sprintf("butuh range lebih lama dari %s", earn.Earning.RequestTime.String()),
+						wd_error.Params{
+							"before": earn.Earning.RequestTime.String(),
+						},
+					)
 					return earninglist, notfundedlist, err
 				}
 			}
 		}
 
-		return earninglist, notfundedlist, wd.WithErr(fmt.Errorf("cannot trace funded earning wd ret %.3f and earn %.3f", wdAmount, earninglist.GetAmount()))
+		return earninglist, notfundedlist, wd.WithErr(errEarningNotTraced(
+			fmt.Sprintf("cannot trace funded earning wd ret %s and earn %s", wdAmount, earninglist.GetAmount()),
+			wdAmount,
+			earninglist.GetAmount(),
+		))
 	}
 
 	return earninglist, notfundedlist, nil
@@ -147,13 +164,20 @@ func (w *WdSet) WithErrf(format string, a ...any) error {
 
 func (w *WdSet) WithErr(err error) error {
 	return fmt.Errorf(
-		"withdrawal %.3f at %s error %s",
+		"withdrawal %.3f at %s error %w",
 		w.Withdrawal.Amount,
 		w.Withdrawal.SuccessTime,
-		err.Error(),
+		err,
 	)
 }
 
+func errEarningNotTraced(msg string, wdAmount money.Amount, earnAmount money.Amount) error {
+	return wd_error.New(wd_error.CodeEarningNotTraced, msg, wd_error.Params{
+		"wd_amount":   wdAmount.String(),
+		"earn_amount": earnAmount.String(),
+	})
+}
+
 // func (wd *WdSet) TraceValidEarning() (EarningList, error) {
 // 	result := EarningList{}
 // 	result = append(result, wd.Earning...)
@@ -188,8 +212,8 @@ func (w *WdSet) WithErr(err error) error {
 // 	return result, nil
 // }
 
-func (wd *WdSet) NotFundedAmount() float64 {
-	return wd.Earning.GetAmount() - math.Abs(wd.Withdrawal.Amount)
+func (wd *WdSet) NotFundedAmount() money.Amount {
+	return wd.Earning.GetAmount() - money.FromFloat(wd.Withdrawal.Amount).Abs()
 }
 
 // func (wd *WdSet) FundedEarning() (EarningList, EarningList, error) {
@@ -1,10 +1,10 @@
 package datasource_test
 
 import (
-	"math"
 	"os"
 	"testing"
 
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/pdcgo/withdrawal_service/v2/datasource"
 	"github.com/stretchr/testify/assert"
 )
@@ -71,7 +71,7 @@ func TestToktokSini1Bulan(t *testing.T) {
 
 			earning, _, err := wd.FundedEarning()
 			assert.Nil(t, err)
-			assert.Equal(t, math.Abs(wd.Withdrawal.Amount), earning.GetAmount())
+			assert.Equal(t, money.FromFloat(wd.Withdrawal.Amount).Abs(), earning.GetAmount())
 
 		}
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
 )
 
 func NewDataframe(ctx context.Context, files []io.ReadCloser) (*db_models.InvoItemDataFrame, error) {
@@ -63,20 +64,11 @@ func NewValidWithdrawal(
 	notFundMap := map[int]EarningList{}
 
 	for i, invWd := range invWds {
-		var notFund EarningList
 		// getting not funded
-		notFund = df.
-			Query(
-				df.D.TransactionDate.Filter(func(i int, item time.Time) bool {
-					return item.Before(invWd.TransactionDate)
-				}),
-				df.D.BalanceAfter.Break(false, func(i int, item float64) bool {
-					return item == math.Abs(invWd.Amount)
-				}),
-			).Data()
+		notFund := wdNotFunded(df, invWd)
 
-		if notFund.GetAmount() != invWd.BalanceAfter {
-			return result, fmt.Errorf("not funded is %.1f but balance after is %.1f", notFund.GetAmount(), invWd.BalanceAfter)
+		if notFund.GetAmount() != money.FromFloat(invWd.BalanceAfter) {
+			return result, fmt.Errorf("not funded is %s but balance after is %.1f", notFund.GetAmount(), invWd.BalanceAfter)
 		}
 
 		if len(notFund) == 0 {
@@ -93,68 +85,120 @@ func NewValidWithdrawal(
 	}
 
 	for i, invWd := range invWds {
-		var fund EarningList
-		fundf := df.
-			Query(
-				df.D.TransactionDate.Filter(func(i int, item time.Time) bool {
-					return item.Before(invWd.TransactionDate)
-				}),
-			)
-
-		notFund, ok := notFundMap[i]
-		if !ok {
-
-			fundf = fundf.
-				Query(
-					fundf.D.BalanceAfter.Break(false, func(i int, item float64) bool {
-
-						// fmt.Printf("%d %.1f\n", i, item)
-						return item == 0
-					}),
-				)
-		} else {
-			first := notFund[len(notFund)-1]
-
-			cc := 0.0
-
-			fundf = fundf.
-				Query(
-					fundf.D.TransactionDate.Filter(func(i int, item time.Time) bool {
-						return item.Before(first.TransactionDate)
-					}),
-
-					fundf.D.Amount.Break(true, func(i int, item float64) bool {
-						cc += item
-						return cc == math.Abs(invWd.Amount)
-					}),
-
-					// fundf.D.Amount.SearchPosition(func(partial Series[float64]) (bool, bool) {
-					// 	res := 0.0
-					// 	for _, item := range partial {
-					// 		res += item
-					// 	}
-					// 	return res > math.Abs(invWd.Amount), res == math.Abs(invWd.Amount)
-					// }),
-				)
-
-			// debugtool.LogJson(fundf.Data())
-		}
-		fund = fundf.Data()
+		fund := wdFunded(df, invWds, i, notFundMap)
+		notFund := notFundMap[i]
 
-		if math.Abs(invWd.Amount) != fund.GetAmount() {
+		if money.FromFloat(invWd.Amount).Abs() != fund.GetAmount() {
 			if i == (len(invWds)-1) && i > 1 {
 				return result, nil
 			}
 
-			return result, fmt.Errorf("funded is %.1f but withdrawal is %.1f", fund.GetAmount(), math.Abs(invWd.Amount))
+			return result, fmt.Errorf("funded is %s but withdrawal is %.1f", fund.GetAmount(), math.Abs(invWd.Amount))
 		}
 
 		result = append(result, &ShopeeWdSet{
 			Withdrawal: invWd,
 			Earning:    fund,
+			NotFunded:  notFund,
 		})
 
 	}
 
 	return result, nil
 }
+
+// wdNotFunded earning yang masih jadi saldo setelah withdrawal.
+// kalau saldo minus, earning terbaru yang membuat minus (sama dengan deficitEarning)
+func wdNotFunded(df *db_models.InvoItemDataFrame, invWd *db_models.InvoItem) EarningList {
+	beforef := df.
+		Query(
+			df.D.TransactionDate.Filter(func(i int, item time.Time) bool {
+				return item.Before(invWd.TransactionDate)
+			}),
+		)
+
+	balanceAfter := money.FromFloat(invWd.BalanceAfter)
+	if balanceAfter < 0 {
+		var cc money.Amount
+		return beforef.
+			Query(
+				beforef.D.Amount.Break(true, func(i int, item float64) bool {
+					cc += money.FromFloat(item)
+					return cc == balanceAfter
+				}),
+			).Data()
+	}
+
+	return beforef.
+		Query(
+			beforef.D.BalanceAfter.Break(false, func(i int, item float64) bool {
+				return money.FromFloat(item) == money.FromFloat(invWd.Amount).Abs()
+			}),
+		).Data()
+}
+
+// wdFunded earning yang didanai withdrawal ke i, invWds urut dari yang terbaru.
+// saldo minus withdrawal sebelumnya ikut dipotong dari withdrawal ini
+func wdFunded(
+	df *db_models.InvoItemDataFrame,
+	invWds []*db_models.InvoItem,
+	i int,
+	notFundMap map[int]EarningList,
+) EarningList {
+	invWd := invWds[i]
+	target := money.FromFloat(invWd.Amount).Abs()
+
+	fundf := df.
+		Query(
+			df.D.TransactionDate.Filter(func(i int, item time.Time) bool {
+				return item.Before(invWd.TransactionDate)
+			}),
+		)
+
+	var deficit EarningList
+	if i+1 < len(invWds) && money.FromFloat(invWds[i+1].BalanceAfter) < 0 {
+		before := invWds[i+1]
+		deficit = notFundMap[i+1]
+		target -= deficit.GetAmount()
+
+		fundf = fundf.
+			Query(
+				fundf.D.TransactionDate.Filter(func(i int, item time.Time) bool {
+					return item.After(before.TransactionDate)
+				}),
+			)
+	}
+
+	notFund, ok := notFundMap[i]
+	switch {
+	case ok:
+		first := notFund[len(notFund)-1]
+
+		var cc money.Amount
+
+		fundf = fundf.
+			Query(
+				fundf.D.TransactionDate.Filter(func(i int, item time.Time) bool {
+					return item.Before(first.TransactionDate)
+				}),
+
+				fundf.D.Amount.Break(true, func(i int, item float64) bool {
+					cc += money.FromFloat(item)
+					return cc == target
+				}),
+			)
+	case len(deficit) > 0:
+		// saldo tidak pernah 0 setelah withdrawal sebelumnya, semua earning setelahnya ikut didanai
+	default:
+		fundf = fundf.
+			Query(
+				fundf.D.BalanceAfter.Break(false, func(i int, item float64) bool {
+					return money.FromFloat(item) == 0
+				}),
+			)
+	}
+
+	fund := EarningList{}
+	fund = append(fund, fundf.Data()...)
+	return append(fund, deficit...)
+}
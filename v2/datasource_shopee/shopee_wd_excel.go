@@ -2,18 +2,19 @@ package datasource_shopee
 
 import (
 	"context"
-	"errors"
 	"io"
 	"strings"
 
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/excel_reader"
 	"github.com/pdcgo/withdrawal_service/models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 	"github.com/xuri/excelize/v2"
 )
 
-var ErrContainInProcessWD = errors.New("ada withdrawal yang masih diproses, silahkan reimport kembali ketika withdrawalnya menjadi selesai")
-var ErrCannotGetMarketplaceUsername = errors.New("cannot get marketplace username")
+var ErrContainInProcessWD = wd_error.New(wd_error.CodeWdInProcess, "ada withdrawal yang masih diproses, silahkan reimport kembali ketika withdrawalnya menjadi selesai", nil)
+var ErrCannotGetMarketplaceUsername = wd_error.New(wd_error.CodeMpUsernameNotFound, "cannot get marketplace username", nil)
 
 type shopeeXlsImpl struct {
 	reader io.ReadCloser
@@ -68,6 +69,7 @@ func (s *shopeeXlsImpl) ValidWithdrawal(ctx context.Context) ([]*ShopeeWdSet, er
 				WdSetBefore: wd.WdSetBefore,
 				WdSetNext:   wd.WdSetNext,
 				Earning:     validEarning,
+				NotFunded:   wd.Earning.Exclude(validEarning),
 				IsLast:      wd.IsLast,
 			})
 		}
@@ -245,7 +247,7 @@ func (s *shopeeXlsImpl) Iterate(ctx context.Context, handler func(item *db_model
 		// checking duplicate fund
 		if item.Type == models.WdTxFund {
 			if lastitem.Type == item.Type {
-				if lastitem.Amount == item.Amount {
+				if money.FromFloat(lastitem.Amount) == money.FromFloat(item.Amount) {
 					return nil
 				}
 			}
@@ -257,8 +259,8 @@ func (s *shopeeXlsImpl) Iterate(ctx context.Context, handler func(item *db_model
 			ExternalOrderID: item.ExternalOrderID,
 			TransactionDate: item.TransactionDate,
 			Description:     item.Description,
-			Amount:          item.Amount,
-			BalanceAfter:    item.BalanceAfter,
+			Amount:          money.FromFloat(item.Amount).Float64(),
+			BalanceAfter:    money.FromFloat(item.BalanceAfter).Float64(),
 			Type:            tipe,
 			IsOtherRegion:   isOtherRegion,
 			Region:          region,
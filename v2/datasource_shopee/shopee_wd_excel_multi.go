@@ -6,10 +6,10 @@ import (
 	"io"
 	"math"
 	"strings"
-	"time"
 
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/common_helper"
+	"github.com/pdcgo/withdrawal_service/money"
 )
 
 //go:generate go run github.com/wargasipil/data_processing
@@ -44,7 +44,7 @@ func (w *wdMultiFileImpl) GetRefIDs() (OrderRefList, error) {
 func (w *wdMultiFileImpl) ValidWithdrawal(ctx context.Context) ([]*ShopeeWdSet, error) {
 	var err error
 	var result []*ShopeeWdSet = []*ShopeeWdSet{}
-	failedMap := map[float64]EarningList{}
+	failedMap := map[money.Amount]EarningList{}
 
 	caller := common_helper.NewChainParam[*db_models.InvoItemDataFrame](
 		func(next common_helper.NextFuncParam[*db_models.InvoItemDataFrame]) common_helper.NextFuncParam[*db_models.InvoItemDataFrame] {
@@ -92,20 +92,11 @@ func (w *wdMultiFileImpl) ValidWithdrawal(ctx context.Context) ([]*ShopeeWdSet,
 				notFundMap := map[int]EarningList{}
 
 				for i, invWd := range invWds {
-					var notFund EarningList
 					// getting not funded
-					notFund = df.
-						Query(
-							df.D.TransactionDate.Filter(func(i int, item time.Time) bool {
-								return item.Before(invWd.TransactionDate)
-							}),
-							df.D.BalanceAfter.Break(false, func(i int, item float64) bool {
-								return item == math.Abs(invWd.Amount)
-							}),
-						).Data()
-
-					if notFund.GetAmount() != invWd.BalanceAfter {
-						return nil, fmt.Errorf("not funded is %.1f but balance after is %.1f", notFund.GetAmount(), invWd.BalanceAfter)
+					notFund := wdNotFunded(df, invWd)
+
+					if notFund.GetAmount() != money.FromFloat(invWd.BalanceAfter) {
+						return nil, fmt.Errorf("not funded is %s but balance after is %.1f", notFund.GetAmount(), invWd.BalanceAfter)
 					}
 
 					if len(notFund) == 0 {
@@ -124,65 +115,21 @@ func (w *wdMultiFileImpl) ValidWithdrawal(ctx context.Context) ([]*ShopeeWdSet,
 				wdlen := len(invWds)
 
 				for i, invWd := range invWds {
-					var fund EarningList
-					fundf := df.
-						Query(
-							df.D.TransactionDate.Filter(func(i int, item time.Time) bool {
-								return item.Before(invWd.TransactionDate)
-							}),
-						)
-
-					notFund, ok := notFundMap[i]
-					if !ok {
-
-						fundf = fundf.
-							Query(
-								fundf.D.BalanceAfter.Break(false, func(i int, item float64) bool {
-
-									// fmt.Printf("%d %.1f\n", i, item)
-									return item == 0
-								}),
-							)
-					} else {
-						first := notFund[len(notFund)-1]
-
-						cc := 0.0
-
-						fundf = fundf.
-							Query(
-								fundf.D.TransactionDate.Filter(func(i int, item time.Time) bool {
-									return item.Before(first.TransactionDate)
-								}),
-
-								fundf.D.Amount.Break(true, func(i int, item float64) bool {
-									cc += item
-									return cc == math.Abs(invWd.Amount)
-								}),
-
-								// fundf.D.Amount.SearchPosition(func(partial Series[float64]) (bool, bool) {
-								// 	res := 0.0
-								// 	for _, item := range partial {
-								// 		res += item
-								// 	}
-								// 	return res > math.Abs(invWd.Amount), res == math.Abs(invWd.Amount)
-								// }),
-							)
-
-						// debugtool.LogJson(fundf.Data())
-					}
-					fund = fundf.Data()
+					fund := wdFunded(df, invWds, i, notFundMap)
+					notFund := notFundMap[i]
 
-					if math.Abs(invWd.Amount) != fund.GetAmount() {
+					if money.FromFloat(invWd.Amount).Abs() != fund.GetAmount() {
 						if i == (wdlen-1) && wdlen > 1 {
 							return next(df)
 						}
 
-						return nil, fmt.Errorf("funded is %.1f but withdrawal is %.1f", fund.GetAmount(), math.Abs(invWd.Amount))
+						return nil, fmt.Errorf("funded is %s but withdrawal is %.1f", fund.GetAmount(), math.Abs(invWd.Amount))
 					}
 
 					result = append(result, &ShopeeWdSet{
 						Withdrawal: invWd,
 						Earning:    fund,
+						NotFunded:  notFund,
 					})
 
 				}
@@ -196,7 +143,7 @@ func (w *wdMultiFileImpl) ValidWithdrawal(ctx context.Context) ([]*ShopeeWdSet,
 				// mapping failed
 				for _, wd := range result {
 					if wd.Withdrawal.Failed {
-						key := math.Abs(wd.Withdrawal.Amount)
+						key := money.FromFloat(wd.Withdrawal.Amount).Abs()
 						failedMap[key] = wd.Earning
 					}
 				}
@@ -225,7 +172,7 @@ func (w *wdMultiFileImpl) ValidWithdrawal(ctx context.Context) ([]*ShopeeWdSet,
 						continue
 					}
 
-					fwd, ok := failedMap[invo.Amount]
+					fwd, ok := failedMap[money.FromFloat(invo.Amount)]
 
 					if !ok {
 						return df, fmt.Errorf("cannot find withdrawal invoice list of %.2f", invo.Amount)
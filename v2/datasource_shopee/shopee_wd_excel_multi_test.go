@@ -1,13 +1,16 @@
 package datasource_shopee_test
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"testing"
 
 	"github.com/pdcgo/shared/pkg/debugtool"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/pdcgo/withdrawal_service/v2/datasource_shopee"
 	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
 )
 
 func TestOrderLuarNegeri(t *testing.T) {
@@ -116,3 +119,69 @@ func TestLuxyWdGagal(t *testing.T) {
 		}
 	}
 }
+
+func shopeeTransactionFile(t *testing.T, rows [][]interface{}) io.ReadCloser {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Transaction Report"
+	err := f.SetSheetName(f.GetSheetName(0), sheet)
+	assert.Nil(t, err)
+
+	username := []interface{}{"Username (Penjual)", "toko_test"}
+	err = f.SetSheetRow(sheet, "A1", &username)
+	assert.Nil(t, err)
+
+	header := []interface{}{"Tanggal Transaksi", "Tipe Transaksi", "Deskripsi", "No. Pesanan", "Jenis Transaksi", "Jumlah", "Status", "Saldo Akhir"}
+	err = f.SetSheetRow(sheet, "A3", &header)
+	assert.Nil(t, err)
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+4)
+		assert.Nil(t, err)
+		err = f.SetSheetRow(sheet, cell, &row)
+		assert.Nil(t, err)
+	}
+
+	buf, err := f.WriteToBuffer()
+	assert.Nil(t, err)
+	return io.NopCloser(bytes.NewReader(buf.Bytes()))
+}
+
+func TestMultiFileSaldoMinus(t *testing.T) {
+	// potongan return masuk setelah withdrawal diajukan, saldo akhir withdrawal jadi minus
+	older := shopeeTransactionFile(t, [][]interface{}{
+		{"2025-08-04 10:00:00", "Penarikan Dana", "Penarikan Dana", "-", "Transaksi Keluar", "-150.00", "Transaksi Selesai", "-30.00"},
+		{"2025-08-03 10:00:00", "Penyesuaian", "Pengembalian Dana ORD003", "ORD003", "Transaksi Keluar", "-30.00", "Transaksi Selesai", "120.00"},
+		{"2025-08-02 10:00:00", "Penghasilan dari Pesanan", "Penghasilan dari Pesanan #ORD002", "ORD002", "Transaksi Masuk", "50.00", "Transaksi Selesai", "150.00"},
+		{"2025-08-01 10:00:00", "Penghasilan dari Pesanan", "Penghasilan dari Pesanan #ORD001", "ORD001", "Transaksi Masuk", "100.00", "Transaksi Selesai", "100.00"},
+	})
+	newer := shopeeTransactionFile(t, [][]interface{}{
+		{"2025-08-06 10:00:00", "Penarikan Dana", "Penarikan Dana", "-", "Transaksi Keluar", "-50.00", "Transaksi Selesai", "0.00"},
+		{"2025-08-05 10:00:00", "Penghasilan dari Pesanan", "Penghasilan dari Pesanan #ORD004", "ORD004", "Transaksi Masuk", "80.00", "Transaksi Selesai", "50.00"},
+	})
+
+	importer, err := datasource_shopee.NewShopeeXlsMultiFile([]io.ReadCloser{newer, older})
+	assert.Nil(t, err)
+
+	wds, err := importer.ValidWithdrawal(t.Context())
+	assert.Nil(t, err)
+	assert.Len(t, wds, 2)
+
+	t.Run("saldo minus tidak ikut didanai", func(t *testing.T) {
+		minus := wds[1]
+		assert.Equal(t, money.FromFloat(-150), money.FromFloat(minus.Withdrawal.Amount))
+		assert.Equal(t, money.FromFloat(150), minus.Earning.GetAmount())
+		assert.Len(t, minus.Earning, 2)
+		assert.Len(t, minus.NotFunded, 1)
+		assert.Equal(t, "ORD003", minus.NotFunded[0].ExternalOrderID)
+	})
+
+	t.Run("saldo minus dipotong dari withdrawal berikutnya", func(t *testing.T) {
+		wd := wds[0]
+		assert.Equal(t, money.FromFloat(50), wd.Earning.GetAmount())
+		assert.Len(t, wd.Earning, 2)
+		assert.Equal(t, "ORD004", wd.Earning[0].ExternalOrderID)
+		assert.Equal(t, "ORD003", wd.Earning[1].ExternalOrderID)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/pdcgo/withdrawal_service/v2/datasource_shopee"
 	"github.com/stretchr/testify/assert"
 )
@@ -130,3 +132,37 @@ func TestLuxySisaErr2026(t *testing.T) {
 	_, err = importer.ValidWithdrawal(t.Context())
 	assert.Nil(t, err)
 }
+
+func TestShopeeSaldoMinus(t *testing.T) {
+	// urutan file shopee dari yang terbaru, potongan return masuk setelah withdrawal diajukan
+	list := datasource_shopee.InvoItemList{
+		{Type: db_models.AdjFund, Amount: -50, BalanceAfter: 0},
+		{Type: db_models.AdjOrderFund, ExternalOrderID: "ORD004", Amount: 80, BalanceAfter: 50},
+		{Type: db_models.AdjFund, Amount: -150, BalanceAfter: -30},
+		{Type: db_models.AdjReturn, ExternalOrderID: "ORD003", Amount: -30, BalanceAfter: 120},
+		{Type: db_models.AdjOrderFund, ExternalOrderID: "ORD002", Amount: 50, BalanceAfter: 150},
+		{Type: db_models.AdjOrderFund, ExternalOrderID: "ORD001", Amount: 100, BalanceAfter: 100},
+	}
+
+	wds, err := list.Withdrawals(t.Context())
+	assert.Nil(t, err)
+	assert.Len(t, wds, 2)
+
+	minus := wds[1]
+	earns, err := minus.TraceValidEarning()
+	assert.Nil(t, err)
+	assert.Equal(t, money.FromFloat(150), earns.GetAmount())
+	assert.Len(t, earns, 2)
+
+	deficit, err := minus.NotFundedEarning()
+	assert.Nil(t, err)
+	assert.Len(t, deficit, 1)
+	assert.Equal(t, "ORD003", deficit[0].ExternalOrderID)
+
+	// saldo minus dipotong dari withdrawal berikutnya
+	earns, err = wds[0].TraceValidEarning()
+	assert.Nil(t, err)
+	assert.Equal(t, money.FromFloat(50), earns.GetAmount())
+	assert.Len(t, earns, 2)
+	assert.Equal(t, "ORD003", earns[1].ExternalOrderID)
+}
@@ -3,9 +3,10 @@ package datasource_shopee
 import (
 	"errors"
 	"fmt"
-	"math"
 
 	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/wd_error"
 )
 
 type ShopeeWdSet struct {
@@ -13,35 +14,63 @@ type ShopeeWdSet struct {
 	WdSetBefore *ShopeeWdSet
 	WdSetNext   *ShopeeWdSet
 	Earning     EarningList
+	NotFunded   EarningList // earning yang masih jadi saldo setelah withdrawal
 	IsLast      bool
 }
 
 func (w *ShopeeWdSet) WithErr(err error) error {
 	return fmt.Errorf(
-		"withdrawal %.3f at %s error %s",
+		"withdrawal %.3f at %s error %w",
 		w.Withdrawal.Amount,
 		w.Withdrawal.TransactionDate,
-		err.Error(),
+		err,
 	)
 }
 
+func errEarningNotTraced(msg string, wdAmount money.Amount, earnAmount money.Amount) error {
+	return wd_error.New(wd_error.CodeEarningNotTraced, msg, wd_error.Params{
+		"wd_amount":   wdAmount.String(),
+		"earn_amount": earnAmount.String(),
+	})
+}
+
 func (wd *ShopeeWdSet) FundedEarning() (EarningList, error) {
 	earnlist := EarningList{}
 
-	var fundedAmount float64
-	wdAmount := math.Abs(wd.Withdrawal.Amount)
+	var fundedAmount money.Amount
+	wdAmount := money.FromFloat(wd.Withdrawal.Amount).Abs()
+	balanceAfter := money.FromFloat(wd.Withdrawal.BalanceAfter)
 
-	if wd.Withdrawal.BalanceAfter < 0 {
-		return earnlist, errors.New("balance after minus not implemented")
+	// saldo withdrawal sebelumnya, plus jadi earning tambahan, minus harus ditutup earning set ini
+	var beforeNotFund EarningList
+	if wd.WdSetBefore != nil && wd.WdSetBefore.Withdrawal.BalanceAfter != 0 {
+		var err error
+		beforeNotFund, err = wd.WdSetBefore.NotFundedEarning()
+		if err != nil {
+			return earnlist, err
+		}
+	}
+
+	target := wdAmount
+	if beforeNotFund.GetAmount() < 0 {
+		target -= beforeNotFund.GetAmount()
 	}
 
 	i := 0
-	var notFundedAmount float64
-	if wd.Withdrawal.BalanceAfter > 0 {
+	var notFundedAmount money.Amount
+	if balanceAfter < 0 {
+		deficit, err := wd.deficitEarning()
+		if err != nil {
+			return earnlist, err
+		}
+		i = len(deficit)
+	}
+
+	if balanceAfter > 0 {
 		for i < len(wd.Earning) {
 
-			notFundedAmount += wd.Earning[i].Amount
-			if notFundedAmount <= wd.Withdrawal.BalanceAfter {
+			notFundedAmount += money.FromFloat(wd.Earning[i].Amount)
+			if notFundedAmount <= balanceAfter {
 				// fmt.Printf("not funded %.3f - %d - %.3f \n", wd.Withdrawal.Amount, i, wd.Earning[i].Amount)
 				// earnlist = append(earnlist, wd.Earning[i])
 				i++
@@ -56,9 +85,9 @@ func (wd *ShopeeWdSet) FundedEarning() (EarningList, error) {
 	// log.Println("cc", len(wd.Earning), wd.Withdrawal.Amount)
 	for c >= i {
 
-		fundedAmount += wd.Earning[c].Amount
+		fundedAmount += money.FromFloat(wd.Earning[c].Amount)
 
-		if fundedAmount <= wdAmount {
+		if fundedAmount <= target {
 			// fmt.Printf("funded %.3f - %d - %.3f - %.3f \n", wd.Withdrawal.Amount, c, wd.Earning[c].Amount, fundedAmount)
 			// earnlist = append(earnlist, wd.Earning[c])
 			earnlist = append([]*db_models.InvoItem{wd.Earning[c]}, earnlist...)
@@ -67,7 +96,7 @@ func (wd *ShopeeWdSet) FundedEarning() (EarningList, error) {
 			break
 		}
 
-		if fundedAmount == wdAmount {
+		if fundedAmount == target {
 			break
 		}
 
@@ -86,26 +115,19 @@ func (wd *ShopeeWdSet) FundedEarning() (EarningList, error) {
 
 	}
 
-	if wd.WdSetBefore != nil {
-		before := wd.WdSetBefore
-		if before.Withdrawal.BalanceAfter > 0 {
-			beforeNotFund, err := before.NotFundedEarning()
-			if err != nil {
-				return earnlist, err
-			}
+	// for _, earn := range beforeNotFund {
+	// 	fmt.Printf("before %.3f - %d - %.3f \n", before.Withdrawal.Amount, i, earn.Amount)
+	// }
 
-			// for _, earn := range beforeNotFund {
-			// 	fmt.Printf("before %.3f - %d - %.3f \n", before.Withdrawal.Amount, i, earn.Amount)
-			// }
-
-			earnlist = append(earnlist, beforeNotFund...)
-
-		}
-	}
+	earnlist = append(earnlist, beforeNotFund...)
 
 	if wdAmount != earnlist.GetAmount() {
 		// debugtool.LogJson(earnlist)
-		return earnlist, wd.WithErr(fmt.Errorf("cannot trace funded earning wd %.3f and earn %.3f", wdAmount, earnlist.GetAmount()))
+		return earnlist, wd.WithErr(errEarningNotTraced(
+			fmt.Sprintf("cannot trace funded earning wd %s and earn %s", wdAmount, earnlist.GetAmount()),
+			wdAmount,
+			earnlist.GetAmount(),
+		))
 	}
 
 	return earnlist, nil
@@ -114,19 +136,20 @@ func (wd *ShopeeWdSet) FundedEarning() (EarningList, error) {
 func (wd *ShopeeWdSet) NotFundedEarning() (EarningList, error) {
 	earnlist := EarningList{}
 
-	if wd.Withdrawal.BalanceAfter == 0 {
+	balanceAfter := money.FromFloat(wd.Withdrawal.BalanceAfter)
+	if balanceAfter == 0 {
 		return earnlist, nil
 	}
 
-	if wd.Withdrawal.BalanceAfter < 0 {
-		return earnlist, errors.New("balance after minus not implemented")
+	if balanceAfter < 0 {
+		return wd.deficitEarning()
 	}
 
-	if wd.Withdrawal.BalanceAfter > 0 {
-		var targetfund float64
+	if balanceAfter > 0 {
+		var targetfund money.Amount
 		for _, earn := range wd.Earning {
-			targetfund += earn.Amount
-			if targetfund <= wd.Withdrawal.BalanceAfter {
+			targetfund += money.FromFloat(earn.Amount)
+			if targetfund <= balanceAfter {
 				earnlist = append(earnlist, earn)
 			} else {
 				break
@@ -134,15 +157,31 @@ func (wd *ShopeeWdSet) NotFundedEarning() (EarningList, error) {
 		}
 	}
 
-	if wd.Withdrawal.BalanceAfter != earnlist.GetAmount() {
-		return earnlist, fmt.Errorf("tidak bisa detect sisa balance withdrawal %.3f", wd.Withdrawal.BalanceAfter)
+	if balanceAfter != earnlist.GetAmount() {
+		return earnlist, fmt.Errorf("tidak bisa detect sisa balance withdrawal %s", balanceAfter)
 	}
 
 	return earnlist, nil
 }
 
-func (wd *ShopeeWdSet) NotFundedAmount() float64 {
-	return wd.Earning.GetAmount() - math.Abs(wd.Withdrawal.Amount)
+// deficitEarning earning terbaru yang membuat saldo minus setelah withdrawal (biasanya potongan return),
+// tidak ikut didanai withdrawal ini dan dipotong dari withdrawal berikutnya
+func (wd *ShopeeWdSet) deficitEarning() (EarningList, error) {
+	balanceAfter := money.FromFloat(wd.Withdrawal.BalanceAfter)
+
+	var amount money.Amount
+	for i, earn := range wd.Earning {
+		amount += money.FromFloat(earn.Amount)
+		if amount == balanceAfter {
+			return append(EarningList{}, wd.Earning[:i+1]...), nil
+		}
+	}
+
+	return EarningList{}, fmt.Errorf("tidak bisa detect saldo minus withdrawal %s", balanceAfter)
+}
+
+func (wd *ShopeeWdSet) NotFundedAmount() money.Amount {
+	return wd.Earning.GetAmount() - money.FromFloat(wd.Withdrawal.Amount).Abs()
 }
 
 func (wd *ShopeeWdSet) TraceValidEarning() (EarningList, error) {
@@ -158,8 +197,13 @@ func (wd *ShopeeWdSet) TraceValidEarning() (EarningList, error) {
 			return result, err
 		}
 
-		if math.Abs(wd.Withdrawal.Amount) != result.GetAmount() {
-			return result, wd.WithErr(fmt.Errorf("cannot trace valid earning d %.3f", result.GetAmount()))
+		wdAmount := money.FromFloat(wd.Withdrawal.Amount).Abs()
+		if wdAmount != result.GetAmount() {
+			return result, wd.WithErr(errEarningNotTraced(
+				fmt.Sprintf("cannot trace valid earning d %s", result.GetAmount()),
+				wdAmount,
+				result.GetAmount(),
+			))
 		}
 
 	}
@@ -192,15 +236,27 @@ func (wd *ShopeeWdSet) TraceValidEarning() (EarningList, error) {
 
 type EarningList []*db_models.InvoItem
 
-func (e EarningList) GetAmount() float64 {
-	hasil := 0.00
+func (e EarningList) GetAmount() money.Amount {
+	var hasil money.Amount
 	for _, earning := range e {
-		invoAmount := earning.Amount
+		invoAmount := money.FromFloat(earning.Amount)
 		hasil += invoAmount
 	}
 	return hasil
 }
 
+// Exclude earning yang tidak ada di other, dibandingkan per item bukan nominal
+func (e EarningList) Exclude(other EarningList) EarningList {
+	res := EarningList{}
+	for _, earn := range e {
+		if Contains(other, earn) {
+			continue
+		}
+		res = append(res, earn)
+	}
+	return res
+}
+
 func (e EarningList) SubsetIndex(index []int, reverse bool) EarningList {
 	res := EarningList{}
 
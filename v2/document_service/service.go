@@ -14,6 +14,7 @@ import (
 	"github.com/pdcgo/schema/services/asset_iface/v1"
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/interfaces/authorization_iface"
+	withdrawal_service_v1 "github.com/pdcgo/withdrawal_service"
 	"gorm.io/gorm"
 )
 
@@ -125,6 +126,18 @@ func (w *wdDocServiceImpl) Upload(
 			return err
 		}
 
+		// hash dipakai submit withdrawal untuk menolak file duplikat sebelum task dibuat
+		err = tx.Save(&withdrawal_service_v1.WDResourceHash{
+			ResourcePath:  resource.Path,
+			TeamID:        resource.TeamID,
+			MarketplaceID: resource.MarketplaceID,
+			ContentHash:   withdrawal_service_v1.ContentHash(pay.Content),
+			CreatedAt:     resource.CreatedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
 		result.ResourceUri = resource.Path
 
 		//setting to public
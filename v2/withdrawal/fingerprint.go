@@ -0,0 +1,26 @@
+package withdrawal
+
+import (
+	withdrawal_service_v1 "github.com/pdcgo/withdrawal_service"
+)
+
+// checkFingerprint menolak file yang sudah pernah diimport ke toko yang sama, kecuali request reimport
+func (w *wdServiceImpl) checkFingerprint(reimport bool, teamID uint, mpID uint, hash string) error {
+	if reimport {
+		return nil
+	}
+
+	return withdrawal_service_v1.CheckFileFingerprint(w.db, withdrawal_service_v1.FingerprintV2, teamID, mpID, hash)
+}
+
+func (w *wdServiceImpl) saveFingerprint(reimport bool, teamID uint, mpID uint, hash string, uri string, userID uint) error {
+	return withdrawal_service_v1.SaveFileFingerprint(w.db, &withdrawal_service_v1.FileFingerprint{
+		Scope:       withdrawal_service_v1.FingerprintV2,
+		TeamID:      teamID,
+		MpID:        mpID,
+		ContentHash: hash,
+		ResourceUri: uri,
+		UserID:      userID,
+		Reimport:    reimport,
+	})
+}
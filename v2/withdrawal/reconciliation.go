@@ -0,0 +1,41 @@
+package withdrawal
+
+import (
+	"github.com/pdcgo/shared/db_models"
+	withdrawal_service_v1 "github.com/pdcgo/withdrawal_service"
+	"github.com/pdcgo/withdrawal_service/money"
+	"github.com/pdcgo/withdrawal_service/v2/datasource"
+	"github.com/pdcgo/withdrawal_service/v2/datasource_shopee"
+)
+
+func newV2Reconciliation(wlog *V2WithdrawalLog, carriedOver float64, matched, unmatched []*db_models.InvoItem) *withdrawal_service_v1.WithdrawalReconciliation {
+	rec := withdrawal_service_v1.WithdrawalReconciliation{
+		Source:           withdrawal_service_v1.ReconciliationV2,
+		TeamID:           wlog.TeamId,
+		WithdrawalID:     wlog.ID,
+		MpID:             wlog.ShopId,
+		WithdrawalAmount: money.FromFloat(wlog.Amount).Abs(),
+		CarriedOver:      money.FromFloat(carriedOver),
+	}
+	rec.SetEarnings(matched, unmatched)
+
+	// earning yang masuk dikurangi withdrawal, 0 berarti cocok semua
+	rec.DiffAmount = rec.MatchedAmount - rec.WithdrawalAmount
+	return &rec
+}
+
+func newShopeeReconciliation(wlog *V2WithdrawalLog, wd *datasource_shopee.ShopeeWdSet) *withdrawal_service_v1.WithdrawalReconciliation {
+	return newV2Reconciliation(wlog, wd.Withdrawal.BalanceAfter, wd.Earning, wd.NotFunded)
+}
+
+func newTiktokReconciliation(wlog *V2WithdrawalLog, wd *datasource.WdSet) *withdrawal_service_v1.WithdrawalReconciliation {
+	return newV2Reconciliation(wlog, wd.Withdrawal.AfterAmount, tiktokInvoItems(wd.Earning), tiktokInvoItems(wd.NotFunded))
+}
+
+func tiktokInvoItems(earnings datasource.EarningList) []*db_models.InvoItem {
+	items := []*db_models.InvoItem{}
+	for _, earning := range earnings {
+		items = append(items, earning.Involist...)
+	}
+	return items
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/pdcgo/shared/db_models"
 	"github.com/pdcgo/shared/pkg/moretest"
 	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	withdrawal_service_v1 "github.com/pdcgo/withdrawal_service"
 	"github.com/pdcgo/withdrawal_service/v2/withdrawal"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
@@ -103,6 +104,9 @@ func TestWdServiceImpl_SubmitWithdrawal(t *testing.T) {
 			&accounting_core.TransactionShop{},
 			&accounting_core.AccountingTag{},
 			&accounting_core.TransactionTag{},
+			&withdrawal_service_v1.FileFingerprint{},
+			&withdrawal_service_v1.WithdrawalReconciliation{},
+			&withdrawal_service_v1.ColumnMappingConfig{},
 		)
 
 		assert.Nil(t, err)
@@ -236,10 +240,32 @@ func TestWdServiceImpl_SubmitWithdrawal(t *testing.T) {
 						// t.Log(msg.Message)
 					}
 					err = stream.Err()
-					if err != nil {
-						t.Error(err.Error())
-					}
-					assert.Nil(t, err)
+					assert.ErrorContains(t, err, withdrawal_service_v1.ErrDuplicateFile.Error())
+
+					t.Run("dengan reimport", func(t *testing.T) {
+						req := connect.NewRequest(&withdrawal_iface.SubmitWithdrawalRequest{
+							TeamId: 1,
+							MpSubmit: &withdrawal_iface.MpSubmit{
+								MpId:   1,
+								MpType: common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK,
+							},
+							Source:      withdrawal_iface_v1.ImporterSource_IMPORTER_SOURCE_XLS,
+							ResourceUri: "../../test/assets/testwd/tiktok_wd_include_gmv.xlsx",
+							Reimport:    true,
+						})
+
+						stream, err := wdclient.SubmitWithdrawal(t.Context(), req)
+						assert.Nil(t, err)
+
+						for stream.Receive() {
+							stream.Msg()
+						}
+						err = stream.Err()
+						if err != nil {
+							t.Error(err.Error())
+						}
+						assert.Nil(t, err)
+					})
 				})
 			})
 
@@ -53,23 +53,6 @@ func (w *wdServiceImpl) SubmitWithdrawal(
 	}
 	token := req.Header().Get("Authorization")
 
-	streamlog("sync importer ke versi sebelumnya..")
-	reqv1 := connect.NewRequest(&withdrawal_iface_v1.SubmitWithdrawalRequest{
-		TeamId:      pay.TeamId,
-		MpId:        pay.MpSubmit.MpId,
-		Source:      pay.Source,
-		MpType:      pay.MpSubmit.MpType,
-		ResourceUri: pay.ResourceUri,
-	})
-
-	reqv1.Header().Set("Authorization", token)
-	_, err = w.v1service.SubmitWithdrawal(ctx, reqv1)
-
-	if err != nil {
-		return err
-	}
-
-	streamlog("proses updating data accounting..")
 	streamlog("membaca file..")
 	var importer withdrawal_service_v1.WdImporterIterate
 	var data []byte
@@ -78,8 +61,24 @@ func (w *wdServiceImpl) SubmitWithdrawal(
 		streamlog("error reading %s", pay.ResourceUri)
 		return err
 	}
+	hash := withdrawal_service_v1.ContentHash(data)
+
+	mapping, err := withdrawal_service_v1.FindColumnMapping(w.db.WithContext(ctx), uint(pay.TeamId), uint(pay.MpSubmit.MpId))
+	if err != nil {
+		return err
+	}
+
+	detected, err := withdrawal_service_v1.CheckDetectedFile(pay.Source, pay.MpSubmit.MpType, mapping, data)
+	if err != nil {
+		streamlog("%s", err.Error())
+		return err
+	}
+	if detected != nil {
+		streamlog("file terdeteksi %s (%s)", detected.MpType, detected.Parser)
+	}
+	source := withdrawal_service_v1.ImporterSourceOf(pay.Source, detected)
 
-	importer, err = w.createImporter(pay.MpSubmit.MpType, data)
+	importer, err = w.createImporter(source, pay.MpSubmit.MpType, mapping, data)
 	if err != nil {
 		streamlog("error create importer %s", pay.ResourceUri)
 		return err
@@ -98,6 +97,48 @@ func (w *wdServiceImpl) SubmitWithdrawal(
 		return err
 	}
 
+	switch pay.MpSubmit.MpType {
+	case common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK,
+		common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR:
+		// file tidak punya identitas toko, dicek dari order nya
+		var refIDs datasource.OrderRefList
+		refIDs, err = importer.GetRefIDs()
+		if err != nil {
+			streamlog("%s", err.Error())
+			return err
+		}
+
+		err = withdrawal_service_v1.CheckShopOrders(w.db.WithContext(ctx), uint(pay.TeamId), mp.ID, refIDs)
+		if err != nil {
+			streamlog("%s", err.Error())
+			return err
+		}
+	}
+
+	err = w.checkFingerprint(pay.Reimport, uint(pay.TeamId), mp.ID, hash)
+	if err != nil {
+		streamlog("%s", err.Error())
+		return err
+	}
+
+	streamlog("sync importer ke versi sebelumnya..")
+	reqv1 := connect.NewRequest(&withdrawal_iface_v1.SubmitWithdrawalRequest{
+		TeamId:      pay.TeamId,
+		MpId:        pay.MpSubmit.MpId,
+		Source:      source,
+		MpType:      pay.MpSubmit.MpType,
+		ResourceUri: pay.ResourceUri,
+		Reimport:    pay.Reimport,
+	})
+
+	reqv1.Header().Set("Authorization", token)
+	_, err = w.v1service.SubmitWithdrawal(ctx, reqv1)
+
+	if err != nil {
+		return err
+	}
+
+	streamlog("proses updating data accounting..")
 	rstream := w.rclient.RevenueStream(ctx)
 	rstream.Send(&revenue_iface.RevenueStreamRequest{
 		Event: &revenue_iface.RevenueStreamEvent{
@@ -189,7 +230,8 @@ func (w *wdServiceImpl) SubmitWithdrawal(
 				err = rstream.Send(msg)
 			}
 
-		case db_models.AdjCommision, db_models.AdjLostCompensation, db_models.AdjCompensation:
+		case db_models.AdjCommision, db_models.AdjLostCompensation, db_models.AdjCompensation,
+			db_models.AdjReturn, db_models.AdjShipping:
 			err = rstream.Send(&revenue_iface.RevenueStreamRequest{
 				Event: &revenue_iface.RevenueStreamEvent{
 					Kind: &revenue_iface.RevenueStreamEvent_Adjustment{
@@ -242,7 +284,11 @@ func (w *wdServiceImpl) SubmitWithdrawal(
 	}
 
 	_, err = rstream.CloseAndReceive()
-	return err
+	if err != nil {
+		return err
+	}
+
+	return w.saveFingerprint(pay.Reimport, uint(pay.TeamId), mp.ID, hash, pay.ResourceUri, agent.IdentityID())
 }
 
 type xlsSource interface {
@@ -287,7 +333,10 @@ func (w *wdServiceImpl) checkShop(
 			return mp, err
 		}
 
-	case common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR:
+	case common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR,
+		common.MarketplaceType_MARKETPLACE_TYPE_LAZADA,
+		common.MarketplaceType_MARKETPLACE_TYPE_TOKOPEDIA,
+		common.MarketplaceType_MARKETPLACE_TYPE_CUSTOM:
 		mpquery := marketplace_query.NewMarketplaceQuery(w.db, agent)
 		mp, err = mpquery.
 			ByID(uint(teamID), uint(payload.MpId)).
@@ -307,10 +356,28 @@ func (w *wdServiceImpl) checkShop(
 	return mp, nil
 }
 
-func (w *wdServiceImpl) createImporter(tipe common.MarketplaceType, data []byte) (withdrawal_service_v1.WdImporterIterate, error) {
+func (w *wdServiceImpl) createImporter(
+	source withdrawal_iface_v1.ImporterSource,
+	tipe common.MarketplaceType,
+	mapping *withdrawal_service_v1.ColumnMappingConfig,
+	data []byte,
+) (withdrawal_service_v1.WdImporterIterate, error) {
 	var err error
 	var importer withdrawal_service_v1.WdImporterIterate
 
+	if source == withdrawal_iface_v1.ImporterSource_IMPORTER_SOURCE_JSON {
+		if tipe != common.MarketplaceType_MARKETPLACE_TYPE_CUSTOM {
+			return importer, fmt.Errorf("source %s for %s not supported", source, tipe)
+		}
+
+		importer = datasource.NewCustomWdJson(io.NopCloser(bytes.NewReader(data)))
+		return importer, err
+	}
+
+	if mapping != nil {
+		return withdrawal_service_v1.NewMappedImporter(source, tipe, mapping.Mapping.Data(), data)
+	}
+
 	switch tipe {
 	case common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE:
 		importer = datasource.NewShopeeWdXls(io.NopCloser(bytes.NewReader(data)))
@@ -318,6 +385,10 @@ func (w *wdServiceImpl) createImporter(tipe common.MarketplaceType, data []byte)
 		importer = datasource_v2.NewTiktokWdXls(io.NopCloser(bytes.NewReader(data)))
 	case common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR:
 		importer = datasource.NewMengantarWdCsv(io.NopCloser(bytes.NewReader(data)))
+	case common.MarketplaceType_MARKETPLACE_TYPE_LAZADA:
+		importer = datasource.NewLazadaWdXls(io.NopCloser(bytes.NewReader(data)))
+	case common.MarketplaceType_MARKETPLACE_TYPE_TOKOPEDIA:
+		importer = datasource.NewTokopediaWdXls(io.NopCloser(bytes.NewReader(data)))
 	default:
 		return importer, fmt.Errorf("%s not supported", tipe)
 	}
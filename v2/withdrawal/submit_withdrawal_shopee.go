@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strings"
 	"time"
 
 	"connectrpc.com/connect"
@@ -13,6 +14,7 @@ import (
 	"github.com/pdcgo/schema/services/revenue_iface/v1"
 	"github.com/pdcgo/schema/services/withdrawal_iface/v2"
 	"github.com/pdcgo/shared/db_models"
+	withdrawal_service_v1 "github.com/pdcgo/withdrawal_service"
 	"github.com/pdcgo/withdrawal_service/v2/datasource_shopee"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -55,7 +57,7 @@ func (w *wdServiceImpl) SubmitWithdrawalShopee(
 	}
 
 	streamlog("membaca dan parsing file..")
-	source, err := w.getSource(ctx, pay)
+	source, hash, err := w.getSource(ctx, pay)
 
 	streamlog("check toko dan marketplace ..")
 	var mp *db_models.Marketplace
@@ -71,6 +73,11 @@ func (w *wdServiceImpl) SubmitWithdrawalShopee(
 
 	streamlog("marketplace %s found..", mp.MpName)
 
+	err = w.checkFingerprint(pay.Reimport, uint(pay.TeamId), mp.ID, hash)
+	if err != nil {
+		return streamerr(err)
+	}
+
 	streamlog("change marketplace id jika tidak sesuai..")
 	refids, err := source.GetRefIDs()
 	if err != nil {
@@ -97,14 +104,15 @@ func (w *wdServiceImpl) SubmitWithdrawalShopee(
 
 	for _, wd := range wds {
 		// creating log v2 wd
-		err = w.logWithdrawal(w.db.WithContext(ctx), &V2WithdrawalLog{
+		wlog := &V2WithdrawalLog{
 			TeamId:    mp.TeamID,
 			ShopId:    mp.ID,
 			Amount:    wd.Withdrawal.Amount,
 			UserId:    agent.IdentityID(),
 			At:        wd.Withdrawal.TransactionDate,
 			CreatedAt: time.Now(),
-		})
+		}
+		err = w.logWithdrawal(w.db.WithContext(ctx), wlog)
 
 		if err != nil {
 			return streamerr(err)
@@ -217,9 +225,18 @@ func (w *wdServiceImpl) SubmitWithdrawalShopee(
 
 		}
 
+		err = withdrawal_service_v1.SaveReconciliation(w.db.WithContext(ctx), newShopeeReconciliation(wlog, wd))
+		if err != nil {
+			return streamerr(err)
+		}
+	}
+
+	resourceUri := pay.ResourceUri
+	if len(pay.ResourceUris) != 0 {
+		resourceUri = strings.Join(pay.ResourceUris, ",")
 	}
 
-	return nil
+	return streamerr(w.saveFingerprint(pay.Reimport, uint(pay.TeamId), mp.ID, hash, resourceUri, agent.IdentityID()))
 }
 
 // func (w *wdServiceImpl) getOrderAdjustmentMultiRegion(orderID uint, before, after time.Time) ([]*db_models.OrderAdjustment, error) {
@@ -242,33 +259,36 @@ type Source interface {
 	ValidWithdrawal(ctx context.Context) ([]*datasource_shopee.ShopeeWdSet, error)
 }
 
-func (w *wdServiceImpl) getSource(ctx context.Context, pay *withdrawal_iface.SubmitWithdrawalShopeeRequest) (Source, error) {
+// getSource return source dan content hash file
+func (w *wdServiceImpl) getSource(ctx context.Context, pay *withdrawal_iface.SubmitWithdrawalShopeeRequest) (Source, string, error) {
 	var err error
 	if len(pay.ResourceUris) == 0 {
 		// dengan single file
 		var data []byte
 		data, err = w.storage.GetContent(ctx, pay.ResourceUri)
 		if err != nil {
-			return nil, fmt.Errorf("error reading %s", pay.ResourceUri)
+			return nil, "", fmt.Errorf("error reading %s", pay.ResourceUri)
 		}
 
 		source := datasource_shopee.NewShopeeXlsWithdrawal(io.NopCloser(bytes.NewReader(data)))
-		return source, err
+		return source, withdrawal_service_v1.ContentHash(data), err
 	}
 
 	readers := []io.ReadCloser{}
+	datas := [][]byte{}
 	for _, uri := range pay.ResourceUris {
 		var data []byte
 		data, err = w.storage.GetContent(ctx, uri)
 		if err != nil {
-			return nil, fmt.Errorf("error reading %s", uri)
+			return nil, "", fmt.Errorf("error reading %s", uri)
 		}
 
 		readers = append(readers, io.NopCloser(bytes.NewReader(data)))
+		datas = append(datas, data)
 
 	}
 
 	source, err := datasource_shopee.NewShopeeXlsMultiFile(readers)
 
-	return source, err
+	return source, withdrawal_service_v1.MultiContentHash(datas), err
 }
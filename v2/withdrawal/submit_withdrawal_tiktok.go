@@ -16,6 +16,7 @@ import (
 	"github.com/pdcgo/schema/services/revenue_iface/v1"
 	"github.com/pdcgo/schema/services/withdrawal_iface/v2"
 	"github.com/pdcgo/shared/db_models"
+	withdrawal_service_v1 "github.com/pdcgo/withdrawal_service"
 	"github.com/pdcgo/withdrawal_service/v2/datasource"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -69,6 +70,7 @@ func (w *wdServiceImpl) SubmitWithdrawalTiktok(
 		streamlog("error reading %s", pay.ResourceUri)
 		return err
 	}
+	hash := withdrawal_service_v1.ContentHash(data)
 
 	streamlog("check toko dan marketplace ..")
 	var mp *db_models.Marketplace
@@ -84,6 +86,11 @@ func (w *wdServiceImpl) SubmitWithdrawalTiktok(
 
 	streamlog("marketplace %s found..", mp.MpName)
 
+	err = w.checkFingerprint(pay.Reimport, uint(pay.TeamId), mp.ID, hash)
+	if err != nil {
+		return streamerr(err)
+	}
+
 	// open di datasource baru
 	streamlog("parsing file..")
 	source := datasource.NewV2TiktokWdXls(io.NopCloser(bytes.NewReader(data)))
@@ -98,16 +105,31 @@ func (w *wdServiceImpl) SubmitWithdrawalTiktok(
 		return streamerrf("withdrawal data is empty")
 	}
 
+	// file tiktok tidak punya identitas toko, dicek dari order nya
+	refIDs := []string{}
+	for _, wd := range wds {
+		for _, earning := range wd.Earning {
+			for _, inv := range earning.Involist {
+				refIDs = append(refIDs, inv.ExternalOrderID)
+			}
+		}
+	}
+	err = withdrawal_service_v1.CheckShopOrders(w.db.WithContext(ctx), uint(pay.TeamId), mp.ID, refIDs)
+	if err != nil {
+		return streamerr(err)
+	}
+
 	// update order jadi selesai
 	for _, wd := range wds {
-		err = w.logWithdrawal(w.db.WithContext(ctx), &V2WithdrawalLog{
+		wlog := &V2WithdrawalLog{
 			TeamId:    mp.TeamID,
 			ShopId:    mp.ID,
 			Amount:    wd.Withdrawal.Amount,
 			UserId:    agent.IdentityID(),
 			At:        wd.Withdrawal.SuccessTime,
 			CreatedAt: time.Now(),
-		})
+		}
+		err = w.logWithdrawal(w.db.WithContext(ctx), wlog)
 
 		if err != nil {
 			return streamerr(err)
@@ -330,7 +352,11 @@ func (w *wdServiceImpl) SubmitWithdrawalTiktok(
 			}
 		}
 
+		err = withdrawal_service_v1.SaveReconciliation(w.db.WithContext(ctx), newTiktokReconciliation(wlog, wd))
+		if err != nil {
+			return streamerr(err)
+		}
 	}
 
-	return nil
+	return streamerr(w.saveFingerprint(pay.Reimport, uint(pay.TeamId), mp.ID, hash, pay.ResourceUri, agent.IdentityID()))
 }
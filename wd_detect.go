@@ -0,0 +1,132 @@
+package withdrawal_service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/pdcgo/schema/services/common/v1"
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/schema/services/withdrawal_task_iface/v1"
+	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/withdrawal_service/datasource"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+)
+
+func DetectedMarketplaceType(mpType db_models.OrderMpType) common.MarketplaceType {
+	switch mpType {
+	case db_models.OrderMpCustom:
+		return common.MarketplaceType_MARKETPLACE_TYPE_CUSTOM
+	case db_models.OrderMpLazada:
+		return common.MarketplaceType_MARKETPLACE_TYPE_LAZADA
+	case db_models.OrderMengantar:
+		return common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR
+	case db_models.OrderMpShopee:
+		return common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE
+	case db_models.OrderMpTiktok:
+		return common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK
+	case db_models.OrderMpTokopedia:
+		return common.MarketplaceType_MARKETPLACE_TYPE_TOKOPEDIA
+	}
+	return common.MarketplaceType_MARKETPLACE_TYPE_UNSPECIFIED
+}
+
+func DetectedSource(format datasource.DetectFormat) withdrawal_iface.ImporterSource {
+	switch format {
+	case datasource.DetectXls:
+		return withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS
+	case datasource.DetectCsv:
+		return withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV
+	case datasource.DetectJson:
+		return withdrawal_iface.ImporterSource_IMPORTER_SOURCE_JSON
+	}
+	return withdrawal_iface.ImporterSource_IMPORTER_SOURCE_UNSPECIFIED
+}
+
+func DetectWdFile(data []byte) (*withdrawal_task_iface.DetectedFile, error) {
+	detected, err := datasource.DetectWdFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &withdrawal_task_iface.DetectedFile{
+		MpType:       DetectedMarketplaceType(detected.MpType),
+		Source:       DetectedSource(detected.Format),
+		Parser:       string(detected.Parser),
+		ShopIdentity: detected.ShopIdentity,
+	}, nil
+}
+
+// CheckDetectedFile menolak file yang marketplace nya beda dengan yang dipilih.
+// source yang beda dengan hasil deteksi hanya dicatat, client lama masih mengirim source yang salah
+// dan importer dipilih dari hasil deteksi (lihat ImporterSourceOf).
+// file yang tidak dikenali dan import dengan mapping kolom team tidak dicek, error nya dari importer
+func CheckDetectedFile(
+	source withdrawal_iface.ImporterSource,
+	tipe common.MarketplaceType,
+	mapping *ColumnMappingConfig,
+	data []byte,
+) (*withdrawal_task_iface.DetectedFile, error) {
+	if mapping != nil {
+		return nil, nil
+	}
+
+	detected, err := DetectWdFile(data)
+	if err != nil {
+		if errors.Is(err, datasource.ErrWdFileNotDetected) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if detected.MpType != tipe {
+		return detected, wd_error.New(
+			wd_error.CodeMarketplaceMismatch,
+			fmt.Sprintf("file terdeteksi withdrawal %s, marketplace yang dipilih %s", detected.MpType, tipe),
+			wd_error.Params{
+				"mp_type":          tipe.String(),
+				"detected_mp_type": detected.MpType.String(),
+			},
+		)
+	}
+
+	if source != withdrawal_iface.ImporterSource_IMPORTER_SOURCE_UNSPECIFIED && detected.Source != source {
+		slog.Warn("source beda dengan hasil deteksi, pakai hasil deteksi",
+			slog.String("source", source.String()),
+			slog.String("detected_source", detected.Source.String()),
+			slog.String("parser", detected.Parser),
+		)
+	}
+
+	return detected, nil
+}
+
+// ImporterSourceOf source untuk membuat importer, hasil deteksi didahulukan dari source request
+func ImporterSourceOf(source withdrawal_iface.ImporterSource, detected *withdrawal_task_iface.DetectedFile) withdrawal_iface.ImporterSource {
+	if detected == nil || detected.Source == withdrawal_iface.ImporterSource_IMPORTER_SOURCE_UNSPECIFIED {
+		return source
+	}
+	return detected.Source
+}
+
+// CheckV1Parser menolak file yang terdeteksi tapi tidak bisa dibaca importer v1,
+// tiktok dengan potongan gmv pay hanya bisa dibaca datasource v2
+func CheckV1Parser(detected *withdrawal_task_iface.DetectedFile) error {
+	if detected == nil {
+		return nil
+	}
+
+	switch datasource.DetectParser(detected.Parser) {
+	case datasource.ParserTiktokWdV2:
+		return wd_error.New(
+			wd_error.CodeUnsupportedImporter,
+			fmt.Sprintf("file withdrawal %s tidak bisa dibaca importer v1, import lewat withdrawal v2", detected.Parser),
+			wd_error.Params{
+				"mp_type": detected.MpType.String(),
+				"parser":  detected.Parser,
+			},
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package withdrawal_service_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pdcgo/schema/services/common/v1"
+	"github.com/pdcgo/schema/services/withdrawal_iface/v1"
+	"github.com/pdcgo/withdrawal_service"
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDetectedFile(t *testing.T) {
+	data, err := os.ReadFile("test/assets/shopee/luxy_wdgagal.xlsx")
+	assert.Nil(t, err)
+
+	t.Run("marketplace sesuai", func(t *testing.T) {
+		detected, err := withdrawal_service.CheckDetectedFile(
+			withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS,
+			common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE,
+			nil,
+			data,
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, "zafa.wear", detected.ShopIdentity)
+	})
+
+	t.Run("file shopee dipilih tiktok", func(t *testing.T) {
+		_, err := withdrawal_service.CheckDetectedFile(
+			withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS,
+			common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK,
+			nil,
+			data,
+		)
+		assert.Equal(t, wd_error.CodeMarketplaceMismatch, wd_error.From(err).Code)
+	})
+
+	t.Run("file xls dipilih csv pakai hasil deteksi", func(t *testing.T) {
+		// client lama masih mengirim source csv untuk file shopee
+		detected, err := withdrawal_service.CheckDetectedFile(
+			withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV,
+			common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE,
+			nil,
+			data,
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS, detected.Source)
+
+		source := withdrawal_service.ImporterSourceOf(withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV, detected)
+		assert.Equal(t, withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS, source)
+	})
+
+	t.Run("source tidak dipilih pakai hasil deteksi", func(t *testing.T) {
+		detected, err := withdrawal_service.CheckDetectedFile(
+			withdrawal_iface.ImporterSource_IMPORTER_SOURCE_UNSPECIFIED,
+			common.MarketplaceType_MARKETPLACE_TYPE_SHOPEE,
+			nil,
+			data,
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS, detected.Source)
+	})
+
+	t.Run("mapping kolom team tidak dicek", func(t *testing.T) {
+		detected, err := withdrawal_service.CheckDetectedFile(
+			withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV,
+			common.MarketplaceType_MARKETPLACE_TYPE_MENGANTAR,
+			&withdrawal_service.ColumnMappingConfig{},
+			data,
+		)
+		assert.Nil(t, err)
+		assert.Nil(t, detected)
+
+		source := withdrawal_service.ImporterSourceOf(withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV, detected)
+		assert.Equal(t, withdrawal_iface.ImporterSource_IMPORTER_SOURCE_CSV, source)
+	})
+}
+
+func TestCheckV1Parser(t *testing.T) {
+	check := func(fname string) error {
+		data, err := os.ReadFile(fname)
+		assert.Nil(t, err)
+
+		detected, err := withdrawal_service.CheckDetectedFile(
+			withdrawal_iface.ImporterSource_IMPORTER_SOURCE_XLS,
+			common.MarketplaceType_MARKETPLACE_TYPE_TIKTOK,
+			nil,
+			data,
+		)
+		assert.Nil(t, err)
+		return withdrawal_service.CheckV1Parser(detected)
+	}
+
+	t.Run("tiktok v1 bisa dibaca", func(t *testing.T) {
+		err := check("test/assets/testwd/tiktok14-3.xlsx")
+		assert.Nil(t, err)
+	})
+
+	t.Run("tiktok v2 ditolak importer v1", func(t *testing.T) {
+		err := check("test/assets/tiktok/gmv_mlongo.xlsx")
+		assert.Equal(t, wd_error.CodeUnsupportedImporter, wd_error.From(err).Code)
+		assert.Equal(t, "tiktok_wd_v2", wd_error.From(err).Params["parser"])
+	})
+
+	t.Run("file tidak terdeteksi tidak dicek", func(t *testing.T) {
+		err := withdrawal_service.CheckV1Parser(nil)
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,153 @@
+package wd_error
+
+import (
+	"errors"
+)
+
+// Category dipakai frontend untuk menentukan pesan dan aksi ke user
+type Category string
+
+const (
+	CategoryUser      Category = "user"      // bisa diperbaiki user, misal file atau toko yang dipilih salah
+	CategoryRetryable Category = "retryable" // error sementara, task dicoba ulang otomatis
+	CategoryBug       Category = "bug"       // perlu dicek developer
+)
+
+type Code string
+
+const (
+	CodeUnknown Code = "UNKNOWN"
+
+	// datasource
+	CodeWdInProcess         Code = "WD_IN_PROCESS"
+	CodeMpUsernameNotFound  Code = "MP_USERNAME_NOT_FOUND"
+	CodeFileInvalid         Code = "FILE_INVALID"
+	CodeRangeTooShort       Code = "RANGE_TOO_SHORT"
+	CodeEarningNotTraced    Code = "EARNING_NOT_TRACED"
+	CodeWdNotMatch          Code = "WD_NOT_MATCH"
+	CodeUnsupportedImporter Code = "UNSUPPORTED_IMPORTER"
+	CodeMappingInvalid      Code = "MAPPING_INVALID"
+
+	// order_query
+	CodeMarketplaceMismatch    Code = "MARKETPLACE_MISMATCH"
+	CodeMarketplaceNotFound    Code = "MARKETPLACE_NOT_FOUND"
+	CodeOrderMarketplaceNotSet Code = "ORDER_MARKETPLACE_NOT_SET"
+	CodeOrderRefNotFound       Code = "ORDER_REF_NOT_FOUND"
+
+	// runner
+	CodeDuplicateFile Code = "DUPLICATE_FILE"
+	CodeFileNotFound  Code = "FILE_NOT_FOUND"
+	CodeStorage       Code = "STORAGE"
+	CodeTemporary     Code = "TEMPORARY"
+
+	// task rpc
+	CodeTaskNotFound       Code = "TASK_NOT_FOUND"
+	CodeTaskNotCancellable Code = "TASK_NOT_CANCELLABLE"
+	CodeTaskNotRevertable  Code = "TASK_NOT_REVERTABLE"
+	CodeTaskRevertConflict Code = "TASK_REVERT_CONFLICT"
+	CodeTaskChangeNoKey    Code = "TASK_CHANGE_NO_KEY"
+)
+
+var catalog = map[Code]Category{
+	CodeUnknown: CategoryBug,
+
+	CodeWdInProcess:         CategoryUser,
+	CodeMpUsernameNotFound:  CategoryUser,
+	CodeFileInvalid:         CategoryUser,
+	CodeRangeTooShort:       CategoryUser,
+	CodeEarningNotTraced:    CategoryBug,
+	CodeWdNotMatch:          CategoryBug,
+	CodeUnsupportedImporter: CategoryUser,
+	CodeMappingInvalid:      CategoryUser,
+
+	CodeMarketplaceMismatch:    CategoryUser,
+	CodeMarketplaceNotFound:    CategoryUser,
+	CodeOrderMarketplaceNotSet: CategoryBug,
+	CodeOrderRefNotFound:       CategoryUser,
+
+	CodeDuplicateFile: CategoryUser,
+	CodeFileNotFound:  CategoryUser,
+	CodeStorage:       CategoryRetryable,
+	CodeTemporary:     CategoryRetryable,
+
+	CodeTaskNotFound:       CategoryUser,
+	CodeTaskNotCancellable: CategoryUser,
+	CodeTaskNotRevertable:  CategoryUser,
+	CodeTaskRevertConflict: CategoryUser,
+	CodeTaskChangeNoKey:    CategoryBug,
+}
+
+func CategoryOf(code Code) Category {
+	category, ok := catalog[code]
+	if !ok {
+		return CategoryBug
+	}
+	return category
+}
+
+// Params data pendukung pesan error, misal username atau tanggal, untuk ditampilkan frontend
+type Params map[string]string
+
+type Error struct {
+	Code     Code
+	Category Category
+	Params   Params
+	Message  string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is error dengan code yang sama dianggap sama, supaya sentinel tetap bisa dicek walau params beda
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Code == e.Code
+}
+
+func New(code Code, message string, params Params) *Error {
+	return &Error{
+		Code:     code,
+		Category: CategoryOf(code),
+		Params:   params,
+		Message:  message,
+	}
+}
+
+func Wrap(code Code, err error, params Params) *Error {
+	return &Error{
+		Code:     code,
+		Category: CategoryOf(code),
+		Params:   params,
+		Err:      err,
+	}
+}
+
+// From ambil error terstruktur dari chain, error biasa dianggap bug
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded
+	}
+
+	return Wrap(CodeUnknown, err, nil)
+}
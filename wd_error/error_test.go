@@ -0,0 +1,45 @@
+package wd_error_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pdcgo/withdrawal_service/wd_error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	sentinel := wd_error.New(wd_error.CodeWdInProcess, "ada withdrawal yang masih diproses", nil)
+
+	t.Run("error dengan code sama dianggap sama", func(t *testing.T) {
+		err := fmt.Errorf("withdrawal 10.000 error %w", wd_error.New(wd_error.CodeWdInProcess, "lain", wd_error.Params{"wd": "1"}))
+		assert.ErrorIs(t, err, sentinel)
+		assert.NotErrorIs(t, err, wd_error.New(wd_error.CodeFileInvalid, "", nil))
+	})
+
+	t.Run("from error terstruktur", func(t *testing.T) {
+		err := fmt.Errorf("wrap %w", wd_error.New(wd_error.CodeRangeTooShort, "butuh range lebih lama", wd_error.Params{
+			"before": "2025-01-01",
+		}))
+
+		coded := wd_error.From(err)
+		assert.Equal(t, wd_error.CodeRangeTooShort, coded.Code)
+		assert.Equal(t, wd_error.CategoryUser, coded.Category)
+		assert.Equal(t, "2025-01-01", coded.Params["before"])
+	})
+
+	t.Run("from error biasa jadi bug", func(t *testing.T) {
+		coded := wd_error.From(errors.New("nil pointer"))
+		assert.Equal(t, wd_error.CodeUnknown, coded.Code)
+		assert.Equal(t, wd_error.CategoryBug, coded.Category)
+		assert.Equal(t, "nil pointer", coded.Error())
+	})
+
+	t.Run("wrap tetap bisa unwrap", func(t *testing.T) {
+		base := errors.New("timeout")
+		coded := wd_error.Wrap(wd_error.CodeStorage, base, nil)
+		assert.ErrorIs(t, coded, base)
+		assert.Equal(t, wd_error.CategoryRetryable, coded.Category)
+	})
+}
@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/pdcgo/shared/db_models"
+	"github.com/pdcgo/shared/events/order_event"
 	"github.com/pdcgo/shared/interfaces/identity_iface"
 	"github.com/pdcgo/shared/pkg/streampipe"
 	"github.com/pdcgo/withdrawal_service/marketplace_query"
+	"github.com/pdcgo/withdrawal_service/money"
 	"github.com/pdcgo/withdrawal_service/order_query"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -35,7 +37,11 @@ type ImporterProcessor interface {
 	OrderAdjustment(item *db_models.InvoItem) error
 	Unknown(item *db_models.InvoItem) error
 	Withdrawal(item *db_models.InvoItem) error
-	Check(sisaAmount float64) error
+	Check(sisaAmount money.Amount) error
+	Summary() *TaskSummary
+	Rollback()
+	EnablePreview()
+	Preview() *TaskPreview
 }
 
 func NewImporterProcessor(
@@ -45,7 +51,7 @@ func NewImporterProcessor(
 	query *WDImporterQuery,
 	agent identity_iface.Agent,
 	pub streampipe.PublishProvider,
-
+	taskID uint,
 ) ImporterProcessor {
 	ctxValue := context.WithValue(ctx, "controller", "importer_processor")
 	return &importerProcessorImpl{
@@ -55,15 +61,16 @@ func NewImporterProcessor(
 		agent:     agent,
 		itemlists: InvoList{},
 		pub:       pub,
+		journal:   newTaskJournal(taskID),
 	}
 }
 
 type InvoList []*db_models.InvoItem
 
-func (m InvoList) Amount() float64 {
-	var hasil float64
+func (m InvoList) Amount() money.Amount {
+	var hasil money.Amount
 	for _, val := range m {
-		hasil += val.Amount
+		hasil += money.FromFloat(val.Amount)
 	}
 
 	return hasil
@@ -120,8 +127,111 @@ type importerProcessorImpl struct {
 
 	wd             *db_models.Withdrawal
 	itemlists      InvoList
+	adjbuf         InvoList // order fund dan adjustment yang ditulis batch saat Check
 	adjlists       []uint
+	matched        InvoList // earning set berjalan yang cocok ke order, untuk rekonsiliasi
+	unmatched      InvoList
 	firstOrderTime time.Time
+	summary        TaskSummary
+	pending        TaskSummary // summary set yang belum commit
+	completedIDs   []uint      // order completed set berjalan, event dikirim setelah commit
+	journal        *taskJournal
+	preview        *TaskPreview
+
+	// satu withdrawal beserta earning nya di satu transaksi, commit saat Check
+	tx *gorm.DB
+}
+
+// Summary implements ImporterProcessor.
+func (i *importerProcessorImpl) Summary() *TaskSummary {
+	summary := i.summary
+	return &summary
+}
+
+// Rollback implements ImporterProcessor.
+// membatalkan set withdrawal yang belum commit, aman dipanggil berkali kali
+func (i *importerProcessorImpl) Rollback() {
+	if i.tx == nil {
+		return
+	}
+
+	i.tx.Rollback()
+	i.tx = nil
+	i.adjbuf = InvoList{}
+	i.pending = TaskSummary{}
+	i.completedIDs = nil
+}
+
+// inSet menjalankan handler di transaksi set withdrawal yang sedang berjalan
+func (i *importerProcessorImpl) inSet(handler func(tx *gorm.DB) error) error {
+	if i.tx == nil {
+		tx := i.db.Begin()
+		if tx.Error != nil {
+			return tx.Error
+		}
+		i.tx = tx
+	}
+
+	err := handler(i.tx)
+	if err != nil {
+		i.Rollback()
+	}
+	return err
+}
+
+// commitSet summary baru dihitung setelah set commit
+func (i *importerProcessorImpl) commitSet() error {
+	if i.tx == nil {
+		return nil
+	}
+
+	tx := i.tx
+	i.tx = nil
+	completedIDs := i.completedIDs
+	i.completedIDs = nil
+
+	// dry run, set di rollback setelah preview dicatat supaya lock tidak ditahan sampai file selesai
+	if i.preview != nil {
+		err := tx.Rollback().Error
+		if err != nil {
+			i.pending = TaskSummary{}
+			return err
+		}
+	} else {
+		err := tx.Commit().Error
+		if err != nil {
+			i.pending = TaskSummary{}
+			return err
+		}
+
+		for _, id := range completedIDs {
+			i.pub.Send(streampipe.DEFAULT_TOPIC, &order_event.OrderEvent{
+				Action:  order_event.OrderChangeStatus,
+				Status:  db_models.OrdCompleted,
+				OrderID: id,
+			})
+		}
+	}
+
+	i.summary.WithdrawalCreated += i.pending.WithdrawalCreated
+	i.summary.OrderFundApplied += i.pending.OrderFundApplied
+	i.summary.AdjustmentLogged += i.pending.AdjustmentLogged
+	i.summary.OrderNotFound += i.pending.OrderNotFound
+	i.summary.TotalAmount += i.pending.TotalAmount
+	i.pending = TaskSummary{}
+	return nil
+}
+
+// EnablePreview implements ImporterProcessor.
+// dipakai untuk dry run, tiap set di rollback jadi set berikutnya tidak melihat perubahan set sebelumnya
+func (i *importerProcessorImpl) EnablePreview() {
+	i.preview = NewTaskPreview()
+	i.journal = newTaskJournal(0)
+}
+
+// Preview implements ImporterProcessor.
+func (i *importerProcessorImpl) Preview() *TaskPreview {
+	return i.preview
 }
 
 // SetFilterMarketplace implements ImporterProcessor.
@@ -138,13 +248,21 @@ func (i *importerProcessorImpl) Unknown(item *db_models.InvoItem) error {
 	i.itemlists = append(i.itemlists, item)
 
 	if item.ExternalOrderID == "" {
-		err = i.db.Transaction(func(tx *gorm.DB) error {
-			err := i.addOrderNotFound(tx, item)
+		var notFound bool
+		i.unmatched = append(i.unmatched, item)
+		err = i.inSet(func(tx *gorm.DB) error {
+			var err error
+			notFound, err = i.addOrderNotFound(tx, item)
 			if err != nil {
 				return err
 			}
 
 			if i.needIncrement() {
+				err = i.journal.Snapshot(tx, ChangeWithdrawal, []uint{i.wd.ID}, "diff_amount")
+				if err != nil {
+					return err
+				}
+
 				wdquery := i.fin.DataQuery(i.agent, tx).WithdrawalByID(i.wd.ID)
 				err = wdquery.IncActualAmount(item.Amount)
 
@@ -156,6 +274,10 @@ func (i *importerProcessorImpl) Unknown(item *db_models.InvoItem) error {
 			return nil
 
 		})
+
+		if err == nil && notFound {
+			i.pending.OrderNotFound += 1
+		}
 	} else {
 		err = i.OrderAdjustment(item)
 	}
@@ -166,13 +288,15 @@ func (i *importerProcessorImpl) Unknown(item *db_models.InvoItem) error {
 // Withdrawal implements ImporterProcessor.
 func (i *importerProcessorImpl) Withdrawal(item *db_models.InvoItem) error {
 	var err error
-	err = i.Check(item.BalanceAfter)
+	err = i.Check(money.FromFloat(item.BalanceAfter))
 	if err != nil {
 		return err
 	}
 
 	i.itemlists = InvoList{}
 	i.adjlists = []uint{}
+	i.matched = InvoList{}
+	i.unmatched = InvoList{}
 
 	// checking date time last order masuk system
 	if item.TransactionDate.Before(i.firstOrderTime) {
@@ -181,7 +305,7 @@ func (i *importerProcessorImpl) Withdrawal(item *db_models.InvoItem) error {
 	}
 
 	// add to history wd
-	err = i.db.Transaction(func(tx *gorm.DB) error {
+	err = i.inSet(func(tx *gorm.DB) error {
 		var err error
 		// var mpquery order_query.MpItemQuery
 		mpquery := order_query.NewMarketplaceQuery(i.fin, tx, i.agent).
@@ -195,143 +319,208 @@ func (i *importerProcessorImpl) Withdrawal(item *db_models.InvoItem) error {
 			return err
 		}
 
-		return nil
+		if i.preview != nil {
+			i.preview.Withdrawals = append(i.preview.Withdrawals, &PreviewWithdrawal{
+				At:           item.TransactionDate.Unix(),
+				Amount:       math.Abs(item.Amount),
+				BalanceAfter: item.BalanceAfter,
+				IsNew:        i.wd.IsNew,
+			})
+		}
+
+		if i.wd.IsNew {
+			return i.journal.Created(tx, ChangeWithdrawal, i.wd.ID)
+		}
+
+		// withdrawal lama, diff_amount di reset saat CreateWithdrawal
+		return i.journal.Updated(tx, ChangeWithdrawal, i.wd.ID, map[string]interface{}{
+			"diff_amount": i.wd.DiffAmount,
+		})
 	})
 
+	if err == nil && i.wd != nil && i.wd.IsNew {
+		i.pending.WithdrawalCreated += 1
+		i.pending.TotalAmount += money.FromFloat(item.Amount).Abs()
+	}
+
 	return err
 }
 
 // OrderAdjustment implements ImporterProcessor.
+// order nya di lock dan adjustment ditulis bersama satu set di flushAdjustments
 func (i *importerProcessorImpl) OrderAdjustment(item *db_models.InvoItem) error {
 	i.itemlists = append(i.itemlists, item)
-	err := i.db.Transaction(func(tx *gorm.DB) error {
-		query := order_query.NewOrderQuery(tx, i.agent, i.pub).ByRefID(i.query.TeamID, item.ExternalOrderID)
-		err := query.Lock()
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				err = i.addOrderNotFound(tx, item)
-			}
-			return err
-		}
-
-		adjID, err := query.LogAdjustment(item.Type, item.TransactionDate, time.Time{}, item.Amount, item.Description)
-		if err != nil {
-			return err
-		}
-		i.adjlists = append(i.adjlists, adjID)
-
-		if i.needIncrement() {
-			wdquery := i.fin.DataQuery(i.agent, tx).WithdrawalByID(i.wd.ID)
-			err = wdquery.IncActualAmount(item.Amount)
+	i.adjbuf = append(i.adjbuf, item)
+	return nil
+}
 
-			if err != nil {
-				return err
-			}
-		}
+// OrderFund implements ImporterProcessor.
+func (i *importerProcessorImpl) OrderFund(item *db_models.InvoItem) error {
+	i.itemlists = append(i.itemlists, item)
+	i.adjbuf = append(i.adjbuf, item)
+	return nil
+}
 
+// flushAdjustments semua order satu set di lock dengan satu query lalu adjustment ditulis batch
+func (i *importerProcessorImpl) flushAdjustments(tx *gorm.DB) error {
+	var err error
+	items := i.adjbuf
+	if len(items) == 0 {
 		return nil
+	}
+	i.adjbuf = InvoList{}
 
-	})
+	query := order_query.NewOrderQuery(tx, i.agent, i.pub).ByRefIDs(i.query.TeamID, items.OrderRefIDs())
+	err = query.Lock()
+	if err != nil {
+		return err
+	}
 
-	return err
-}
+	logs := make([]*order_query.AdjustmentLog, len(items))
+	for idx, item := range items {
+		logs[idx] = &order_query.AdjustmentLog{
+			RefID:  item.ExternalOrderID,
+			Type:   item.Type,
+			At:     item.TransactionDate,
+			Amount: item.Amount,
+			Desc:   item.Description,
+		}
+	}
 
-// OrderFund implements ImporterProcessor.
-func (i *importerProcessorImpl) OrderFund(item *db_models.InvoItem) error {
-	i.itemlists = append(i.itemlists, item)
+	// wd_total dan adjustment order dihitung ulang di LogAdjustments, nilai lama dicatat untuk revert
+	err = i.snapshotOrderTotals(tx, items.OrderRefIDs())
+	if err != nil {
+		return err
+	}
 
-	err := i.db.Transaction(func(tx *gorm.DB) error {
-		var err error
-		query := order_query.NewOrderQuery(tx, i.agent, i.pub).ByRefID(i.query.TeamID, item.ExternalOrderID)
-		err = query.Lock()
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				err = i.addOrderNotFound(tx, item)
+	err = query.LogAdjustments(logs)
+	if err != nil {
+		return err
+	}
+
+	var found int
+	var amount money.Amount
+	for idx, log := range logs {
+		item := items[idx]
+		if log.NotFound {
+			i.unmatched = append(i.unmatched, item)
+			notFound, err := i.addOrderNotFound(tx, item)
+			if err != nil {
+				return err
 			}
-			return err
+			if notFound {
+				i.pending.OrderNotFound += 1
+			}
+			continue
 		}
-		adjID, err := query.LogAdjustment(item.Type, item.TransactionDate, time.Time{}, item.Amount, item.Description)
+
+		err = i.logAdjustment(tx, item, log)
 		if err != nil {
 			return err
 		}
-		i.adjlists = append(i.adjlists, adjID)
-
-		if i.needIncrement() {
-			wdquery := i.fin.DataQuery(i.agent, tx).WithdrawalByID(i.wd.ID)
-			err = wdquery.IncActualAmount(item.Amount)
-			if err != nil {
-				return err
-			}
+		i.adjlists = append(i.adjlists, log.ID)
+		i.matched = append(i.matched, item)
+
+		found += 1
+		amount += money.FromFloat(item.Amount)
+		if item.Type == db_models.AdjOrderFund {
+			i.pending.OrderFundApplied += 1
+		} else {
+			i.pending.AdjustmentLogged += 1
 		}
+	}
 
+	if found == 0 || !i.needIncrement() {
 		return nil
-	})
+	}
 
-	return err
+	err = i.journal.Snapshot(tx, ChangeWithdrawal, []uint{i.wd.ID}, "diff_amount")
+	if err != nil {
+		return err
+	}
+
+	wdquery := i.fin.DataQuery(i.agent, tx).WithdrawalByID(i.wd.ID)
+	return wdquery.IncActualAmount(amount.Float64())
 }
 
-func (i *importerProcessorImpl) Check(sisaAmount float64) error {
+func (i *importerProcessorImpl) Check(sisaAmount money.Amount) error {
 	var err error
 	if len(i.itemlists) == 0 {
-		return err
+		if i.wd != nil {
+			err = i.inSet(func(tx *gorm.DB) error {
+				return i.saveReconciliation(tx, sisaAmount)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return i.commitSet()
 	}
 
-	if i.wd == nil { // get jika atasnya ada
-		first := i.itemlists.First()
-		wd, err := i.getNextWithdrawal(first.TransactionDate, i.itemlists.Amount())
+	err = i.inSet(func(tx *gorm.DB) error {
+		// adjustment ditulis dulu dengan wd yang aktif saat item dibaca
+		err := i.flushAdjustments(tx)
 		if err != nil {
 			return err
 		}
 
-		if wd.ID != 0 {
-			i.wd = wd
-		}
-	}
+		if i.wd == nil { // get jika atasnya ada
+			first := i.itemlists.First()
+			wd, err := i.getNextWithdrawal(tx, first.TransactionDate, i.itemlists.Amount().Float64())
+			if err != nil {
+				return err
+			}
 
-	if i.wd == nil {
-		return nil
-	}
+			if wd.ID != 0 {
+				i.wd = wd
+			}
+		}
 
-	if sisaAmount != 0 {
-		err = i.db.Transaction(func(tx *gorm.DB) error {
-			return i.wdIncBalanceSisa(tx, sisaAmount)
-		})
-		if err != nil {
-			return err
+		if i.wd == nil {
+			return nil
 		}
-	}
 
-	refIDs := i.itemlists.OrderRefIDs()
-	err = i.db.Transaction(func(tx *gorm.DB) error {
+		if sisaAmount != 0 {
+			err = i.wdIncBalanceSisa(tx, sisaAmount)
+			if err != nil {
+				return err
+			}
+		}
 
+		refIDs := i.itemlists.OrderRefIDs()
 		refIDsQuery := order_query.NewOrderQuery(tx, i.agent, i.pub).ByRefIDs(i.query.TeamID, refIDs)
-		err = refIDsQuery.Lock()
-		if err != nil {
-			return err
+		// dry run tidak mengunci order yang mungkin sedang diproses import lain
+		if i.preview == nil {
+			err = refIDsQuery.Lock()
+			if err != nil {
+				return err
+			}
 		}
 
-		err = refIDsQuery.Completed(i.wd.At)
+		err = i.completed(tx, refIDsQuery, refIDs)
 		if err != nil {
 			return err
 		}
+
 		err = i.connectAdjToWithdrawal(tx, i.wd.ID, i.wd.At)
 		if err != nil {
 			return err
 		}
 
-		return err
+		return i.saveReconciliation(tx, sisaAmount)
 	})
 
 	if err != nil {
 		return err
 	}
 
-	return err
+	return i.commitSet()
 }
 
-func (i *importerProcessorImpl) addOrderNotFound(tx *gorm.DB, item *db_models.InvoItem) error {
+// addOrderNotFound return true kalau entry baru dibuat
+func (i *importerProcessorImpl) addOrderNotFound(tx *gorm.DB, item *db_models.InvoItem) (bool, error) {
 	if i.wd == nil {
-		return nil
+		return false, nil
 	}
 	var lostItem db_models.WdOrderNotFound
 
@@ -344,11 +533,11 @@ func (i *importerProcessorImpl) addOrderNotFound(tx *gorm.DB, item *db_models.In
 		Find(&lostItem).
 		Error
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if lostItem.ID != 0 {
-		return nil
+		return false, nil
 	}
 
 	lostItem = db_models.WdOrderNotFound{
@@ -360,7 +549,25 @@ func (i *importerProcessorImpl) addOrderNotFound(tx *gorm.DB, item *db_models.In
 	err = tx.Save(&lostItem).Error
 
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	err = i.journal.Created(tx, ChangeWdOrderNotFound, lostItem.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if i.preview != nil {
+		i.preview.OrderNotFound = append(i.preview.OrderNotFound, &PreviewOrderNotFound{
+			OrderRefId: item.ExternalOrderID,
+			Amount:     item.Amount,
+			At:         item.TransactionDate.Unix(),
+		})
+	}
+
+	err = i.journal.Snapshot(tx, ChangeWithdrawal, []uint{i.wd.ID}, "order_not_found")
+	if err != nil {
+		return false, err
 	}
 
 	err = tx.
@@ -374,22 +581,67 @@ func (i *importerProcessorImpl) addOrderNotFound(tx *gorm.DB, item *db_models.In
 		}).Error
 	// updating withdrawal count
 
-	return err
+	return err == nil, err
 }
 
-func (i *importerProcessorImpl) wdIncBalanceSisa(tx *gorm.DB, amount float64) error {
+func (i *importerProcessorImpl) wdIncBalanceSisa(tx *gorm.DB, amount money.Amount) error {
 	if i.wd == nil {
 		return errors.New("wd contain nil")
 	}
 
+	err := i.journal.Snapshot(tx, ChangeWithdrawal, []uint{i.wd.ID}, "diff_amount")
+	if err != nil {
+		return err
+	}
+
 	wdquery := i.fin.DataQuery(i.agent, tx).WithdrawalByID(i.wd.ID)
-	return wdquery.IncActualAmount(amount)
+	return wdquery.IncActualAmount(amount.Float64())
 }
 
-func (i *importerProcessorImpl) getNextWithdrawal(tlimit time.Time, amount float64) (*db_models.Withdrawal, error) {
+// saveReconciliation laporan set withdrawal, diff_amount diambil setelah semua earning masuk
+func (i *importerProcessorImpl) saveReconciliation(tx *gorm.DB, sisaAmount money.Amount) error {
+	var err error
+	var wdAmount, diffAmount float64
+
+	err = tx.
+		Model(&db_models.AssetHistory{}).
+		Select("amount").
+		Where("id = ?", i.wd.HistID).
+		Find(&wdAmount).
+		Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.
+		Model(&db_models.Withdrawal{}).
+		Select("diff_amount").
+		Where("id = ?", i.wd.ID).
+		Find(&diffAmount).
+		Error
+	if err != nil {
+		return err
+	}
+
+	rec := WithdrawalReconciliation{
+		Source:           ReconciliationV1,
+		TeamID:           i.query.TeamID,
+		WithdrawalID:     i.wd.ID,
+		MpID:             i.query.MpID,
+		TaskID:           i.journal.taskID,
+		WithdrawalAmount: money.FromFloat(wdAmount).Abs(),
+		CarriedOver:      sisaAmount,
+		DiffAmount:       money.FromFloat(diffAmount),
+	}
+	rec.SetEarnings(i.matched, i.unmatched)
+
+	return SaveReconciliation(tx, &rec)
+}
+
+func (i *importerProcessorImpl) getNextWithdrawal(tx *gorm.DB, tlimit time.Time, amount float64) (*db_models.Withdrawal, error) {
 	var err error
 	var wd db_models.Withdrawal
-	next := i.fin.DataQuery(i.agent, i.db).NextWithdrawal(i.query.MpID, tlimit, amount)
+	next := i.fin.DataQuery(i.agent, tx).NextWithdrawal(i.query.MpID, tlimit, amount)
 	err = next.Get(&wd)
 	if err != nil {
 		return nil, err
@@ -404,11 +656,34 @@ func (i *importerProcessorImpl) connectAdjToWithdrawal(tx *gorm.DB, wdID uint, w
 	if adjlen == 0 {
 		return nil
 	}
+	if i.journal.enabled() {
+		valids := []map[string]interface{}{}
+		err = tx.
+			Model(&db_models.WdValid{}).
+			Select("withdrawal_id", "order_adjustment_id").
+			Where("withdrawal_id = ?", wdID).
+			Find(&valids).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = i.journal.Deleted(tx, ChangeWdValid, valids)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = tx.Model(&db_models.WdValid{}).Where("withdrawal_id = ?", wdID).Delete(&db_models.WdValid{}).Error
 	if err != nil {
 		return err
 	}
 
+	err = i.journal.Snapshot(tx, ChangeOrderAdjustment, i.adjlists, "fund_at")
+	if err != nil {
+		return err
+	}
+
 	// updating fund at
 	err = tx.Model(&db_models.OrderAdjustment{}).Where("id IN ?", i.adjlists).Update("fund_at", wdtime).Error
 	if err != nil {
@@ -432,6 +707,19 @@ func (i *importerProcessorImpl) connectAdjToWithdrawal(tx *gorm.DB, wdID uint, w
 		if err != nil {
 			return fmt.Errorf("%s id order %d wd %d", err.Error(), id, wdID)
 		}
+
+		err = i.journal.CreatedRow(tx, ChangeWdValid, map[string]interface{}{
+			"withdrawal_id":       wdID,
+			"order_adjustment_id": id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = i.journal.Snapshot(tx, ChangeWithdrawal, []uint{wdID}, "order_valid")
+	if err != nil {
+		return err
 	}
 
 	err = tx.Model(&db_models.Withdrawal{}).Where("id = ?", wdID).Updates(map[string]interface{}{
@@ -460,3 +748,147 @@ func (i *importerProcessorImpl) needIncrement() bool {
 
 	return true
 }
+
+func (i *importerProcessorImpl) snapshotOrderTotals(tx *gorm.DB, refIDs []string) error {
+	if !i.journal.enabled() {
+		return nil
+	}
+
+	orderIDs := []uint{}
+	err := tx.
+		Model(&db_models.Order{}).
+		Where("team_id = ?", i.query.TeamID).
+		Where("order_ref_id IN ?", refIDs).
+		Pluck("id", &orderIDs).
+		Error
+	if err != nil {
+		return err
+	}
+
+	return i.journal.Snapshot(tx, ChangeOrder, orderIDs, "wd_total", "adjustment")
+}
+
+// logAdjustment nilai lama adjustment yang di update dicatat untuk revert
+func (i *importerProcessorImpl) logAdjustment(tx *gorm.DB, item *db_models.InvoItem, log *order_query.AdjustmentLog) error {
+	isNew := log.Prev == nil
+	if i.preview != nil {
+		i.preview.Adjustments = append(i.preview.Adjustments, &PreviewAdjustment{
+			OrderRefId: item.ExternalOrderID,
+			Type:       fmt.Sprint(item.Type),
+			Amount:     item.Amount,
+			At:         item.TransactionDate.Unix(),
+			IsNew:      isNew,
+		})
+	}
+
+	if !isNew {
+		return i.journal.Updated(tx, ChangeOrderAdjustment, log.ID, map[string]interface{}{
+			"amount":  log.Prev.Amount,
+			"at":      log.Prev.At,
+			"fund_at": log.Prev.FundAt,
+		})
+	}
+
+	return i.journal.Created(tx, ChangeOrderAdjustment, log.ID)
+}
+
+// completed event order dikirim setelah set commit
+func (i *importerProcessorImpl) completed(tx *gorm.DB, query order_query.OrderRefIDsQuery, refIDs []string) error {
+	// dry run tidak memanggil Completed supaya event order tidak terkirim
+	if i.preview != nil {
+		refs, err := query.CompletedRefIDs()
+		if err != nil {
+			return err
+		}
+		i.preview.CompletedOrders = append(i.preview.CompletedOrders, refs...)
+		return nil
+	}
+
+	completeIDs, err := completeOrders(tx, i.journal, query, i.query.TeamID, 0, refIDs, i.wd.At)
+	if err != nil {
+		return err
+	}
+	i.completedIDs = append(i.completedIDs, completeIDs...)
+	return nil
+}
+
+// completeOrders status order sebelum completed dan log yang dibuat dicatat untuk revert, mpID 0 berarti semua marketplace
+func completeOrders(
+	tx *gorm.DB,
+	journal *taskJournal,
+	query order_query.OrderRefIDsQuery,
+	teamID uint,
+	mpID uint,
+	refIDs []string,
+	at time.Time,
+) ([]uint, error) {
+	var err error
+	var lastOrderTs, lastInvTs uint
+	orderIDs := []uint{}
+	returnIDs := []uint{}
+
+	if journal.enabled() {
+		orderQuery := tx.
+			Model(&db_models.Order{}).
+			Where("team_id = ?", teamID).
+			Where("order_ref_id IN ?", refIDs)
+		if mpID != 0 {
+			orderQuery = orderQuery.Where("order_mp_id = ?", mpID)
+		}
+		err = orderQuery.Pluck("id", &orderIDs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.
+			Model(&db_models.Order{}).
+			Where("id IN ?", orderIDs).
+			Where("invertory_return_tx_id IS NOT NULL").
+			Pluck("invertory_return_tx_id", &returnIDs).
+			Error
+		if err != nil {
+			return nil, err
+		}
+
+		err = journal.Snapshot(tx, ChangeOrder, orderIDs, "status", "wd_fund", "wd_fund_at")
+		if err != nil {
+			return nil, err
+		}
+
+		err = journal.Snapshot(tx, ChangeInvTransaction, returnIDs, "status")
+		if err != nil {
+			return nil, err
+		}
+
+		lastOrderTs, err = journal.LastID(tx, ChangeOrderTimestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		lastInvTs, err = journal.LastID(tx, ChangeInvTimestamp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	completeIDs, err := query.Completed(at)
+	if err != nil {
+		return nil, err
+	}
+
+	err = journal.CreatedAfter(tx, ChangeOrderTimestamp, lastOrderTs, func(q *gorm.DB) *gorm.DB {
+		return q.Where("order_id IN ?", orderIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = journal.CreatedAfter(tx, ChangeInvTimestamp, lastInvTs, func(q *gorm.DB) *gorm.DB {
+		return q.Where("tx_id IN ?", returnIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return completeIDs, nil
+}